@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MinHandleLength = 3
+	MaxHandleLength = 15
+)
+
+var reservedHandles = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"help":          true,
+	"login":         true,
+	"logout":        true,
+	"me":            true,
+	"moderator":     true,
+	"notifications": true,
+	"root":          true,
+	"search":        true,
+	"settings":      true,
+	"support":       true,
+	"system":        true,
+	"trends":        true,
+	"tweeter":       true,
+}
+
+// lookalikeRunes maps non ascii characters that are commonly used to
+// impersonate other users to the ascii character they resemble
+var lookalikeRunes = map[rune]rune{
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x',
+	'А': 'a', 'В': 'b', 'Е': 'e', 'К': 'k', 'М': 'm', 'Н': 'h', 'О': 'o',
+	'Р': 'p', 'С': 'c', 'Т': 't', 'Х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	'α': 'a', 'ο': 'o', 'ρ': 'p', 'ν': 'v', 'Α': 'a', 'Β': 'b', 'Ε': 'e',
+	'Ι': 'i', 'Κ': 'k', 'Μ': 'm', 'Ν': 'n', 'Ο': 'o', 'Ρ': 'p', 'Τ': 't',
+	'Χ': 'x', 'Υ': 'y', 'Ζ': 'z',
+}
+
+// skeletonReplacer collapses ascii sequences that look alike so that
+// handles like "nick" and "n1ck" share the same skeleton
+var skeletonReplacer = strings.NewReplacer(
+	"rn", "m",
+	"vv", "w",
+	"0", "o",
+	"1", "l",
+	"i", "l",
+	"5", "s",
+	"_", "",
+)
+
+// NormalizeHandle returns the canonical form of a handle, the one used to
+// compare users. Handles are case insensitive and the leading @ is optional
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+func ValidateHandle(handle string) error {
+
+	handle = strings.TrimPrefix(strings.TrimSpace(handle), "@")
+
+	if handle == "" {
+		return fmt.Errorf("user is required")
+	}
+
+	for _, character := range handle {
+
+		if lookalike, found := lookalikeRunes[character]; found {
+			return fmt.Errorf("user contains %q which looks like %q", character, lookalike)
+		}
+
+		if !isHandleCharacter(character) {
+			return fmt.Errorf("user can only contain letters, digits and underscores")
+		}
+	}
+
+	if len(handle) < MinHandleLength || len(handle) > MaxHandleLength {
+		return fmt.Errorf("user must have between %d and %d characters", MinHandleLength, MaxHandleLength)
+	}
+
+	if isReservedHandle(handle) {
+		return fmt.Errorf("user %s is reserved", handle)
+	}
+
+	return nil
+}
+
+// HandleSkeleton returns a representation of the handle in which visually
+// confusable handles are equal
+func HandleSkeleton(handle string) string {
+	return skeletonReplacer.Replace(NormalizeHandle(handle))
+}
+
+func isReservedHandle(handle string) bool {
+
+	skeleton := HandleSkeleton(handle)
+
+	for reserved := range reservedHandles {
+		if HandleSkeleton(reserved) == skeleton {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isHandleCharacter(character rune) bool {
+	return character >= 'a' && character <= 'z' ||
+		character >= 'A' && character <= 'Z' ||
+		character >= '0' && character <= '9' ||
+		character == '_'
+}
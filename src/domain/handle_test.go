@@ -0,0 +1,77 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+)
+
+func TestValidHandlesAreAccepted(t *testing.T) {
+
+	// Initialization
+	handles := []string{"grupoesfera", "nick", "@Nick_2017", "abc"}
+
+	for _, handle := range handles {
+
+		// Operation
+		err := domain.ValidateHandle(handle)
+
+		// Validation
+		if err != nil {
+			t.Errorf("Handle %s was expected to be valid but was %s", handle, err)
+		}
+	}
+}
+
+func TestInvalidHandlesAreRejected(t *testing.T) {
+
+	// Initialization
+	handles := map[string]string{
+		"":                  "user is required",
+		"nick name":         "user can only contain letters, digits and underscores",
+		"ab":                "user must have between 3 and 15 characters",
+		"averyverylongnick": "user must have between 3 and 15 characters",
+		"admin":             "user admin is reserved",
+		"Adm1n":             "user Adm1n is reserved",
+		"nіck":              `user contains 'і' which looks like 'i'`,
+	}
+
+	for handle, expectedError := range handles {
+
+		// Operation
+		err := domain.ValidateHandle(handle)
+
+		// Validation
+		if err == nil {
+			t.Errorf("Handle %s was expected to be invalid", handle)
+			continue
+		}
+
+		if err.Error() != expectedError {
+			t.Errorf("Expected error is %s but was %s", expectedError, err)
+		}
+	}
+}
+
+func TestHandlesAreNormalizedIgnoringCaseAndAt(t *testing.T) {
+
+	// Operation
+	handle := domain.NormalizeHandle(" @Nick ")
+
+	// Validation
+	if handle != "nick" {
+		t.Errorf("Expected handle is nick but was %s", handle)
+	}
+}
+
+func TestConfusableHandlesShareSkeleton(t *testing.T) {
+
+	// Operation
+	skeleton := domain.HandleSkeleton("N1ck")
+	anotherSkeleton := domain.HandleSkeleton("nick")
+
+	// Validation
+	if skeleton != anotherSkeleton {
+		t.Errorf("Expected skeletons to be equal but were %s and %s", skeleton, anotherSkeleton)
+	}
+}
@@ -0,0 +1,60 @@
+package domain
+
+import "strings"
+
+func Mentions(text string) []string {
+	return prefixedWords(text, '@')
+}
+
+// prefixedWords returns the words made of handle characters that follow the
+// prefix, ignoring prefixes in the middle of a word like in an email address
+func prefixedWords(text string, prefix rune) []string {
+
+	words := make([]string, 0)
+
+	runes := []rune(text)
+
+	for index := 0; index < len(runes); index++ {
+
+		if runes[index] != prefix || (index > 0 && isHandleCharacter(runes[index-1])) {
+			continue
+		}
+
+		end := index + 1
+		for end < len(runes) && isHandleCharacter(runes[end]) {
+			end++
+		}
+
+		if end > index+1 {
+			words = append(words, string(runes[index+1:end]))
+		}
+
+		index = end - 1
+	}
+
+	return words
+}
+
+func Hashtags(text string) []string {
+
+	hashtags := prefixedWords(text, '#')
+
+	for index, hashtag := range hashtags {
+		hashtags[index] = strings.ToLower(hashtag)
+	}
+
+	return hashtags
+}
+
+// RepliedUser returns the handle of the user the text replies to, which is
+// the one mentioned at the beginning of the text, or "" if it is not a reply
+func RepliedUser(text string) string {
+
+	mentions := Mentions(text)
+
+	if len(mentions) == 0 || !strings.HasPrefix(text, "@"+mentions[0]) {
+		return ""
+	}
+
+	return mentions[0]
+}
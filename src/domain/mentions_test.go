@@ -0,0 +1,48 @@
+package domain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+)
+
+func TestMentionsAreExtractedFromText(t *testing.T) {
+
+	// Operation
+	mentions := domain.Mentions("@nick look at this, @grupoesfera! write to me@mail.com")
+
+	// Validation
+	expectedMentions := []string{"nick", "grupoesfera"}
+	if !reflect.DeepEqual(mentions, expectedMentions) {
+		t.Errorf("Expected mentions are %v but were %v", expectedMentions, mentions)
+	}
+}
+
+func TestHashtagsAreExtractedLowercased(t *testing.T) {
+
+	// Operation
+	hashtags := domain.Hashtags("Learning #Golang at #ITAcademy")
+
+	// Validation
+	expectedHashtags := []string{"golang", "itacademy"}
+	if !reflect.DeepEqual(hashtags, expectedHashtags) {
+		t.Errorf("Expected hashtags are %v but were %v", expectedHashtags, hashtags)
+	}
+}
+
+func TestTextStartingWithAMentionIsAReply(t *testing.T) {
+
+	// Operation
+	repliedUser := domain.RepliedUser("@nick I agree with @grupoesfera")
+	notReplied := domain.RepliedUser("I agree with @nick")
+
+	// Validation
+	if repliedUser != "nick" {
+		t.Errorf("Expected replied user is nick but was %s", repliedUser)
+	}
+
+	if notReplied != "" {
+		t.Errorf("Expected no replied user but was %s", notReplied)
+	}
+}
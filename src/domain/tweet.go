@@ -5,6 +5,7 @@ import "fmt"
 
 type Tweet interface {
 	GetUser() string
+	SetUser(string)
 	GetText() string
 	GetDate() *time.Time
 	GetId() int
@@ -36,6 +37,10 @@ func (tweet *TextTweet) GetUser() string {
 	return tweet.User
 }
 
+func (tweet *TextTweet) SetUser(user string) {
+	tweet.User = user
+}
+
 func (tweet *TextTweet) GetText() string {
 	return tweet.Text
 }
@@ -85,6 +90,10 @@ func (tweet *ImageTweet) GetUser() string {
 	return tweet.User
 }
 
+func (tweet *ImageTweet) SetUser(user string) {
+	tweet.User = user
+}
+
 func (tweet *ImageTweet) GetText() string {
 	return tweet.Text
 }
@@ -134,6 +143,10 @@ func (tweet *QuoteTweet) GetUser() string {
 	return tweet.User
 }
 
+func (tweet *QuoteTweet) SetUser(user string) {
+	tweet.User = user
+}
+
 func (tweet *QuoteTweet) GetText() string {
 	return tweet.Text
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// StopFunc stops a part of the application, like a server or a writer,
+// finishing its pending work before the context is done
+type StopFunc func(ctx context.Context) error
+
+type stopper struct {
+	name string
+	stop StopFunc
+}
+
+// Lifecycle stops the parts of the application in the reverse order they
+// were started, when a signal arrives or when Stop is called
+type Lifecycle struct {
+	mutex    sync.Mutex
+	stoppers []stopper
+	stopping chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	err      error
+}
+
+func NewLifecycle() *Lifecycle {
+
+	lifecycle := new(Lifecycle)
+
+	lifecycle.stoppers = make([]stopper, 0)
+	lifecycle.stopping = make(chan struct{})
+	lifecycle.done = make(chan struct{})
+
+	return lifecycle
+}
+
+// OnStop registers how to stop a part of the application. Parts registered
+// later are stopped first, as they usually depend on the previous ones
+func (lifecycle *Lifecycle) OnStop(name string, stop StopFunc) {
+
+	lifecycle.mutex.Lock()
+	defer lifecycle.mutex.Unlock()
+
+	lifecycle.stoppers = append(lifecycle.stoppers, stopper{name, stop})
+}
+
+// Stop stops every registered part, even if some of them fail, and returns
+// their errors together. Only the first call stops them, the others wait
+// for it and return the same error
+func (lifecycle *Lifecycle) Stop(ctx context.Context) error {
+
+	lifecycle.once.Do(func() {
+
+		close(lifecycle.stopping)
+
+		lifecycle.mutex.Lock()
+		stoppers := lifecycle.stoppers
+		lifecycle.mutex.Unlock()
+
+		failures := make([]string, 0)
+
+		for n := len(stoppers) - 1; n >= 0; n-- {
+			if err := stoppers[n].stop(ctx); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", stoppers[n].name, err.Error()))
+			}
+		}
+
+		if len(failures) > 0 {
+			lifecycle.err = fmt.Errorf("shutdown failed: %s", strings.Join(failures, "; "))
+		}
+
+		close(lifecycle.done)
+	})
+
+	<-lifecycle.done
+
+	return lifecycle.err
+}
+
+// StopOnSignals calls stopped when SIGINT or SIGTERM arrive, so the caller
+// can stop what is waiting for input, like the shell. The signals are
+// handled until the lifecycle starts stopping
+func (lifecycle *Lifecycle) StopOnSignals(stopped func()) {
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+
+		defer signal.Stop(received)
+
+		select {
+		case <-received:
+			stopped()
+		case <-lifecycle.stopping:
+		}
+	}()
+}
+
+func (lifecycle *Lifecycle) Stopping() <-chan struct{} {
+	return lifecycle.stopping
+}
+
+func (lifecycle *Lifecycle) Done() <-chan struct{} {
+	return lifecycle.done
+}
+
+type tweetPipeline interface {
+	Flush(ctx context.Context) error
+	Close()
+}
+
+// stopPipeline writes the queued tweets of the pipeline and closes it. It
+// is closed even if the context is done first, so nothing is written to
+// the writers stopped after it
+func stopPipeline(pipeline tweetPipeline) StopFunc {
+
+	return func(ctx context.Context) error {
+
+		err := pipeline.Flush(ctx)
+
+		pipeline.Close()
+
+		return err
+	}
+}
+
+func ExitCode(err error) int {
+
+	if err != nil {
+		return 1
+	}
+
+	return 0
+}
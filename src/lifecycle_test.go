@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func TestLifecycleStopsInReverseOrderOnce(t *testing.T) {
+
+	// Initialization
+	lifecycle := NewLifecycle()
+
+	stopped := make([]string, 0)
+
+	for _, name := range []string{"store", "writer", "server"} {
+		name := name
+		lifecycle.OnStop(name, func(ctx context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		})
+	}
+
+	// Operation
+	first := lifecycle.Stop(context.Background())
+	second := lifecycle.Stop(context.Background())
+
+	// Validation
+	if first != nil || second != nil {
+		t.Errorf("Expected no errors but were %v and %v", first, second)
+	}
+
+	if strings.Join(stopped, ",") != "server,writer,store" {
+		t.Errorf("Expected every part to be stopped once in reverse order but were %v", stopped)
+	}
+
+	if code := ExitCode(first); code != 0 {
+		t.Errorf("Expected exit code 0 but was %d", code)
+	}
+}
+
+func TestLifecycleStopsEveryPartWhenOneFails(t *testing.T) {
+
+	// Initialization
+	lifecycle := NewLifecycle()
+
+	storeStopped := false
+
+	lifecycle.OnStop("store", func(ctx context.Context) error {
+		storeStopped = true
+		return nil
+	})
+
+	lifecycle.OnStop("server", func(ctx context.Context) error {
+		return fmt.Errorf("connections still open")
+	})
+
+	// Operation
+	err := lifecycle.Stop(context.Background())
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "server: connections still open") {
+		t.Errorf("Expected the error of the server but was %v", err)
+	}
+
+	if !storeStopped {
+		t.Errorf("Expected the store to be stopped after the server failed")
+	}
+
+	if code := ExitCode(err); code == 0 {
+		t.Errorf("Expected a failure exit code")
+	}
+}
+
+func TestLifecycleStopsOnSigterm(t *testing.T) {
+
+	// Initialization
+	lifecycle := NewLifecycle()
+
+	signalled := make(chan bool)
+
+	lifecycle.StopOnSignals(func() {
+		close(signalled)
+	})
+
+	// Operation
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	// Validation
+	select {
+	case <-signalled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected SIGTERM to be handled")
+	}
+
+	lifecycle.Stop(context.Background())
+
+	select {
+	case <-lifecycle.Done():
+	default:
+		t.Errorf("Expected the lifecycle to be done")
+	}
+}
+
+// blockingTweetWriter waits for release before every write
+type blockingTweetWriter struct {
+	release chan bool
+	writer  *service.MemoryTweetWriter
+}
+
+func (writer *blockingTweetWriter) WriteTweet(tweet domain.Tweet) error {
+	<-writer.release
+	return writer.writer.WriteTweet(tweet)
+}
+
+func TestLifecycleClosesThePipelineWhenTheFlushTimesOut(t *testing.T) {
+
+	// Initialization
+	blockingWriter := &blockingTweetWriter{make(chan bool), service.NewMemoryTweetWriter()}
+	tweetWriter := service.NewChannelTweetWriter(blockingWriter)
+
+	lifecycle := NewLifecycle()
+
+	writtenBeforeClosing := 0
+
+	lifecycle.OnStop("file", func(ctx context.Context) error {
+		writtenBeforeClosing = len(blockingWriter.writer.Tweets)
+		return nil
+	})
+
+	lifecycle.OnStop("tweet writer", stopPipeline(tweetWriter))
+
+	for n := 0; n < 3; n++ {
+		tweetWriter.Enqueue(context.Background(), domain.NewTextTweet("grupoesfera", fmt.Sprintf("Tweet %d", n)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(blockingWriter.release)
+	}()
+
+	// Operation
+	err := lifecycle.Stop(ctx)
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "tweet writer: context deadline exceeded") {
+		t.Errorf("Expected the flush to time out but was %v", err)
+	}
+
+	if writtenBeforeClosing != 3 {
+		t.Errorf("Expected the writer to be closed before the file but %d tweets were written", writtenBeforeClosing)
+	}
+}
@@ -1,104 +1,746 @@
 package rest
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/search"
 
 	"github.com/cursoGo/src/service"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/go-playground/validator.v8"
 )
 
 type GinTweet struct {
-	User string
+	User string `binding:"handle"`
 	Text string
 	URL  string
 	ID   int
 }
 
+type GinRename struct {
+	User    string `binding:"handle"`
+	NewUser string `binding:"handle"`
+}
+
+type GinFollow struct {
+	User     string `binding:"handle"`
+	Followed string `binding:"handle"`
+}
+
+type GinNotification struct {
+	User    string `binding:"handle"`
+	ID      int
+	Type    service.NotificationType
+	Enabled bool
+}
+
 type GinServer struct {
-	tweetManager *service.TweetManager
+	tweetManager          *service.TweetManager
+	recommendationService *service.RecommendationService
+	notificationService   *service.NotificationService
+	trendService          *service.TrendService
+	searchService         *search.SearchService
+	rankingService        *service.RankingService
+	tweetSinks            *service.MultiTweetWriter
+	deadLetters           *service.DeadLetterQueue
+	httpServer            *http.Server
+	apiTokens             map[string]bool
+	adminTokens           map[string]bool
+}
+
+// APITokensVariable is the environment variable with the bearer tokens of
+// the clients of the API, separated by commas. Only these tokens get their
+// own rate limit
+const APITokensVariable = "TWEETER_API_TOKENS"
+
+// AdminTokensVariable is the environment variable with the bearer tokens
+// allowed to use the /admin routes, separated by commas. Without tokens
+// the routes answer 401 to everyone
+const AdminTokensVariable = "TWEETER_ADMIN_TOKENS"
+
+type validationRegisterer interface {
+	RegisterValidation(string, validator.Func) error
+}
+
+func NewGinServer(tweetManager *service.TweetManager, recommendationService *service.RecommendationService,
+	notificationService *service.NotificationService, trendService *service.TrendService,
+	searchService *search.SearchService, rankingService *service.RankingService,
+	tweetSinks *service.MultiTweetWriter, deadLetters *service.DeadLetterQueue) *GinServer {
+
+	if registerer, ok := binding.Validator.(validationRegisterer); ok {
+		registerer.RegisterValidation("handle", isValidHandle)
+	}
+
+	return &GinServer{tweetManager, recommendationService, notificationService, trendService, searchService, rankingService,
+		tweetSinks, deadLetters, nil, make(map[string]bool), make(map[string]bool)}
+}
+
+func (server *GinServer) SetAPITokens(tokens []string) {
+	server.apiTokens = tokenSet(tokens)
+}
+
+func (server *GinServer) SetAdminTokens(tokens []string) {
+	server.adminTokens = tokenSet(tokens)
 }
 
-func NewGinServer(tweetManager *service.TweetManager) *GinServer {
-	return &GinServer{tweetManager}
+func tokenSet(tokens []string) map[string]bool {
+
+	set := make(map[string]bool, len(tokens))
+
+	for _, token := range tokens {
+		set[token] = true
+	}
+
+	return set
+}
+
+func ParseTokens(text string) []string {
+
+	tokens := make([]string, 0)
+
+	for _, token := range strings.Split(text, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
+
+func isValidHandle(v *validator.Validate, topStruct reflect.Value, currentStruct reflect.Value,
+	field reflect.Value, fieldType reflect.Type, fieldKind reflect.Kind, param string) bool {
+
+	return domain.ValidateHandle(field.String()) == nil
+}
+
+func bindTweet(c *gin.Context, tweetdata *GinTweet) bool {
+
+	if err := c.ShouldBind(tweetdata); err != nil {
+
+		if handleErr := domain.ValidateHandle(tweetdata.User); handleErr != nil {
+			err = handleErr
+		}
+
+		c.JSON(http.StatusBadRequest, "Invalid tweet "+err.Error())
+		return false
+	}
+
+	return true
 }
 
 func (server *GinServer) StartGinServer() {
 
 	router := gin.Default()
 
+	// The forwarded headers are set by the clients, who could pick a new IP
+	// for every request to skip its rate limit
+	router.ForwardedByClientIP = false
+
 	router.GET("/listTweets", server.listTweets)
-	router.GET("/listTweets/:user", server.listTweets)
+	router.GET("/listTweets/:user", server.getTweetsByUser)
 	router.POST("publishTweet", server.publishTweet)
 	router.POST("publishImageTweet", server.publishImageTweet)
 	router.POST("publishQuoteTweet", server.publishQuoteTweet)
+	router.POST("renameUser", server.renameUser)
+	router.POST("follow", server.follow)
+	router.POST("unfollow", server.unfollow)
+	router.GET("/recommendations/:user", server.getRecommendations)
+	router.POST("dismissRecommendation", server.dismissRecommendation)
+	router.POST("likeTweet", server.likeTweet)
+	router.POST("deleteTweet", server.deleteTweet)
+	router.GET("/mentions/:user", server.getMentions)
+	router.GET("/notifications/:user", server.getNotifications)
+	router.GET("/notifications/:user/unread", server.countUnreadNotifications)
+	router.POST("markNotificationsAsRead", server.markNotificationsAsRead)
+	router.POST("notificationPreferences", server.setNotificationPreference)
+	router.GET("/trends", server.getTrends)
+	router.GET("/search", server.search)
+	router.GET("/topTweets/:user", server.getTopTweets)
+	router.GET("/archive/:user", server.exportArchive)
+	router.POST("importArchive", server.importArchive)
+
+	admin := router.Group("/admin", server.requireAdmin)
+	admin.GET("/sinks", server.getSinks)
+	admin.GET("/deadLetters", server.getDeadLetters)
+	admin.POST("/deadLetters/replay", server.replayDeadLetters)
+
+	server.httpServer = &http.Server{Addr: ":8080", Handler: router}
+
+	go func() {
+		if err := server.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println("Error starting the HTTP server:", err)
+		}
+	}()
+}
+
+// Shutdown stops accepting requests and waits for the ones in flight to
+// finish, or for the context to be done
+func (server *GinServer) Shutdown(ctx context.Context) error {
 
-	go router.Run()
+	if server.httpServer == nil {
+		return nil
+	}
+
+	return server.httpServer.Shutdown(ctx)
 }
 
 func (server *GinServer) listTweets(c *gin.Context) {
 
-	c.JSON(http.StatusOK, server.tweetManager.GetTweets())
+	if server.listTweetsByDate(c, "") {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	page, err := server.tweetManager.GetTweetsPage(c.Query("cursor"), limit)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error listing tweets "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, page)
+	}
 }
 
 func (server *GinServer) getTweetsByUser(c *gin.Context) {
 
 	user := c.Param("user")
-	c.JSON(http.StatusOK, server.tweetManager.GetTweetsByUser(user))
+
+	handle := server.tweetManager.ResolveUser(user)
+
+	// The redirect is temporary, as the old handle is released after the
+	// grace period
+	if handle != domain.NormalizeHandle(user) {
+		location := "/listTweets/" + handle
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusTemporaryRedirect, location)
+		return
+	}
+
+	if server.listTweetsByDate(c, user) {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	page, err := server.tweetManager.GetTweetsByUserPage(user, c.Query("cursor"), limit)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error listing tweets "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, page)
+	}
 }
 
-func (server *GinServer) publishTweet(c *gin.Context) {
+// listTweetsByDate answers with the tweets of the from and to days, or the
+// ones published on the onThisDay day of every year, when those query
+// parameters are given. It returns whether the request was answered
+func (server *GinServer) listTweetsByDate(c *gin.Context, user string) bool {
 
-	quit := make(chan bool)
+	now := time.Now()
 
-	var tweetdata GinTweet
-	c.Bind(&tweetdata)
+	if onThisDay := c.Query("onThisDay"); onThisDay != "" {
 
-	tweetToPublish := domain.NewTextTweet(tweetdata.User, tweetdata.Text)
+		date, err := service.ParseDate(onThisDay, now)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, "Error listing tweets "+err.Error())
+		} else {
+			c.JSON(http.StatusOK, server.tweetManager.GetTweetsOnThisDay(date))
+		}
+
+		return true
+	}
+
+	if c.Query("from") == "" && c.Query("to") == "" {
+		return false
+	}
 
-	id, err := server.tweetManager.PublishTweet(tweetToPublish, quit)
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"), now)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, "Error publishing tweet "+err.Error())
+		c.JSON(http.StatusBadRequest, "Error listing tweets "+err.Error())
+	} else if user != "" {
+		c.JSON(http.StatusOK, server.tweetManager.GetTweetsByUserBetween(user, from, to))
 	} else {
-		c.JSON(http.StatusOK, struct{ Id int }{id})
+		c.JSON(http.StatusOK, server.tweetManager.GetTweetsBetween(from, to))
 	}
+
+	return true
 }
 
-func (server *GinServer) publishImageTweet(c *gin.Context) {
+// parseDateRange returns the range from the start of the from day to the
+// end of the to day. A missing from or to leaves that side open
+func parseDateRange(fromText, toText string, now time.Time) (time.Time, time.Time, error) {
 
-	quit := make(chan bool)
+	from := time.Time{}
+	to := time.Date(9999, time.December, 31, 0, 0, 0, 0, now.Location())
 
-	var tweetdata GinTweet
-	c.Bind(&tweetdata)
+	var err error
 
-	tweetToPublish := domain.NewImageTweet(tweetdata.User, tweetdata.Text, tweetdata.URL)
+	if fromText != "" {
+		if from, err = service.ParseDate(fromText, now); err != nil {
+			return from, to, err
+		}
+	}
 
-	id, err := server.tweetManager.PublishTweet(tweetToPublish, quit)
+	if toText != "" {
+		if to, err = service.ParseDate(toText, now); err != nil {
+			return from, to, err
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	return from, to, nil
+}
+
+func (server *GinServer) renameUser(c *gin.Context) {
+
+	var renamedata GinRename
+	if err := c.ShouldBind(&renamedata); err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid rename "+err.Error())
+		return
+	}
+
+	err := server.tweetManager.RenameUser(renamedata.User, renamedata.NewUser)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, "Error publishing tweet "+err.Error())
+		c.JSON(http.StatusBadRequest, "Error renaming user "+err.Error())
 	} else {
-		c.JSON(http.StatusOK, struct{ Id int }{id})
+		c.JSON(http.StatusOK, struct{ User string }{renamedata.NewUser})
 	}
 }
 
-func (server *GinServer) publishQuoteTweet(c *gin.Context) {
+func (server *GinServer) publishTweet(c *gin.Context) {
 
-	quit := make(chan bool)
+	var tweetdata GinTweet
+	if !bindTweet(c, &tweetdata) {
+		return
+	}
+
+	tweetToPublish := domain.NewTextTweet(tweetdata.User, tweetdata.Text)
+
+	server.publish(c, tweetToPublish)
+}
+
+func (server *GinServer) publishImageTweet(c *gin.Context) {
+
+	var tweetdata GinTweet
+	if !bindTweet(c, &tweetdata) {
+		return
+	}
+
+	tweetToPublish := domain.NewImageTweet(tweetdata.User, tweetdata.Text, tweetdata.URL)
+
+	server.publish(c, tweetToPublish)
+}
+
+func (server *GinServer) publishQuoteTweet(c *gin.Context) {
 
 	var tweetdata GinTweet
-	c.Bind(&tweetdata)
+	if !bindTweet(c, &tweetdata) {
+		return
+	}
 
 	quotedTweet := server.tweetManager.GetTweetById(tweetdata.ID)
 	tweetToPublish := domain.NewQuoteTweet(tweetdata.User, tweetdata.Text, quotedTweet)
 
-	id, err := server.tweetManager.PublishTweet(tweetToPublish, quit)
+	server.publish(c, tweetToPublish)
+}
 
-	if err != nil {
+// publish publishes the tweet and answers once it was written. When the
+// request is cancelled or the write takes longer than publishTimeout, the
+// tweet stays published and is answered as accepted. The tweets are limited
+// by user, client IP and bearer token
+func (server *GinServer) publish(c *gin.Context, tweetToPublish domain.Tweet) {
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), publishTimeout)
+	defer cancel()
+
+	ctx = service.WithPublishSource(ctx, server.publishSource(c))
+
+	id, err := server.tweetManager.PublishTweetAndWait(ctx, tweetToPublish)
+
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, struct{ Id int }{id})
+	case id > 0 && ctx.Err() != nil:
+		c.JSON(http.StatusAccepted, struct{ Id int }{id})
+	case err == service.ErrQueueFull:
+		c.JSON(http.StatusServiceUnavailable, "Error publishing tweet "+err.Error())
+	case isRateLimited(err):
+		rateLimitResponse(c, err.(*service.RateLimitError))
+	default:
 		c.JSON(http.StatusInternalServerError, "Error publishing tweet "+err.Error())
+	}
+}
+
+// publishSource returns who publishes through the request. Unknown tokens
+// are ignored, so a made up token doesn't get a new rate limit
+func (server *GinServer) publishSource(c *gin.Context) service.PublishSource {
+
+	source := service.PublishSource{Channel: service.RESTChannel, ClientIP: c.ClientIP()}
+
+	if token := bearerToken(c); server.apiTokens[token] {
+		source.Token = token
+	}
+
+	return source
+}
+
+func (server *GinServer) requireAdmin(c *gin.Context) {
+
+	if token := bearerToken(c); token == "" || !server.adminTokens[token] {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, "Error an admin token is required")
+	}
+}
+
+func bearerToken(c *gin.Context) string {
+
+	header := c.GetHeader("Authorization")
+
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+
+	return strings.TrimPrefix(header, "Bearer ")
+}
+
+func isRateLimited(err error) bool {
+	_, limited := err.(*service.RateLimitError)
+	return limited
+}
+
+// rateLimitResponse answers 429 with the state of the limit in the headers.
+// Retry-After is rounded up to whole seconds
+func rateLimitResponse(c *gin.Context, err *service.RateLimitError) {
+
+	retryAfter := int(math.Ceil(err.RetryAfter.Seconds()))
+
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.Header("X-RateLimit-Limit", strconv.Itoa(err.Burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(err.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(err.Reset.Unix(), 10))
+
+	c.JSON(http.StatusTooManyRequests, "Error "+err.Error())
+}
+
+func (server *GinServer) follow(c *gin.Context) {
+
+	var followdata GinFollow
+	if err := c.ShouldBind(&followdata); err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid follow "+err.Error())
+		return
+	}
+
+	err := server.tweetManager.Follow(followdata.User, followdata.Followed)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error following user "+err.Error())
 	} else {
-		c.JSON(http.StatusOK, struct{ Id int }{id})
+		c.JSON(http.StatusOK, server.tweetManager.GetFollowing(followdata.User))
+	}
+}
+
+func (server *GinServer) unfollow(c *gin.Context) {
+
+	var followdata GinFollow
+	if err := c.ShouldBind(&followdata); err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid unfollow "+err.Error())
+		return
+	}
+
+	err := server.tweetManager.Unfollow(followdata.User, followdata.Followed)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error unfollowing user "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, server.tweetManager.GetFollowing(followdata.User))
+	}
+}
+
+func (server *GinServer) getRecommendations(c *gin.Context) {
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	user := c.Param("user")
+	c.JSON(http.StatusOK, server.recommendationService.GetRecommendations(user, limit))
+}
+
+func (server *GinServer) dismissRecommendation(c *gin.Context) {
+
+	var followdata GinFollow
+	if err := c.ShouldBind(&followdata); err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid recommendation "+err.Error())
+		return
+	}
+
+	server.recommendationService.Dismiss(followdata.User, followdata.Followed)
+
+	c.JSON(http.StatusOK, struct{ Dismissed string }{followdata.Followed})
+}
+
+func (server *GinServer) likeTweet(c *gin.Context) {
+
+	var tweetdata GinTweet
+	if !bindTweet(c, &tweetdata) {
+		return
+	}
+
+	err := server.tweetManager.LikeTweet(tweetdata.User, tweetdata.ID)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error liking tweet "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, server.tweetManager.GetLikes(tweetdata.ID))
+	}
+}
+
+func (server *GinServer) deleteTweet(c *gin.Context) {
+
+	var tweetdata GinTweet
+	if !bindTweet(c, &tweetdata) {
+		return
+	}
+
+	err := server.tweetManager.DeleteTweet(tweetdata.User, tweetdata.ID)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error deleting tweet "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, struct{ Id int }{tweetdata.ID})
+	}
+}
+
+func (server *GinServer) getMentions(c *gin.Context) {
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	page, err := server.notificationService.GetMentionsPage(c.Param("user"), c.Query("cursor"), limit)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error listing mentions "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+func (server *GinServer) getNotifications(c *gin.Context) {
+
+	user := c.Param("user")
+	c.JSON(http.StatusOK, server.notificationService.GetNotifications(user))
+}
+
+func (server *GinServer) countUnreadNotifications(c *gin.Context) {
+
+	user := c.Param("user")
+	c.JSON(http.StatusOK, struct{ Unread int }{server.notificationService.CountUnread(user)})
+}
+
+func (server *GinServer) markNotificationsAsRead(c *gin.Context) {
+
+	var notificationdata GinNotification
+	if err := c.ShouldBind(&notificationdata); err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid notification "+err.Error())
+		return
+	}
+
+	if notificationdata.ID == 0 {
+		server.notificationService.MarkAllAsRead(notificationdata.User)
+	} else if err := server.notificationService.MarkAsRead(notificationdata.User, notificationdata.ID); err != nil {
+		c.JSON(http.StatusBadRequest, "Error marking notification as read "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, struct{ Unread int }{server.notificationService.CountUnread(notificationdata.User)})
+}
+
+func (server *GinServer) setNotificationPreference(c *gin.Context) {
+
+	var notificationdata GinNotification
+	if err := c.ShouldBind(&notificationdata); err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid notification preference "+err.Error())
+		return
+	}
+
+	err := server.notificationService.SetPreference(notificationdata.User, notificationdata.Type, notificationdata.Enabled)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error setting notification preference "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, server.notificationService.GetPreferences(notificationdata.User))
+	}
+}
+
+func (server *GinServer) getTrends(c *gin.Context) {
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	trends, err := server.trendService.GetTrends(c.DefaultQuery("window", "hour"), limit)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error getting trends "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, trends)
+	}
+}
+
+func (server *GinServer) search(c *gin.Context) {
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	results, err := server.searchService.Search(c.Query("q"), limit)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Invalid search "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, results)
+	}
+}
+
+func (server *GinServer) getTopTweets(c *gin.Context) {
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, "Invalid limit "+c.Query("limit"))
+		return
+	}
+
+	debug := c.Query("debug") == "true"
+
+	c.JSON(http.StatusOK, server.rankingService.GetTopTweets(c.Param("user"), limit, debug))
+}
+
+const publishTimeout = 5 * time.Second
+
+const maxArchiveSize = 32 << 20
+
+func (server *GinServer) exportArchive(c *gin.Context) {
+
+	user := c.Param("user")
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=\""+domain.NormalizeHandle(user)+"-archive.zip\"")
+
+	err := server.tweetManager.ExportArchive(user, c.Writer)
+
+	if err != nil && !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusBadRequest, "Error exporting archive "+err.Error())
+	}
+}
+
+func (server *GinServer) importArchive(c *gin.Context) {
+
+	archive, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize))
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "Error reading archive "+err.Error())
+		return
+	}
+
+	ctx := service.WithPublishSource(c.Request.Context(), server.publishSource(c))
+
+	ids, err := server.tweetManager.ImportArchive(ctx, bytes.NewReader(archive), int64(len(archive)))
+
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, ids)
+	case isRateLimited(err):
+		rateLimitResponse(c, err.(*service.RateLimitError))
+	default:
+		c.JSON(http.StatusBadRequest, "Error importing archive "+err.Error())
+	}
+}
+
+// getSinks answers 503 when a sink is unhealthy, so it can be used as a
+// health check
+func (server *GinServer) getSinks(c *gin.Context) {
+
+	statuses := server.tweetSinks.Status()
+
+	status := http.StatusOK
+
+	for _, sinkStatus := range statuses {
+		if !sinkStatus.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(status, statuses)
+}
+
+func (server *GinServer) getDeadLetters(c *gin.Context) {
+
+	letters, err := server.deadLetters.List()
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, "Error listing dead letters "+err.Error())
+	} else {
+		c.JSON(http.StatusOK, letters)
+	}
+}
+
+// replayDeadLetters answers how many dead letters were written, even when
+// some of them failed again
+func (server *GinServer) replayDeadLetters(c *gin.Context) {
+
+	replayed, err := server.deadLetters.Replay()
+
+	response := struct {
+		Replayed int
+		Error    string `json:",omitempty"`
+	}{Replayed: replayed}
+
+	if err != nil {
+		response.Error = err.Error()
+		c.JSON(http.StatusInternalServerError, response)
+	} else {
+		c.JSON(http.StatusOK, response)
 	}
 }
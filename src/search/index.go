@@ -0,0 +1,266 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	HighlightStart = "<em>"
+	HighlightEnd   = "</em>"
+)
+
+type Result struct {
+	Tweet   domain.Tweet
+	Score   float64
+	Snippet string
+}
+
+// Index is an inverted index from terms to the tweets that contain them. It
+// is safe for concurrent use
+type Index struct {
+	mutex       sync.RWMutex
+	postings    map[string]map[int]int
+	tweets      map[int]domain.Tweet
+	lengths     map[int]int
+	totalLength int
+}
+
+func NewIndex() *Index {
+
+	index := new(Index)
+
+	index.postings = make(map[string]map[int]int)
+	index.tweets = make(map[int]domain.Tweet)
+	index.lengths = make(map[int]int)
+
+	return index
+}
+
+func (index *Index) Add(tweet domain.Tweet) {
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	id := tweet.GetId()
+
+	if _, indexed := index.tweets[id]; indexed {
+		index.remove(id)
+	}
+
+	tokens := Tokenize(tweet.GetText())
+
+	for _, token := range tokens {
+
+		if index.postings[token.Term] == nil {
+			index.postings[token.Term] = make(map[int]int)
+		}
+
+		index.postings[token.Term][id]++
+	}
+
+	index.tweets[id] = tweet
+	index.lengths[id] = len(tokens)
+	index.totalLength += len(tokens)
+}
+
+func (index *Index) Remove(id int) {
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.remove(id)
+}
+
+func (index *Index) remove(id int) {
+
+	tweet, indexed := index.tweets[id]
+
+	if !indexed {
+		return
+	}
+
+	for _, token := range Tokenize(tweet.GetText()) {
+
+		delete(index.postings[token.Term], id)
+
+		if len(index.postings[token.Term]) == 0 {
+			delete(index.postings, token.Term)
+		}
+	}
+
+	index.totalLength -= index.lengths[id]
+	delete(index.lengths, id)
+	delete(index.tweets, id)
+}
+
+func (index *Index) Size() int {
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return len(index.tweets)
+}
+
+// Search returns up to limit tweets containing any of the terms of the query
+// ranked by BM25, the newest first when they are equally relevant
+func (index *Index) Search(query string, limit int) []Result {
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	scores := index.score(queryTerms(query))
+
+	results := make([]Result, 0, len(scores))
+
+	for id, score := range scores {
+		tweet := index.tweets[id]
+		results = append(results, Result{tweet, score, Highlight(tweet.GetText(), query)})
+	}
+
+	SortResults(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// Score returns the BM25 score of every tweet containing any of the terms
+func (index *Index) Score(terms []string) map[int]float64 {
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return index.score(terms)
+}
+
+func (index *Index) score(terms []string) map[int]float64 {
+
+	scores := make(map[int]float64)
+
+	if len(index.tweets) == 0 {
+		return scores
+	}
+
+	averageLength := float64(index.totalLength) / float64(len(index.tweets))
+	if averageLength == 0 {
+		averageLength = 1
+	}
+
+	for _, term := range terms {
+
+		postings := index.postings[term]
+
+		matching := float64(len(postings))
+		idf := math.Log(1 + (float64(len(index.tweets))-matching+0.5)/(matching+0.5))
+
+		for id, frequency := range postings {
+
+			tf := float64(frequency)
+			length := float64(index.lengths[id])
+
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/averageLength))
+		}
+	}
+
+	return scores
+}
+
+func (index *Index) Get(id int) domain.Tweet {
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return index.tweets[id]
+}
+
+func (index *Index) Matching(term string) map[int]bool {
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	ids := make(map[int]bool)
+
+	for id := range index.postings[term] {
+		ids[id] = true
+	}
+
+	return ids
+}
+
+func SortResults(results []Result) {
+
+	sort.Slice(results, func(i, j int) bool {
+
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		dateI, dateJ := results[i].Tweet.GetDate(), results[j].Tweet.GetDate()
+		if dateI != nil && dateJ != nil && !dateI.Equal(*dateJ) {
+			return dateI.After(*dateJ)
+		}
+
+		return results[i].Tweet.GetId() > results[j].Tweet.GetId()
+	})
+}
+
+// Highlight surrounds the words of the text that match the terms of the
+// query with HighlightStart and HighlightEnd
+func Highlight(text, query string) string {
+	return highlightTerms(text, queryTerms(query))
+}
+
+func highlightTerms(text string, normalizedTerms []string) string {
+
+	terms := make(map[string]bool)
+	for _, term := range normalizedTerms {
+		terms[term] = true
+	}
+
+	var snippet strings.Builder
+
+	last := 0
+
+	for _, token := range Tokenize(text) {
+
+		if !terms[token.Term] {
+			continue
+		}
+
+		snippet.WriteString(text[last:token.Start])
+		snippet.WriteString(HighlightStart)
+		snippet.WriteString(text[token.Start:token.End])
+		snippet.WriteString(HighlightEnd)
+
+		last = token.End
+	}
+
+	snippet.WriteString(text[last:])
+
+	return snippet.String()
+}
+
+func queryTerms(query string) []string {
+
+	terms := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, token := range Tokenize(query) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+
+	return terms
+}
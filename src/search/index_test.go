@@ -0,0 +1,102 @@
+package search_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/search"
+)
+
+func newIndexedTweet(index *search.Index, id int, text string, date time.Time) domain.Tweet {
+
+	tweet := domain.NewTextTweet("grupoesfera", text)
+	tweet.SetId(id)
+	tweet.Date = &date
+
+	index.Add(tweet)
+
+	return tweet
+}
+
+func TestSearchRanksByRelevance(t *testing.T) {
+
+	// Initialization
+	index := search.NewIndex()
+
+	now := time.Now()
+
+	newIndexedTweet(index, 1, "Learning Go at the course", now)
+	newIndexedTweet(index, 2, "Go go go! Learning Go is fun", now)
+	newIndexedTweet(index, 3, "Nothing to see here", now)
+
+	// Operation
+	results := index.Search("go", 10)
+
+	// Validation
+	if len(results) != 2 {
+		t.Errorf("Expected size is 2 but was %d", len(results))
+		return
+	}
+
+	if results[0].Tweet.GetId() != 2 {
+		t.Errorf("Expected first result is 2 but was %d", results[0].Tweet.GetId())
+	}
+}
+
+func TestEquallyRelevantResultsAreSortedByRecency(t *testing.T) {
+
+	// Initialization
+	index := search.NewIndex()
+
+	now := time.Now()
+
+	newIndexedTweet(index, 1, "Learning golang", now.Add(-time.Hour))
+	newIndexedTweet(index, 2, "Learning golang", now)
+
+	// Operation
+	results := index.Search("golang", 10)
+
+	// Validation
+	if len(results) != 2 || results[0].Tweet.GetId() != 2 {
+		t.Errorf("Expected the newest tweet first but was %v", results)
+	}
+}
+
+func TestSearchHighlightsMatchingWords(t *testing.T) {
+
+	// Initialization
+	index := search.NewIndex()
+
+	newIndexedTweet(index, 1, "Escuchando canciones de Gardel", time.Now())
+
+	// Operation
+	results := index.Search("canción", 10)
+
+	// Validation
+	if len(results) != 1 {
+		t.Errorf("Expected size is 1 but was %d", len(results))
+		return
+	}
+
+	expectedSnippet := "Escuchando <em>canciones</em> de Gardel"
+	if results[0].Snippet != expectedSnippet {
+		t.Errorf("Expected snippet is %s but was %s", expectedSnippet, results[0].Snippet)
+	}
+}
+
+func TestRemovedTweetsAreNotFound(t *testing.T) {
+
+	// Initialization
+	index := search.NewIndex()
+
+	newIndexedTweet(index, 1, "Learning golang", time.Now())
+
+	// Operation
+	index.Remove(1)
+
+	// Validation
+	if results := index.Search("golang", 10); len(results) != 0 {
+		t.Errorf("Expected no results but were %v", results)
+	}
+}
@@ -0,0 +1,304 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+const DateLayout = "2006-01-02"
+
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", err.Position, err.Message)
+}
+
+type Node interface {
+	Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool
+	String() string
+}
+
+type AndNode struct {
+	Nodes []Node
+}
+
+type OrNode struct {
+	Nodes []Node
+}
+
+type NotNode struct {
+	Node Node
+}
+
+// TermNode matches the tweets containing all the terms, consecutively if
+// it is a phrase
+type TermNode struct {
+	Text   string
+	Terms  []string
+	Phrase bool
+}
+
+type HashtagNode struct {
+	Hashtag string
+}
+
+type MentionNode struct {
+	User string
+}
+
+type FromNode struct {
+	User string
+}
+
+type HasNode struct {
+	Feature string
+}
+
+type TypeNode struct {
+	Type string
+}
+
+type SinceNode struct {
+	Date time.Time
+}
+
+// UntilNode matches the tweets published before the date
+type UntilNode struct {
+	Date time.Time
+}
+
+var hasFeatures = map[string]bool{"image": true, "quote": true, "mention": true, "hashtag": true}
+
+var tweetTypes = map[string]bool{"text": true, "image": true, "quote": true}
+
+// unsupportedOperators are common search operators that can't be answered
+// because tweets don't carry the data they need
+var unsupportedOperators = map[string]string{
+	"lang":  "tweets don't have a language",
+	"near":  "tweets don't have a location",
+	"to":    "tweets aren't addressed to an user, use @user instead",
+	"is":    "tweets don't have a status",
+	"likes": "use the likes of a tweet instead",
+}
+
+func (node *AndNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	for _, child := range node.Nodes {
+		if !child.Match(tweet, tweetManager) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (node *AndNode) String() string {
+	return "(and " + joinNodes(node.Nodes) + ")"
+}
+
+func (node *OrNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	for _, child := range node.Nodes {
+		if child.Match(tweet, tweetManager) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (node *OrNode) String() string {
+	return "(or " + joinNodes(node.Nodes) + ")"
+}
+
+func (node *NotNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+	return !node.Node.Match(tweet, tweetManager)
+}
+
+func (node *NotNode) String() string {
+	return "(not " + node.Node.String() + ")"
+}
+
+func (node *TermNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	tokens := Tokenize(tweet.GetText())
+
+	if node.Phrase {
+
+		for start := 0; start+len(node.Terms) <= len(tokens); start++ {
+
+			matches := true
+
+			for offset, term := range node.Terms {
+				if tokens[start+offset].Term != term {
+					matches = false
+					break
+				}
+			}
+
+			if matches {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	terms := make(map[string]bool)
+	for _, token := range tokens {
+		terms[token.Term] = true
+	}
+
+	for _, term := range node.Terms {
+		if !terms[term] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (node *TermNode) String() string {
+
+	if node.Phrase {
+		return fmt.Sprintf("%q", strings.Join(node.Terms, " "))
+	}
+
+	return strings.Join(node.Terms, " ")
+}
+
+func (node *HashtagNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	for _, hashtag := range domain.Hashtags(tweet.GetText()) {
+		if hashtag == node.Hashtag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (node *HashtagNode) String() string {
+	return "#" + node.Hashtag
+}
+
+func (node *MentionNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	user := tweetManager.ResolveUser(node.User)
+
+	for _, mention := range tweetManager.GetMentions(tweet) {
+		if mention == user {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (node *MentionNode) String() string {
+	return "@" + node.User
+}
+
+func (node *FromNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+	return domain.NormalizeHandle(tweet.GetUser()) == tweetManager.ResolveUser(node.User)
+}
+
+func (node *FromNode) String() string {
+	return "from:" + node.User
+}
+
+func (node *HasNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	switch node.Feature {
+	case "image":
+		imageTweet, ok := tweet.(*domain.ImageTweet)
+		return ok && imageTweet.URL != ""
+	case "quote":
+		quoteTweet, ok := tweet.(*domain.QuoteTweet)
+		return ok && quoteTweet.QuotedTweet != nil
+	case "mention":
+		return len(domain.Mentions(tweet.GetText())) > 0
+	case "hashtag":
+		return len(domain.Hashtags(tweet.GetText())) > 0
+	}
+
+	return false
+}
+
+func (node *HasNode) String() string {
+	return "has:" + node.Feature
+}
+
+func (node *TypeNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+
+	switch tweet.(type) {
+	case *domain.ImageTweet:
+		return node.Type == "image"
+	case *domain.QuoteTweet:
+		return node.Type == "quote"
+	}
+
+	return node.Type == "text"
+}
+
+func (node *TypeNode) String() string {
+	return "type:" + node.Type
+}
+
+func (node *SinceNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+	return tweet.GetDate() != nil && !tweet.GetDate().Before(node.Date)
+}
+
+func (node *SinceNode) String() string {
+	return "since:" + node.Date.Format(DateLayout)
+}
+
+func (node *UntilNode) Match(tweet domain.Tweet, tweetManager *service.TweetManager) bool {
+	return tweet.GetDate() != nil && tweet.GetDate().Before(node.Date)
+}
+
+func (node *UntilNode) String() string {
+	return "until:" + node.Date.Format(DateLayout)
+}
+
+// PositiveTerms returns the terms the matching tweets must contain, which
+// are the ones used to rank and highlight the results
+func PositiveTerms(node Node) []string {
+
+	terms := make([]string, 0)
+
+	switch node := node.(type) {
+	case *AndNode:
+		for _, child := range node.Nodes {
+			terms = append(terms, PositiveTerms(child)...)
+		}
+	case *OrNode:
+		for _, child := range node.Nodes {
+			terms = append(terms, PositiveTerms(child)...)
+		}
+	case *TermNode:
+		terms = append(terms, node.Terms...)
+	case *HashtagNode:
+		terms = append(terms, queryTerms(node.Hashtag)...)
+	}
+
+	return terms
+}
+
+func joinNodes(nodes []Node) string {
+
+	texts := make([]string, 0, len(nodes))
+
+	for _, node := range nodes {
+		texts = append(texts, node.String())
+	}
+
+	return strings.Join(texts, " ")
+}
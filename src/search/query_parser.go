@@ -0,0 +1,357 @@
+package search
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cursoGo/src/domain"
+)
+
+type queryTokenType int
+
+const (
+	wordToken queryTokenType = iota
+	phraseToken
+	notToken
+	orToken
+	openToken
+	closeToken
+	endToken
+)
+
+type queryToken struct {
+	tokenType queryTokenType
+	text      string
+	position  int
+}
+
+type queryParser struct {
+	tokens []queryToken
+	next   int
+}
+
+// ParseQuery parses a query like `from:nick #golang has:image -spam
+// since:2017-10-01 until:2017-11-01 type:quote`. Words are implicitly
+// joined with and, OR joins alternatives, - negates and parentheses group
+func ParseQuery(query string) (Node, error) {
+
+	tokens, err := lexQuery(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &queryParser{tokens: tokens}
+
+	if parser.peek().tokenType == endToken {
+		return nil, &SyntaxError{0, "empty query"}
+	}
+
+	node, err := parser.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if token := parser.peek(); token.tokenType != endToken {
+		return nil, &SyntaxError{token.position, "unexpected " + describeToken(token)}
+	}
+
+	return node, nil
+}
+
+func lexQuery(query string) ([]queryToken, error) {
+
+	tokens := make([]queryToken, 0)
+
+	runes := []rune(query)
+
+	for position := 0; position < len(runes); {
+
+		character := runes[position]
+
+		switch {
+
+		case unicode.IsSpace(character):
+			position++
+
+		case character == '(':
+			tokens = append(tokens, queryToken{openToken, "(", position})
+			position++
+
+		case character == ')':
+			tokens = append(tokens, queryToken{closeToken, ")", position})
+			position++
+
+		case character == '"':
+			end := position + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &SyntaxError{position, "unterminated phrase"}
+			}
+			tokens = append(tokens, queryToken{phraseToken, string(runes[position+1 : end]), position})
+			position = end + 1
+
+		case character == '-':
+			tokens = append(tokens, queryToken{notToken, "-", position})
+			position++
+
+		default:
+			end := position
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+
+			word := string(runes[position:end])
+
+			if word == "OR" {
+				tokens = append(tokens, queryToken{orToken, word, position})
+			} else {
+				tokens = append(tokens, queryToken{wordToken, word, position})
+			}
+
+			position = end
+		}
+	}
+
+	tokens = append(tokens, queryToken{endToken, "", len(runes)})
+
+	return tokens, nil
+}
+
+func isWordRune(character rune) bool {
+	return !unicode.IsSpace(character) && character != '(' && character != ')' && character != '"'
+}
+
+func (parser *queryParser) peek() queryToken {
+	return parser.tokens[parser.next]
+}
+
+func (parser *queryParser) advance() queryToken {
+
+	token := parser.tokens[parser.next]
+
+	if token.tokenType != endToken {
+		parser.next++
+	}
+
+	return token
+}
+
+func (parser *queryParser) parseOr() (Node, error) {
+
+	nodes := make([]Node, 0)
+
+	for {
+
+		node, err := parser.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+
+		if parser.peek().tokenType != orToken {
+			break
+		}
+
+		parser.advance()
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+
+	return &OrNode{nodes}, nil
+}
+
+func (parser *queryParser) parseAnd() (Node, error) {
+
+	nodes := make([]Node, 0)
+	start := parser.peek()
+
+	for {
+
+		token := parser.peek()
+
+		if token.tokenType == endToken || token.tokenType == closeToken || token.tokenType == orToken {
+			break
+		}
+
+		node, err := parser.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 && parser.peek() != start {
+		return nil, &SyntaxError{start.position, "search terms are too common"}
+	}
+
+	if len(nodes) == 0 {
+		return nil, &SyntaxError{start.position, "expected a search term before " + describeToken(start)}
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+
+	return &AndNode{nodes}, nil
+}
+
+func (parser *queryParser) parseUnary() (Node, error) {
+
+	token := parser.advance()
+
+	switch token.tokenType {
+
+	case notToken:
+		next := parser.peek()
+		if next.tokenType != wordToken && next.tokenType != phraseToken && next.tokenType != openToken {
+			return nil, &SyntaxError{token.position, "- must be followed by a search term"}
+		}
+
+		node, err := parser.parseUnary()
+
+		if err != nil || node == nil {
+			return nil, err
+		}
+
+		return &NotNode{node}, nil
+
+	case openToken:
+		node, err := parser.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if parser.peek().tokenType != closeToken {
+			return nil, &SyntaxError{token.position, "unclosed parenthesis"}
+		}
+
+		parser.advance()
+
+		return node, nil
+
+	case phraseToken:
+		terms := termsOf(token.text)
+		if len(terms) == 0 {
+			return nil, nil
+		}
+		return &TermNode{token.text, terms, true}, nil
+
+	case wordToken:
+		return parseWord(token)
+	}
+
+	return nil, &SyntaxError{token.position, "unexpected " + describeToken(token)}
+}
+
+// parseWord turns a word into an operator, a hashtag, a mention or terms.
+// Words that are only stopwords return a nil node
+func parseWord(token queryToken) (Node, error) {
+
+	word := token.text
+
+	if strings.HasPrefix(word, "#") && len(word) > 1 {
+		return &HashtagNode{strings.ToLower(word[1:])}, nil
+	}
+
+	if strings.HasPrefix(word, "@") && len(word) > 1 {
+		return &MentionNode{domain.NormalizeHandle(word)}, nil
+	}
+
+	separator := strings.Index(word, ":")
+
+	if separator > 0 && !strings.Contains(word, "://") {
+
+		operator, value := word[:separator], word[separator+1:]
+		valuePosition := token.position + len([]rune(operator)) + 1
+
+		if reason, unsupported := unsupportedOperators[operator]; unsupported {
+			return nil, &SyntaxError{token.position, "operator " + operator + " is not supported, " + reason}
+		}
+
+		if isOperator(operator) && value == "" {
+			return nil, &SyntaxError{valuePosition, "operator " + operator + " needs a value"}
+		}
+
+		switch operator {
+
+		case "from":
+			if err := domain.ValidateHandle(value); err != nil {
+				return nil, &SyntaxError{valuePosition, err.Error()}
+			}
+			return &FromNode{domain.NormalizeHandle(value)}, nil
+
+		case "has":
+			if !hasFeatures[value] {
+				return nil, &SyntaxError{valuePosition, "has must be image, quote, mention or hashtag"}
+			}
+			return &HasNode{value}, nil
+
+		case "type":
+			if !tweetTypes[value] {
+				return nil, &SyntaxError{valuePosition, "type must be text, image or quote"}
+			}
+			return &TypeNode{value}, nil
+
+		case "since", "until":
+			date, err := time.ParseInLocation(DateLayout, value, time.Local)
+			if err != nil {
+				return nil, &SyntaxError{valuePosition, "date must have the format YYYY-MM-DD"}
+			}
+			if operator == "since" {
+				return &SinceNode{date}, nil
+			}
+			return &UntilNode{date}, nil
+		}
+	}
+
+	terms := termsOf(word)
+
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	return &TermNode{word, terms, false}, nil
+}
+
+func isOperator(operator string) bool {
+	return operator == "from" || operator == "has" || operator == "type" || operator == "since" || operator == "until"
+}
+
+func termsOf(text string) []string {
+
+	terms := make([]string, 0)
+
+	for _, token := range Tokenize(text) {
+		terms = append(terms, token.Term)
+	}
+
+	return terms
+}
+
+func describeToken(token queryToken) string {
+
+	switch token.tokenType {
+	case endToken:
+		return "end of query"
+	case orToken:
+		return "OR"
+	case closeToken:
+		return ")"
+	}
+
+	return token.text
+}
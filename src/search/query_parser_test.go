@@ -0,0 +1,67 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/cursoGo/src/search"
+)
+
+func TestQueryIsParsedIntoAnAST(t *testing.T) {
+
+	// Initialization
+	queries := map[string]string{
+		"from:nick #golang has:image -spam since:2017-10-01 until:2017-11-01 type:quote": "(and from:nick #golang has:image (not spam) since:2017-10-01 until:2017-11-01 type:quote)",
+		"golang OR (rust -java)":    "(or golang (and rust (not java)))",
+		`"learning go" @Nick`:       `(and "learn go" @nick)`,
+		"the tweets of grupoesfera": "(and tweet grupoesfera)",
+	}
+
+	for query, expectedAST := range queries {
+
+		// Operation
+		node, err := search.ParseQuery(query)
+
+		// Validation
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %s", query, err)
+			continue
+		}
+
+		if node.String() != expectedAST {
+			t.Errorf("Expected AST of %s is %s but was %s", query, expectedAST, node)
+		}
+	}
+}
+
+func TestInvalidQueriesReturnSyntaxErrors(t *testing.T) {
+
+	// Initialization
+	queries := map[string]string{
+		"":                "syntax error at position 0: empty query",
+		"golang OR":       "syntax error at position 9: expected a search term before end of query",
+		"(golang":         "syntax error at position 0: unclosed parenthesis",
+		"golang)":         "syntax error at position 6: unexpected )",
+		`"learning go`:    "syntax error at position 0: unterminated phrase",
+		"since:yesterday": "syntax error at position 6: date must have the format YYYY-MM-DD",
+		"has:video":       "syntax error at position 4: has must be image, quote, mention or hashtag",
+		"golang lang:es":  "syntax error at position 7: operator lang is not supported, tweets don't have a language",
+		"from:":           "syntax error at position 5: operator from needs a value",
+		"golang - ":       "syntax error at position 7: - must be followed by a search term",
+	}
+
+	for query, expectedError := range queries {
+
+		// Operation
+		_, err := search.ParseQuery(query)
+
+		// Validation
+		if err == nil {
+			t.Errorf("Expected error parsing %s", query)
+			continue
+		}
+
+		if err.Error() != expectedError {
+			t.Errorf("Expected error of %s is %s but was %s", query, expectedError, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+type SearchService struct {
+	tweetManager *service.TweetManager
+	index        *Index
+}
+
+func NewSearchService(tweetManager *service.TweetManager) *SearchService {
+
+	searchService := new(SearchService)
+
+	searchService.tweetManager = tweetManager
+	searchService.index = NewIndex()
+
+	for _, tweet := range tweetManager.GetTweets() {
+		searchService.index.Add(tweet)
+	}
+
+	tweetManager.Events().SubscribeSync("search", searchService.handleEvent, service.TweetPublished{}, service.TweetDeleted{})
+
+	return searchService
+}
+
+// Search returns up to limit tweets matching the query, the most relevant
+// first. See ParseQuery for the syntax of the query
+func (searchService *SearchService) Search(query string, limit int) ([]Result, error) {
+
+	node, err := ParseQuery(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	terms := PositiveTerms(node)
+	scores := searchService.index.Score(terms)
+
+	results := make([]Result, 0)
+
+	for _, tweet := range searchService.candidates(node) {
+		if node.Match(tweet, searchService.tweetManager) {
+			results = append(results, Result{tweet, scores[tweet.GetId()], highlightTerms(tweet.GetText(), terms)})
+		}
+	}
+
+	SortResults(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func (searchService *SearchService) candidates(node Node) []domain.Tweet {
+
+	if ids, found := searchService.candidateIds(node); found {
+
+		tweets := make([]domain.Tweet, 0, len(ids))
+
+		for id := range ids {
+			if tweet := searchService.index.Get(id); tweet != nil {
+				tweets = append(tweets, tweet)
+			}
+		}
+
+		return tweets
+	}
+
+	return searchService.tweetManager.GetTweets()
+}
+
+// candidateIds returns the ids of the tweets that may match the node, or
+// false if the node can't be answered with the indexes
+func (searchService *SearchService) candidateIds(node Node) (map[int]bool, bool) {
+
+	switch node := node.(type) {
+
+	case *TermNode:
+		return searchService.matchingAll(node.Terms), true
+
+	case *HashtagNode:
+		return searchService.matchingAll(queryTerms(node.Hashtag)), true
+
+	case *FromNode:
+		ids := make(map[int]bool)
+		for _, tweet := range searchService.tweetManager.GetTweetsByUser(node.User) {
+			ids[tweet.GetId()] = true
+		}
+		return ids, true
+
+	case *AndNode:
+		var smallest map[int]bool
+		for _, child := range node.Nodes {
+			if ids, found := searchService.candidateIds(child); found && (smallest == nil || len(ids) < len(smallest)) {
+				smallest = ids
+			}
+		}
+		return smallest, smallest != nil
+
+	case *OrNode:
+		union := make(map[int]bool)
+		for _, child := range node.Nodes {
+			ids, found := searchService.candidateIds(child)
+			if !found {
+				return nil, false
+			}
+			for id := range ids {
+				union[id] = true
+			}
+		}
+		return union, true
+	}
+
+	return nil, false
+}
+
+func (searchService *SearchService) matchingAll(terms []string) map[int]bool {
+
+	var ids map[int]bool
+
+	for _, term := range terms {
+
+		matching := searchService.index.Matching(term)
+
+		if ids == nil {
+			ids = matching
+			continue
+		}
+
+		for id := range ids {
+			if !matching[id] {
+				delete(ids, id)
+			}
+		}
+	}
+
+	if ids == nil {
+		ids = make(map[int]bool)
+	}
+
+	return ids
+}
+
+func (searchService *SearchService) handleEvent(event service.Event) error {
+
+	switch event := event.(type) {
+	case service.TweetPublished:
+		searchService.index.Add(event.Tweet)
+	case service.TweetDeleted:
+		searchService.index.Remove(event.Tweet.GetId())
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package search_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/search"
+	"github.com/cursoGo/src/service"
+)
+
+func TestPublishedTweetsAreSearchable(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	searchService := search.NewSearchService(tweetManager)
+
+	ctx := context.Background()
+
+	// Operation
+	id, _ := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Publishing tweets in Go"))
+
+	// Validation
+	results, err := searchService.Search("publish", 10)
+
+	if err != nil || len(results) != 1 || results[0].Tweet.GetId() != id {
+		t.Errorf("Expected the published tweet to be found but was %v", results)
+	}
+}
+
+func TestDeletedTweetsAreNotSearchable(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	searchService := search.NewSearchService(tweetManager)
+
+	id, _ := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "Publishing tweets in Go"))
+
+	// Operation
+	tweetManager.DeleteTweet("grupoesfera", id)
+
+	// Validation
+	results, err := searchService.Search("publish", 10)
+
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected the deleted tweet not to be found but was %v", results)
+	}
+}
+
+func TestSearchFiltersWithOperators(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	searchService := search.NewSearchService(tweetManager)
+
+	ctx := context.Background()
+
+	quoted := domain.NewTextTweet("grupoesfera", "Learning #golang")
+	tweetManager.PublishTweet(ctx, quoted)
+
+	imageId, _ := tweetManager.PublishTweet(ctx, domain.NewImageTweet("nick", "My #golang gopher", "http://gopher.png"))
+	quoteId, _ := tweetManager.PublishTweet(ctx, domain.NewQuoteTweet("nick", "Me too #golang", quoted))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "Buy now #golang spam"))
+
+	queries := map[string]int{
+		"from:nick #golang has:image -spam": imageId,
+		"from:nick type:quote":              quoteId,
+		"(gopher OR \"me too\") -has:image": quoteId,
+	}
+
+	for query, expectedId := range queries {
+
+		// Operation
+		results, err := searchService.Search(query, 10)
+
+		// Validation
+		if err != nil {
+			t.Errorf("Unexpected error searching %s: %s", query, err)
+			continue
+		}
+
+		if len(results) != 1 || results[0].Tweet.GetId() != expectedId {
+			t.Errorf("Expected %s to find tweet %d but found %v", query, expectedId, results)
+		}
+	}
+}
+
+func TestSearchFiltersByDate(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	searchService := search.NewSearchService(tweetManager)
+
+	ctx := context.Background()
+
+	oldTweet := domain.NewTextTweet("grupoesfera", "Old tweet")
+	date := time.Date(2017, 10, 15, 10, 0, 0, 0, time.Local)
+	oldTweet.Date = &date
+
+	oldId, _ := tweetManager.PublishTweet(ctx, oldTweet)
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "New tweet"))
+
+	// Operation
+	results, err := searchService.Search("tweet since:2017-10-01 until:2017-11-01", 10)
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	if len(results) != 1 || results[0].Tweet.GetId() != oldId {
+		t.Errorf("Expected to find tweet %d but found %v", oldId, results)
+	}
+}
+
+func TestTweetsCanBeSearchedWhileTheyArePublished(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	searchService := search.NewSearchService(tweetManager)
+
+	ctx := context.Background()
+	done := make(chan bool)
+
+	// Operation
+	go func() {
+		defer close(done)
+		for n := 0; n < 100; n++ {
+			id, _ := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Publishing tweets in Go"))
+			if n%2 == 0 {
+				tweetManager.DeleteTweet("grupoesfera", id)
+			}
+		}
+	}()
+
+	for searching := true; searching; {
+		select {
+		case <-done:
+			searching = false
+		default:
+			searchService.Search("publish", 10)
+		}
+	}
+
+	// Validation
+	results, err := searchService.Search("publish", 100)
+
+	if err != nil || len(results) != 50 {
+		t.Errorf("Expected the 50 tweets not deleted to be found but were %d", len(results))
+	}
+}
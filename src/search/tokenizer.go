@@ -0,0 +1,107 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+var accents = map[rune]rune{
+	'á': 'a', 'à': 'a', 'ä': 'a', 'â': 'a', 'ã': 'a',
+	'é': 'e', 'è': 'e', 'ë': 'e', 'ê': 'e',
+	'í': 'i', 'ì': 'i', 'ï': 'i', 'î': 'i',
+	'ó': 'o', 'ò': 'o', 'ö': 'o', 'ô': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'ü': 'u', 'û': 'u',
+	'ñ': 'n', 'ç': 'c',
+}
+
+var stopwords = map[string]bool{
+	// English
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "if": true, "in": true, "into": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "this": true, "that": true,
+	"to": true, "was": true, "with": true, "my": true, "i": true,
+	// Spanish
+	"el": true, "la": true, "los": true, "las": true, "un": true, "una": true, "unos": true,
+	"unas": true, "y": true, "o": true, "de": true, "del": true, "al": true, "en": true,
+	"que": true, "es": true, "por": true, "con": true, "para": true, "se": true, "su": true,
+	"lo": true, "mi": true, "me": true,
+}
+
+// suffixes are removed by the stemmer, the longest first. English and
+// Spanish suffixes are mixed since tweets don't declare their language
+var suffixes = []string{
+	"amientos", "imientos", "amiento", "imiento", "aciones", "uciones",
+	"ations", "mente", "acion", "ucion", "ation", "iendo", "ando", "ness",
+	"ment", "able", "ible", "ados", "idos", "adas", "idas", "ing", "ado",
+	"ido", "ada", "ida", "ies", "ed", "ly", "es", "os", "as", "s",
+}
+
+const minStemLength = 3
+
+// Tokenize splits the text into normalized terms: lowercased, without
+// accents, without stopwords and stemmed
+func Tokenize(text string) []Token {
+
+	tokens := make([]Token, 0)
+
+	start := -1
+
+	for index, character := range text + " " {
+
+		isWordCharacter := unicode.IsLetter(character) || unicode.IsDigit(character)
+
+		if isWordCharacter && start < 0 {
+			start = index
+		}
+
+		if !isWordCharacter && start >= 0 {
+
+			if term := NormalizeTerm(text[start:index]); term != "" {
+				tokens = append(tokens, Token{term, start, index})
+			}
+
+			start = -1
+		}
+	}
+
+	return tokens
+}
+
+// NormalizeTerm returns the term as it is indexed, or "" for stopwords
+func NormalizeTerm(word string) string {
+
+	term := FoldAccents(strings.ToLower(word))
+
+	if stopwords[term] {
+		return ""
+	}
+
+	return Stem(term)
+}
+
+func FoldAccents(text string) string {
+
+	return strings.Map(func(character rune) rune {
+		if folded, found := accents[character]; found {
+			return folded
+		}
+		return character
+	}, text)
+}
+
+func Stem(term string) string {
+
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(term, suffix) && len(term)-len(suffix) >= minStemLength {
+			return strings.TrimSuffix(term, suffix)
+		}
+	}
+
+	return term
+}
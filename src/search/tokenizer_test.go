@@ -0,0 +1,47 @@
+package search_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cursoGo/src/search"
+)
+
+func TestTokenizeFoldsAccentsAndRemovesStopwords(t *testing.T) {
+
+	// Operation
+	tokens := search.Tokenize("La canción de Gonzalo")
+
+	// Validation
+	expected := []search.Token{
+		{Term: "cancion", Start: 3, End: 11},
+		{Term: "gonzalo", Start: 15, End: 22},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected tokens are %v but were %v", expected, tokens)
+	}
+}
+
+func TestStemmingMatchesEnglishAndSpanishVariants(t *testing.T) {
+
+	// Initialization
+	variants := map[string]string{
+		"programming": "programm",
+		"programmed":  "programm",
+		"tweets":      "tweet",
+		"canciones":   "cancion",
+		"rápidamente": "rapida",
+		"corriendo":   "corr",
+	}
+
+	for word, expectedTerm := range variants {
+
+		// Operation
+		term := search.NormalizeTerm(word)
+
+		// Validation
+		if term != expectedTerm {
+			t.Errorf("Expected term of %s is %s but was %s", word, expectedTerm, term)
+		}
+	}
+}
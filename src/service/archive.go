@@ -0,0 +1,390 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const ArchiveVersion = 1
+
+const (
+	archiveProfileFile = "profile.json"
+	archiveTweetsFile  = "tweets.json"
+	archiveImagesFile  = "images.json"
+	archiveLikesFile   = "likes.json"
+	archiveIndexFile   = "index.html"
+)
+
+// maxArchiveFileSize bounds the size of a file of an archive once
+// decompressed, so a small archive can't take all the memory
+const maxArchiveFileSize = 64 << 20
+
+type ArchiveProfile struct {
+	Version    int
+	User       string
+	Following  []string
+	Followers  []string
+	ExportedAt time.Time
+}
+
+// ArchiveTweet is a tweet of the user. Quotes keep the id of the quoted
+// tweet, along with its user and text when it isn't in the archive
+type ArchiveTweet struct {
+	Id         int
+	Kind       string
+	Text       string
+	Date       time.Time
+	URL        string `json:",omitempty"`
+	QuotedId   int    `json:",omitempty"`
+	QuotedUser string `json:",omitempty"`
+	QuotedText string `json:",omitempty"`
+	Likes      []string
+}
+
+type ArchiveImage struct {
+	TweetId int
+	URL     string
+}
+
+type ArchiveLike struct {
+	TweetId int
+	User    string
+	Text    string
+}
+
+var archiveIndexTemplate = template.Must(template.New(archiveIndexFile).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Archive of @{{.Profile.User}}</title>
+</head>
+<body>
+<h1>@{{.Profile.User}}</h1>
+<p>Exported on {{.Profile.ExportedAt.Format "2006-01-02 15:04"}}. Following {{len .Profile.Following}}, followed by {{len .Profile.Followers}}.</p>
+<h2>Tweets</h2>
+<ol>
+{{range .Tweets}}<li id="tweet-{{.Id}}">
+<p>{{.Text}}</p>
+{{if .URL}}<p><a href="{{.URL}}"><img src="{{.URL}}" alt="Image of tweet {{.Id}}" width="200"></a></p>{{end}}
+{{if .QuotedUser}}<blockquote>@{{.QuotedUser}}: {{.QuotedText}}</blockquote>{{else if .QuotedId}}<p><a href="#tweet-{{.QuotedId}}">Quoted tweet</a></p>{{end}}
+<p><small>{{.Date.Format "2006-01-02 15:04"}} · {{len .Likes}} likes</small></p>
+</li>
+{{end}}</ol>
+<h2>Likes</h2>
+<ul>
+{{range .Likes}}<li>@{{.User}}: {{.Text}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// ExportArchive writes a zip with the profile, tweets, images and likes of
+// the user as JSON files and an HTML index to browse them. The zip is
+// written as it is built, so it can be streamed
+func (manager *TweetManager) ExportArchive(user string, writer io.Writer) error {
+
+	handle := manager.ResolveUser(user)
+
+	if !manager.IsRegistered(handle) {
+		return fmt.Errorf("user %s does not exist", user)
+	}
+
+	profile := ArchiveProfile{
+		Version:    ArchiveVersion,
+		User:       handle,
+		Following:  manager.GetFollowing(handle),
+		Followers:  manager.GetFollowers(handle),
+		ExportedAt: manager.now(),
+	}
+
+	userTweets := manager.GetTweetsByUser(handle)
+
+	archived := make(map[int]bool, len(userTweets))
+	for _, tweet := range userTweets {
+		archived[tweet.GetId()] = true
+	}
+
+	tweets := make([]ArchiveTweet, 0, len(userTweets))
+	images := make([]ArchiveImage, 0)
+
+	for _, tweet := range userTweets {
+
+		record := newTweetRecord(tweet)
+
+		archiveTweet := ArchiveTweet{
+			Id:       record.Id,
+			Kind:     record.Kind,
+			Text:     record.Text,
+			Date:     record.Date,
+			URL:      record.URL,
+			QuotedId: record.QuotedId,
+			Likes:    manager.GetLikes(record.Id),
+		}
+
+		if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok && quoteTweet.QuotedTweet != nil && !archived[record.QuotedId] {
+			archiveTweet.QuotedUser = quoteTweet.QuotedTweet.GetUser()
+			archiveTweet.QuotedText = quoteTweet.QuotedTweet.GetText()
+		}
+
+		if record.URL != "" {
+			images = append(images, ArchiveImage{record.Id, record.URL})
+		}
+
+		tweets = append(tweets, archiveTweet)
+	}
+
+	likes := make([]ArchiveLike, 0)
+
+	manager.mutex.RLock()
+
+	for id, users := range manager.likes {
+		if tweet := manager.tweetById(id); users[handle] && tweet != nil {
+			likes = append(likes, ArchiveLike{id, tweet.GetUser(), tweet.GetText()})
+		}
+	}
+
+	manager.mutex.RUnlock()
+
+	sort.Slice(likes, func(i, j int) bool {
+		return likes[i].TweetId < likes[j].TweetId
+	})
+
+	archive := zip.NewWriter(writer)
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{archiveProfileFile, profile},
+		{archiveTweetsFile, tweets},
+		{archiveImagesFile, images},
+		{archiveLikesFile, likes},
+	}
+
+	for _, file := range files {
+
+		fileWriter, err := archive.Create(file.name)
+
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(fileWriter)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(file.content); err != nil {
+			return err
+		}
+	}
+
+	indexWriter, err := archive.Create(archiveIndexFile)
+
+	if err != nil {
+		return err
+	}
+
+	err = archiveIndexTemplate.Execute(indexWriter, struct {
+		Profile ArchiveProfile
+		Tweets  []ArchiveTweet
+		Likes   []ArchiveLike
+	}{profile, tweets, likes})
+
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// ImportArchive publishes the tweets of an archive as its user, which must
+// not exist yet, returning the new id of every archived tweet. Likes point
+// to tweets of the instance the archive was exported from, so they aren't
+// imported. With a PublishSource in the context the whole archive counts
+// against the daily quota of the user
+func (manager *TweetManager) ImportArchive(ctx context.Context, reader io.ReaderAt, size int64) (ids map[int]int, err error) {
+
+	archive, err := zip.NewReader(reader, size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var profile ArchiveProfile
+	var tweets []ArchiveTweet
+
+	if err := readArchiveFile(archive, archiveProfileFile, &profile); err != nil {
+		return nil, err
+	}
+
+	if profile.Version != ArchiveVersion {
+		return nil, fmt.Errorf("archive version %d is not supported", profile.Version)
+	}
+
+	if err := domain.ValidateHandle(profile.User); err != nil {
+		return nil, err
+	}
+
+	if err := readArchiveFile(archive, archiveTweetsFile, &tweets); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[i].Id < tweets[j].Id
+	})
+
+	// Every tweet is validated first, so an invalid archive imports nothing
+	importedTweets := make([]domain.Tweet, 0, len(tweets))
+
+	for _, archiveTweet := range tweets {
+
+		tweet, err := archiveTweet.tweet(profile.User)
+
+		if err == nil {
+			err = validateTweet(tweet)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("tweet %d couldn't be imported: %s", archiveTweet.Id, err.Error())
+		}
+
+		importedTweets = append(importedTweets, tweet)
+	}
+
+	// The archive is one request for the limits of the source, and is
+	// rejected when its tweets would exceed the daily quota of the user
+	limiter, source := manager.limiterOf(ctx)
+
+	if limiter != nil {
+
+		if err := limiter.allow(source, manager.ResolveUser(profile.User), len(tweets)); err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			if err != nil {
+				limiter.refund(source, manager.ResolveUser(profile.User), len(tweets)-len(ids))
+			}
+		}()
+	}
+
+	manager.mutex.Lock()
+	user, err := manager.registerNewUser(profile.User)
+	manager.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids = make(map[int]int, len(tweets))
+
+	var lastAck *WriteAck
+
+	for index, archiveTweet := range tweets {
+
+		tweet := importedTweets[index]
+		tweet.SetUser(user)
+
+		if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok {
+			if newId, found := ids[archiveTweet.QuotedId]; found {
+				quoteTweet.QuotedTweet = manager.GetTweetById(newId)
+			}
+		}
+
+		id, ack, err := manager.publishTweet(ctx, tweet)
+
+		if err != nil {
+			return ids, fmt.Errorf("tweet %d couldn't be imported: %s", archiveTweet.Id, err.Error())
+		}
+
+		ids[archiveTweet.Id] = id
+		lastAck = ack
+	}
+
+	// Tweets are written in order, so the others were written before the last
+	if lastAck != nil {
+		if err := lastAck.Wait(ctx); err != nil {
+			return ids, err
+		}
+	}
+
+	for _, followed := range profile.Following {
+		manager.Follow(user, followed)
+	}
+
+	return ids, nil
+}
+
+// tweet builds the tweet to import. Quotes of a tweet outside the archive
+// get it as a detached tweet, which has no id, and the others have to be
+// linked to the imported quoted tweet
+func (archiveTweet ArchiveTweet) tweet(user string) (domain.Tweet, error) {
+
+	record := tweetRecord{
+		Kind: archiveTweet.Kind,
+		User: user,
+		Text: archiveTweet.Text,
+		Date: archiveTweet.Date,
+		URL:  archiveTweet.URL,
+	}
+
+	tweet, err := record.tweet()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok && archiveTweet.QuotedUser != "" {
+		quoteTweet.QuotedTweet = detachedTweet(archiveTweet.QuotedUser, archiveTweet.QuotedText, archiveTweet.Date)
+	}
+
+	return tweet, nil
+}
+
+func readArchiveFile(archive *zip.Reader, name string, content interface{}) error {
+
+	for _, file := range archive.File {
+
+		if file.Name != name {
+			continue
+		}
+
+		if file.UncompressedSize64 > maxArchiveFileSize {
+			return fmt.Errorf("archive file %s is larger than %d bytes", name, maxArchiveFileSize)
+		}
+
+		reader, err := file.Open()
+
+		if err != nil {
+			return err
+		}
+
+		defer reader.Close()
+
+		if err := json.NewDecoder(io.LimitReader(reader, maxArchiveFileSize)).Decode(content); err != nil {
+			return fmt.Errorf("archive file %s is invalid: %s", name, err.Error())
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("archive has no %s", name)
+}
+
+// registerNewUser registers the user of an archive, failing if it already
+// exists so nobody can publish as another user by importing an archive
+func (manager *TweetManager) registerNewUser(user string) (string, error) {
+
+	if handle := domain.NormalizeHandle(user); manager.isRegistered(handle) {
+		return "", fmt.Errorf("user %s already exists", handle)
+	}
+
+	return manager.registerUser(user)
+}
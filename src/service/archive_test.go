@@ -0,0 +1,294 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+// exportArchive exports the archive of the user and opens it as a zip
+func exportArchive(t *testing.T, tweetManager *service.TweetManager, user string) (*bytes.Buffer, *zip.Reader) {
+
+	var archive bytes.Buffer
+
+	if err := tweetManager.ExportArchive(user, &archive); err != nil {
+		t.Fatalf("Unexpected error exporting archive %s", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening archive %s", err)
+	}
+
+	return &archive, reader
+}
+
+func TestImportedArchiveKeepsQuotesWithNewIds(t *testing.T) {
+
+	// Initialization
+	source := newManagerWithUsers("grupoesfera", "gonzalo", "nick")
+
+	ctx := context.Background()
+
+	imageTweet := domain.NewImageTweet("nick", "My gopher", "http://gopher.png")
+	imageId, _ := source.PublishTweet(ctx, imageTweet)
+	source.PublishTweet(ctx, domain.NewQuoteTweet("nick", "Look at it again", imageTweet))
+	source.PublishTweet(ctx, domain.NewQuoteTweet("nick", "So true", source.GetTweetById(1)))
+
+	source.LikeTweet("gonzalo", imageId)
+	source.Follow("nick", "gonzalo")
+	source.Follow("nick", "grupoesfera")
+
+	archive, _ := exportArchive(t, source, "nick")
+
+	destination := newManagerWithUsers("gonzalo", "mariana", "grupo")
+
+	// Operation
+	ids, err := destination.ImportArchive(context.Background(), bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+
+	if len(ids) != 4 || ids[imageId] == imageId {
+		t.Fatalf("Expected the 4 tweets to get new ids but were %v", ids)
+	}
+
+	tweets := destination.GetTweetsByUser("nick")
+	assertTexts(t, tweets, "Hello", "My gopher", "Look at it again", "So true")
+
+	ownQuote := destination.GetTweetById(ids[imageId+1]).(*domain.QuoteTweet)
+
+	if ownQuote.QuotedTweet == nil || ownQuote.QuotedTweet.GetId() != ids[imageId] {
+		t.Errorf("Expected the quote to point to tweet %d but was %v", ids[imageId], ownQuote.QuotedTweet)
+	}
+
+	otherQuote := destination.GetTweetById(ids[imageId+2]).(*domain.QuoteTweet)
+
+	if quoted := otherQuote.QuotedTweet; quoted == nil || quoted.GetId() != 0 || quoted.GetUser() != "grupoesfera" || quoted.GetText() != "Hello" {
+		t.Errorf("Expected the quote of a tweet outside the archive to keep its user and text but was %v", otherQuote.QuotedTweet)
+	}
+
+	if !tweets[1].GetDate().Equal(*imageTweet.GetDate()) {
+		t.Errorf("Expected the date %v to be kept but was %v", imageTweet.GetDate(), tweets[1].GetDate())
+	}
+
+	if following := destination.GetFollowing("nick"); !reflect.DeepEqual(following, []string{"gonzalo"}) {
+		t.Errorf("Expected nick to follow [gonzalo] but was %v", following)
+	}
+}
+
+func TestArchiveHasJSONFilesAndHTMLIndex(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("grupoesfera", "nick")
+
+	ctx := context.Background()
+
+	imageTweet := domain.NewImageTweet("nick", "My <gopher>", "http://gopher.png")
+	imageId, _ := tweetManager.PublishTweet(ctx, imageTweet)
+	tweetManager.PublishTweet(ctx, domain.NewQuoteTweet("nick", "Again", imageTweet))
+	tweetManager.LikeTweet("nick", 1)
+
+	// Operation
+	_, archive := exportArchive(t, tweetManager, "nick")
+
+	// Validation
+	contents := make(map[string]string)
+
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		content, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		contents[file.Name] = string(content)
+	}
+
+	for _, name := range []string{"profile.json", "tweets.json", "images.json", "likes.json", "index.html"} {
+		if _, found := contents[name]; !found {
+			t.Errorf("Expected the archive to have %s", name)
+		}
+	}
+
+	if !strings.Contains(contents["images.json"], "http://gopher.png") {
+		t.Errorf("Expected the image in images.json but was %s", contents["images.json"])
+	}
+
+	if !strings.Contains(contents["likes.json"], "\"TweetId\": 1") {
+		t.Errorf("Expected the liked tweet in likes.json but was %s", contents["likes.json"])
+	}
+
+	index := contents["index.html"]
+
+	expected := []string{
+		fmt.Sprintf("id=\"tweet-%d\"", imageId),
+		fmt.Sprintf("href=\"#tweet-%d\"", imageId),
+		"My &lt;gopher&gt;",
+	}
+
+	for _, text := range expected {
+		if !strings.Contains(index, text) {
+			t.Errorf("Expected index.html to contain %s but was %s", text, index)
+		}
+	}
+}
+
+func TestArchiveOfUnknownUserCantBeExported(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	var archive bytes.Buffer
+
+	// Operation
+	err := tweetManager.ExportArchive("unknown", &archive)
+
+	// Validation
+	if err == nil || archive.Len() != 0 {
+		t.Errorf("Expected error exporting the archive of an unknown user")
+	}
+}
+
+// writeArchive returns an archive with the profile and tweets
+func writeArchive(t *testing.T, profile service.ArchiveProfile, tweets []service.ArchiveTweet) *bytes.Buffer {
+
+	var archive bytes.Buffer
+
+	writer := zip.NewWriter(&archive)
+
+	for name, content := range map[string]interface{}{"profile.json": profile, "tweets.json": tweets} {
+
+		fileWriter, _ := writer.Create(name)
+
+		if err := json.NewEncoder(fileWriter).Encode(content); err != nil {
+			t.Fatalf("Unexpected error writing the archive %s", err)
+		}
+	}
+
+	writer.Close()
+
+	return &archive
+}
+
+func TestArchiveWithAnInvalidTweetImportsNothing(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("grupoesfera")
+
+	archive := writeArchive(t, service.ArchiveProfile{Version: service.ArchiveVersion, User: "nick"}, []service.ArchiveTweet{
+		{Id: 1, Kind: "text", Text: "Valid"},
+		{Id: 2, Kind: "text", Text: strings.Repeat("Too long ", 20)},
+	})
+
+	// Operation
+	_, err := tweetManager.ImportArchive(context.Background(), bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+
+	// Validation
+	if err == nil || err.Error() != "tweet 2 couldn't be imported: text exceeds 140 characters" {
+		t.Errorf("Expected the invalid tweet to be rejected but was %v", err)
+	}
+
+	if tweetManager.IsRegistered("nick") || len(tweetManager.GetTweets()) != 1 {
+		t.Errorf("Expected nothing to be imported but the tweets were %v", tweetManager.GetTweets())
+	}
+}
+
+func TestArchiveIsRejectedWhenItExceedsTheDailyQuota(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.Local)
+
+	tweetManager := newLimitedManager(service.RateLimiterConfig{DailyQuota: 2}, &now)
+
+	ctx := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.RESTChannel})
+
+	profile := service.ArchiveProfile{Version: service.ArchiveVersion, User: "nick"}
+	tooLarge := writeArchive(t, profile, []service.ArchiveTweet{
+		{Id: 1, Kind: "text", Text: "First"},
+		{Id: 2, Kind: "text", Text: "Second"},
+		{Id: 3, Kind: "text", Text: "Third"},
+	})
+	fitting := writeArchive(t, profile, []service.ArchiveTweet{
+		{Id: 1, Kind: "text", Text: "First"},
+		{Id: 2, Kind: "text", Text: "Second"},
+	})
+
+	// Operation
+	_, rejectedErr := tweetManager.ImportArchive(ctx, bytes.NewReader(tooLarge.Bytes()), int64(tooLarge.Len()))
+	ids, err := tweetManager.ImportArchive(ctx, bytes.NewReader(fitting.Bytes()), int64(fitting.Len()))
+	_, exceededErr := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "After the import"))
+
+	// Validation
+	if limitErr, ok := rejectedErr.(*service.RateLimitError); !ok || limitErr.Limit != "daily quota" || limitErr.Remaining != 2 {
+		t.Errorf("Expected the archive to exceed the quota but was %v", rejectedErr)
+	}
+
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Expected the archive that fits in the quota to be imported but was %v", err)
+	}
+
+	if _, ok := exceededErr.(*service.RateLimitError); !ok {
+		t.Errorf("Expected the imported tweets to count for the quota but was %v", exceededErr)
+	}
+
+	if count := tweetManager.CountTweetsByUser("nick"); count != 2 {
+		t.Errorf("Expected 2 tweets of nick but were %d", count)
+	}
+}
+
+func TestArchiveOfAnExistingUserIsNotImported(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	archive := writeArchive(t, service.ArchiveProfile{Version: service.ArchiveVersion, User: "Nick"}, []service.ArchiveTweet{
+		{Id: 1, Kind: "text", Text: "Backdated", Date: time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)},
+	})
+
+	// Operation
+	_, err := tweetManager.ImportArchive(context.Background(), bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+
+	// Validation
+	if err == nil || err.Error() != "user nick already exists" {
+		t.Errorf("Expected the archive of an existing user to be rejected but was %v", err)
+	}
+
+	assertTexts(t, tweetManager.GetTweets(), "Hello")
+}
+
+func TestArchiveWithAHugeFileIsRejected(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+
+	var archive bytes.Buffer
+
+	writer := zip.NewWriter(&archive)
+
+	profileWriter, _ := writer.Create("profile.json")
+	json.NewEncoder(profileWriter).Encode(service.ArchiveProfile{Version: service.ArchiveVersion, User: "nick"})
+
+	tweetsWriter, _ := writer.Create("tweets.json")
+	tweetsWriter.Write(bytes.Repeat([]byte(" "), 65<<20))
+
+	writer.Close()
+
+	// Operation
+	_, err := tweetManager.ImportArchive(context.Background(), bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "tweets.json is larger than") {
+		t.Errorf("Expected the huge file to be rejected but was %v", err)
+	}
+}
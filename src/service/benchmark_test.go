@@ -1,6 +1,10 @@
 package service_test
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/cursoGo/src/domain"
@@ -10,16 +14,20 @@ import (
 func BenchmarkPublishTweetWithFileTweetWriter(b *testing.B) {
 
 	// Initialization
-	fileTweetWriter := service.NewFileTweetWriter()
+	fileTweetWriter, remove := tempFileTweetWriter(b)
+	defer remove()
+
 	tweetWriter := service.NewChannelTweetWriter(fileTweetWriter)
-	tweetManager := service.NewTweetManager(tweetWriter)
+	defer tweetWriter.Close()
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 	tweet := domain.NewTextTweet("grupoesfera", "This is my tweet")
 
 	// Operation
 	for n := 0; n < b.N; n++ {
-		tweetManager.PublishTweet(tweet, quit)
+		tweetManager.PublishTweet(ctx, tweet)
 	}
 }
 
@@ -28,13 +36,185 @@ func BenchmarkPublishTweetWithMemoryTweetWriter(b *testing.B) {
 	// Initialization
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 	tweet := domain.NewTextTweet("grupoesfera", "This is my tweet")
 
 	// Operation
 	for n := 0; n < b.N; n++ {
-		tweetManager.PublishTweet(tweet, quit)
+		tweetManager.PublishTweet(ctx, tweet)
 	}
 }
+
+func BenchmarkPublishTweetWithTrendService(b *testing.B) {
+
+	// Initialization
+	memoryTweetWriter := service.NewMemoryTweetWriter()
+	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+	service.NewTrendService(tweetManager, service.DefaultTrendConfig())
+
+	ctx := context.Background()
+	tweet := domain.NewTextTweet("grupoesfera", "This is my #golang tweet")
+
+	// Operation
+	for n := 0; n < b.N; n++ {
+		tweetManager.PublishTweet(ctx, tweet)
+	}
+
+	// The trends are counted asynchronously, so they are waited for
+	tweetManager.Events().Close()
+}
+
+// batchSizes are the sizes compared by the pipeline benchmarks, where 1
+// writes every tweet on its own
+var batchSizes = []int{1, 10, 100}
+
+func tempFileTweetWriter(b *testing.B) (*service.FileTweetWriter, func()) {
+
+	directory, err := ioutil.TempDir("", "tweetbenchmark")
+
+	if err != nil {
+		b.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	config := service.DefaultFileTweetWriterConfig()
+	config.Directory = directory
+
+	writer, err := service.NewFileTweetWriter(config)
+
+	if err != nil {
+		b.Fatalf("Unexpected error creating the writer: %s", err.Error())
+	}
+
+	return writer, func() {
+		writer.Close()
+		os.RemoveAll(directory)
+	}
+}
+
+// writeInOwnGoroutine writes the tweet as tweets were written before the
+// pipeline, with a goroutine and a channel for every tweet. quit gets true
+// once it is written
+func writeInOwnGoroutine(writer service.TweetWriter, tweet domain.Tweet, quit chan bool) {
+
+	tweetsToWrite := make(chan domain.Tweet)
+
+	go func() {
+		for tweetToWrite := range tweetsToWrite {
+			writer.WriteTweet(tweetToWrite)
+		}
+		quit <- true
+	}()
+
+	tweetsToWrite <- tweet
+	close(tweetsToWrite)
+}
+
+// benchmarkPipeline publishes b.N tweets through one pipeline for every
+// batch size, waiting for all of them to be written. GoroutinePerTweet is
+// the baseline, which writes the tweets as before the pipeline. It only
+// saves the tweets in the repository instead of publishing them, so it
+// leaves out the cost of the manager
+func benchmarkPipeline(b *testing.B, writer service.TweetWriter) {
+
+	b.Run("GoroutinePerTweet", func(b *testing.B) {
+
+		// Initialization
+		repository := service.NewMemoryTweetRepository()
+		quit := make(chan bool, b.N)
+
+		b.ResetTimer()
+
+		// Operation
+		for n := 0; n < b.N; n++ {
+			tweet := domain.NewTextTweet("grupoesfera", "This is my tweet")
+			repository.Save(tweet)
+			writeInOwnGoroutine(writer, tweet, quit)
+		}
+
+		for n := 0; n < b.N; n++ {
+			<-quit
+		}
+	})
+
+	for _, batchSize := range batchSizes {
+
+		b.Run(fmt.Sprintf("Batch%d", batchSize), func(b *testing.B) {
+
+			// Initialization
+			config := service.DefaultChannelTweetWriterConfig()
+			config.BatchSize = batchSize
+
+			tweetWriter := service.NewChannelTweetWriterWithConfig(writer, config)
+			defer tweetWriter.Close()
+
+			tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+			b.ResetTimer()
+
+			// Operation
+			for n := 0; n < b.N; n++ {
+				tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "This is my tweet"))
+			}
+
+			tweetWriter.Flush(context.Background())
+		})
+	}
+}
+
+func BenchmarkFileTweetWriterOneByOne(b *testing.B) {
+
+	// Initialization
+	fileTweetWriter, remove := tempFileTweetWriter(b)
+	defer remove()
+
+	tweet := domain.NewTextTweet("grupoesfera", "This is my tweet")
+
+	b.ResetTimer()
+
+	// Operation
+	for n := 0; n < b.N; n++ {
+		fileTweetWriter.WriteTweet(tweet)
+	}
+}
+
+func BenchmarkPipelineWithFileTweetWriter(b *testing.B) {
+
+	// Initialization
+	fileTweetWriter, remove := tempFileTweetWriter(b)
+	defer remove()
+
+	// Operation
+	benchmarkPipeline(b, fileTweetWriter)
+}
+
+func BenchmarkPipelineWithMemoryTweetWriter(b *testing.B) {
+
+	// Operation
+	benchmarkPipeline(b, service.NewMemoryTweetWriter())
+}
+
+func BenchmarkPipelineWithTweetStore(b *testing.B) {
+
+	// Initialization
+	directory, err := ioutil.TempDir("", "tweetbenchmark")
+
+	if err != nil {
+		b.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	defer os.RemoveAll(directory)
+
+	store, err := service.OpenTweetStore(directory, service.TweetStoreConfig{})
+
+	if err != nil {
+		b.Fatalf("Unexpected error opening the store: %s", err.Error())
+	}
+
+	defer store.Close()
+
+	// Operation
+	benchmarkPipeline(b, store)
+}
@@ -0,0 +1,68 @@
+package service
+
+import "hash/fnv"
+
+// CountMinSketch estimates how many times every key was added using a fixed
+// amount of memory. Estimations never fall below the real count but can be
+// greater because of collisions
+type CountMinSketch struct {
+	depth int
+	width int
+	cells [][]float64
+}
+
+func NewCountMinSketch(depth, width int) *CountMinSketch {
+
+	sketch := new(CountMinSketch)
+
+	sketch.depth = depth
+	sketch.width = width
+	sketch.cells = make([][]float64, depth)
+
+	for row := range sketch.cells {
+		sketch.cells[row] = make([]float64, width)
+	}
+
+	return sketch
+}
+
+func (sketch *CountMinSketch) Add(key string, value float64) {
+
+	for row := 0; row < sketch.depth; row++ {
+		sketch.cells[row][sketch.column(key, row)] += value
+	}
+}
+
+func (sketch *CountMinSketch) Estimate(key string) float64 {
+
+	var estimate float64
+
+	for row := 0; row < sketch.depth; row++ {
+
+		value := sketch.cells[row][sketch.column(key, row)]
+
+		if row == 0 || value < estimate {
+			estimate = value
+		}
+	}
+
+	return estimate
+}
+
+func (sketch *CountMinSketch) Scale(factor float64) {
+
+	for _, row := range sketch.cells {
+		for column := range row {
+			row[column] *= factor
+		}
+	}
+}
+
+func (sketch *CountMinSketch) column(key string, row int) int {
+
+	hash := fnv.New64a()
+	hash.Write([]byte{byte(row)})
+	hash.Write([]byte(key))
+
+	return int(hash.Sum64() % uint64(sketch.width))
+}
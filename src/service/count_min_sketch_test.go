@@ -0,0 +1,46 @@
+package service_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/cursoGo/src/service"
+)
+
+func TestCountMinSketchNeverUnderestimates(t *testing.T) {
+
+	// Initialization
+	sketch := service.NewCountMinSketch(4, 64)
+
+	// Operation
+	for n := 0; n < 1000; n++ {
+		sketch.Add(strconv.Itoa(n%100), 1)
+	}
+	sketch.Add("golang", 50)
+
+	// Validation
+	for n := 0; n < 100; n++ {
+		if estimate := sketch.Estimate(strconv.Itoa(n)); estimate < 10 {
+			t.Errorf("Expected estimate of %d is at least 10 but was %v", n, estimate)
+		}
+	}
+
+	if estimate := sketch.Estimate("golang"); estimate < 50 {
+		t.Errorf("Expected estimate of golang is at least 50 but was %v", estimate)
+	}
+}
+
+func TestCountMinSketchCanBeScaled(t *testing.T) {
+
+	// Initialization
+	sketch := service.NewCountMinSketch(4, 64)
+	sketch.Add("golang", 10)
+
+	// Operation
+	sketch.Scale(0.5)
+
+	// Validation
+	if estimate := sketch.Estimate("golang"); estimate != 5 {
+		t.Errorf("Expected estimate is 5 but was %v", estimate)
+	}
+}
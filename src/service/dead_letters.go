@@ -0,0 +1,390 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+// DeadLetter is a tweet, or a deletion or rename of the TweetStore, that
+// couldn't be written after every retry. Operation describes the deletion
+// or rename, whose dead letters have no tweet
+type DeadLetter struct {
+	Id        int
+	Tweet     domain.Tweet `json:",omitempty"`
+	Operation string       `json:",omitempty"`
+	Error     string
+	FailedAt  time.Time
+}
+
+// recordWriter writes the records of deletions and renames when they are
+// replayed, like the TweetStore does
+type recordWriter interface {
+	writeRecords(records []tweetRecord) error
+}
+
+// deadLetterRecord is how dead letters are stored, one JSON per line.
+// Quotes keep the quoted tweet, as it may not be in the queue. Deletions
+// and renames keep their record as the tweet
+type deadLetterRecord struct {
+	Id       int
+	Tweet    tweetRecord
+	Quoted   *tweetRecord `json:",omitempty"`
+	Error    string
+	FailedAt time.Time
+}
+
+// DeadLetterQueue keeps in a file the tweets that couldn't be written, so
+// they can be inspected and written again to the writer that failed
+type DeadLetterQueue struct {
+	path    string
+	writer  TweetWriter
+	keyring *Keyring
+	clock   func() time.Time
+
+	mutex  sync.Mutex
+	lastId int
+}
+
+// OpenDeadLetterQueue opens the dead letters of the file, which is created
+// when the first tweet fails. Replayed tweets are written to the writer
+func OpenDeadLetterQueue(path string, writer TweetWriter) (*DeadLetterQueue, error) {
+	return OpenEncryptedDeadLetterQueue(path, writer, nil)
+}
+
+// OpenEncryptedDeadLetterQueue opens a queue whose dead letters are
+// encrypted with the current key of the keyring. Plain dead letters are
+// only read while the keyring migrates plain text
+func OpenEncryptedDeadLetterQueue(path string, writer TweetWriter, keyring *Keyring) (*DeadLetterQueue, error) {
+
+	queue := new(DeadLetterQueue)
+
+	queue.path = path
+	queue.writer = writer
+	queue.keyring = keyring
+	queue.clock = time.Now
+
+	records, err := queue.readRecords()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Id > queue.lastId {
+			queue.lastId = record.Id
+		}
+	}
+
+	return queue, nil
+}
+
+// Add keeps the tweets as dead letters with the error of their last write.
+// It returns once they are on disk
+func (queue *DeadLetterQueue) Add(tweets []domain.Tweet, writeErr error) error {
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	records := make([]deadLetterRecord, 0, len(tweets))
+
+	for _, tweet := range tweets {
+		queue.lastId++
+		records = append(records, newDeadLetterRecord(queue.lastId, tweet, writeErr.Error(), queue.clock()))
+	}
+
+	return queue.append(records)
+}
+
+func (queue *DeadLetterQueue) addRecords(tweetRecords []tweetRecord, writeErr error) error {
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	records := make([]deadLetterRecord, 0, len(tweetRecords))
+
+	for _, tweetRecord := range tweetRecords {
+		queue.lastId++
+		records = append(records, deadLetterRecord{Id: queue.lastId, Tweet: tweetRecord, Error: writeErr.Error(), FailedAt: queue.clock()})
+	}
+
+	return queue.append(records)
+}
+
+func (queue *DeadLetterQueue) append(records []deadLetterRecord) error {
+
+	file, err := os.OpenFile(queue.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+
+	if err != nil {
+		return err
+	}
+
+	err = queue.writeRecords(file, records)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (queue *DeadLetterQueue) List() ([]DeadLetter, error) {
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	records, err := queue.readRecords()
+
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(records))
+
+	for _, record := range records {
+
+		if record.Tweet.Operation != "" {
+			letters = append(letters, DeadLetter{Id: record.Id, Operation: record.Tweet.operation(), Error: record.Error, FailedAt: record.FailedAt})
+			continue
+		}
+
+		tweet, err := record.tweet()
+
+		if err != nil {
+			return nil, err
+		}
+
+		letters = append(letters, DeadLetter{Id: record.Id, Tweet: tweet, Error: record.Error, FailedAt: record.FailedAt})
+	}
+
+	return letters, nil
+}
+
+// Replay writes every dead letter again, returning how many were written.
+// The ones that fail again stay in the queue with their new error
+func (queue *DeadLetterQueue) Replay() (int, error) {
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	records, err := queue.readRecords()
+
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := make([]deadLetterRecord, 0)
+
+	for _, record := range records {
+
+		err := queue.replay(record)
+
+		if err != nil {
+			record.Error = err.Error()
+			record.FailedAt = queue.clock()
+			remaining = append(remaining, record)
+		}
+	}
+
+	replayed := len(records) - len(remaining)
+
+	// Every record was either written or got a new error, so the file is
+	// rewritten unless it was empty
+	if len(records) > 0 {
+		if err := queue.rewrite(remaining); err != nil {
+			return replayed, fmt.Errorf("%d dead letters were written but couldn't be removed: %s", replayed, err.Error())
+		}
+	}
+
+	if len(remaining) > 0 {
+		return replayed, fmt.Errorf("%d dead letters couldn't be written: %s", len(remaining), remaining[len(remaining)-1].Error)
+	}
+
+	return replayed, nil
+}
+
+func (queue *DeadLetterQueue) replay(record deadLetterRecord) error {
+
+	if record.Tweet.Operation != "" {
+
+		writer, ok := queue.writer.(recordWriter)
+
+		if !ok {
+			return fmt.Errorf("the %s can't be written to the writer", record.Tweet.operation())
+		}
+
+		return writer.writeRecords([]tweetRecord{record.Tweet})
+	}
+
+	tweet, err := record.tweet()
+
+	if err != nil {
+		return err
+	}
+
+	return queue.writer.WriteTweet(tweet)
+}
+
+func (queue *DeadLetterQueue) readRecords() ([]deadLetterRecord, error) {
+
+	records := make([]deadLetterRecord, 0)
+
+	file, err := os.Open(queue.path)
+
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		var record deadLetterRecord
+
+		data, err := queue.openLine(scanner.Bytes())
+
+		if err == nil {
+			err = json.Unmarshal(data, &record)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("dead letters file %s has an invalid line %d: %s", queue.path, line, err.Error())
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// rewrite replaces the file with the records through a temporary file, so
+// the dead letters are never half written
+func (queue *DeadLetterQueue) rewrite(records []deadLetterRecord) error {
+
+	temporaryPath := queue.path + temporarySuffix
+
+	file, err := os.Create(temporaryPath)
+
+	if err != nil {
+		return err
+	}
+
+	err = queue.writeRecords(file, records)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temporaryPath, queue.path)
+	}
+
+	if err != nil {
+		os.Remove(temporaryPath)
+	}
+
+	return err
+}
+
+func (queue *DeadLetterQueue) writeRecords(file *os.File, records []deadLetterRecord) error {
+
+	lines := make([]byte, 0)
+
+	for _, record := range records {
+
+		line, err := json.Marshal(record)
+
+		if err != nil {
+			return err
+		}
+
+		// Sealed lines are written in base64, so they have no line breaks
+		if queue.keyring != nil {
+			line = []byte(base64.StdEncoding.EncodeToString(queue.keyring.seal(line)))
+		}
+
+		lines = append(append(lines, line...), '\n')
+	}
+
+	if _, err := file.Write(lines); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+func (queue *DeadLetterQueue) openLine(line []byte) ([]byte, error) {
+
+	if bytes.HasPrefix(line, []byte("{")) {
+
+		if !readsPlaintext(queue.keyring) {
+			return nil, fmt.Errorf("it is in plain text but the dead letters are encrypted")
+		}
+
+		return line, nil
+	}
+
+	if queue.keyring == nil {
+		return nil, fmt.Errorf("it is encrypted and no encryption key was given")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return queue.keyring.open(sealed)
+}
+
+func newDeadLetterRecord(id int, tweet domain.Tweet, writeErr string, failedAt time.Time) deadLetterRecord {
+
+	record := deadLetterRecord{
+		Id:       id,
+		Tweet:    newTweetRecord(tweet),
+		Error:    writeErr,
+		FailedAt: failedAt,
+	}
+
+	if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok && quoteTweet.QuotedTweet != nil {
+		quoted := newTweetRecord(quoteTweet.QuotedTweet)
+		record.Quoted = &quoted
+	}
+
+	return record
+}
+
+func (record deadLetterRecord) tweet() (domain.Tweet, error) {
+
+	tweet, err := record.Tweet.tweet()
+
+	if err != nil || record.Quoted == nil {
+		return tweet, err
+	}
+
+	quoted, err := record.Quoted.tweet()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok {
+		quoteTweet.QuotedTweet = quoted
+	}
+
+	return tweet, nil
+}
@@ -0,0 +1,336 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+// failingTweetWriter fails the first failures writes and then writes to
+// the memory writer
+type failingTweetWriter struct {
+	mutex    sync.Mutex
+	failures int
+	attempts int
+	writer   *service.MemoryTweetWriter
+}
+
+func (writer *failingTweetWriter) WriteTweet(tweet domain.Tweet) error {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.attempts++
+
+	if writer.attempts <= writer.failures {
+		return fmt.Errorf("no space left on device")
+	}
+
+	return writer.writer.WriteTweet(tweet)
+}
+
+func retryConfig(deadLetters *service.DeadLetterQueue) service.ChannelTweetWriterConfig {
+	return service.ChannelTweetWriterConfig{
+		QueueSize:     10,
+		BatchSize:     1,
+		BatchDelay:    time.Millisecond,
+		MaxRetries:    3,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 2 * time.Millisecond,
+		DeadLetters:   deadLetters,
+	}
+}
+
+func tempDeadLettersPath(t *testing.T) (string, func()) {
+
+	directory, err := ioutil.TempDir("", "deadletters")
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	return filepath.Join(directory, "dead_letters.jsonl"), func() { os.RemoveAll(directory) }
+}
+
+func TestChannelTweetWriterRetriesFailedWrites(t *testing.T) {
+
+	// Initialization
+	writer := &failingTweetWriter{failures: 2, writer: service.NewMemoryTweetWriter()}
+	tweetWriter := service.NewChannelTweetWriterWithConfig(writer, retryConfig(nil))
+
+	ctx := context.Background()
+
+	// Operation
+	ack, _ := tweetWriter.Enqueue(ctx, domain.NewTextTweet("grupoesfera", "Written at last"))
+	err := ack.Wait(ctx)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Expected the tweet to be written after retrying but was %s", err.Error())
+	}
+
+	if stats := tweetWriter.Stats(); stats.Retries != 2 || stats.Written != 1 || stats.Failed != 0 {
+		t.Errorf("Expected two retries but the stats were %+v", stats)
+	}
+
+	assertTexts(t, writer.writer.Tweets, "Written at last")
+}
+
+func TestChannelTweetWriterKeepsTweetsThatStillFailAsDeadLetters(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	writer := &failingTweetWriter{failures: 100, writer: service.NewMemoryTweetWriter()}
+
+	deadLetters, _ := service.OpenDeadLetterQueue(path, writer)
+	tweetWriter := service.NewChannelTweetWriterWithConfig(writer, retryConfig(deadLetters))
+
+	ctx := context.Background()
+
+	quoted := domain.NewTextTweet("nick", "Learning Go")
+	quoted.SetId(7)
+
+	// Operation
+	ack, _ := tweetWriter.Enqueue(ctx, domain.NewQuoteTweet("grupoesfera", "Me too", quoted))
+	err := ack.Wait(ctx)
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "dead letter: no space left on device") {
+		t.Errorf("Expected the write error in the ack but was %v", err)
+	}
+
+	if writer.attempts != 4 {
+		t.Errorf("Expected the first write and three retries but were %d attempts", writer.attempts)
+	}
+
+	letters, err := deadLetters.List()
+
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("Expected one dead letter but were %v (%v)", letters, err)
+	}
+
+	quoteTweet, ok := letters[0].Tweet.(*domain.QuoteTweet)
+
+	if !ok || quoteTweet.QuotedTweet == nil || quoteTweet.QuotedTweet.GetId() != 7 {
+		t.Errorf("Expected the quote to keep the quoted tweet but was %v", letters[0].Tweet)
+	}
+
+	if letters[0].Error != "no space left on device" {
+		t.Errorf("Expected the error of the last write but was %s", letters[0].Error)
+	}
+
+	if stats := tweetWriter.Stats(); stats.Failed != 1 || stats.DeadLettered != 1 {
+		t.Errorf("Expected the tweet to be counted as dead lettered but the stats were %+v", stats)
+	}
+}
+
+func TestDeadLetterQueueReplaysToTheWriter(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	writer := &failingTweetWriter{failures: 1, writer: service.NewMemoryTweetWriter()}
+
+	deadLetters, _ := service.OpenDeadLetterQueue(path, writer)
+	deadLetters.Add([]domain.Tweet{
+		domain.NewTextTweet("grupoesfera", "Fails again"),
+		domain.NewTextTweet("grupoesfera", "Written now"),
+	}, fmt.Errorf("disk is full"))
+
+	// Operation
+	replayed, err := deadLetters.Replay()
+
+	// Validation
+	if replayed != 1 || err == nil {
+		t.Errorf("Expected one tweet replayed and the other to fail but were %d (%v)", replayed, err)
+	}
+
+	assertTexts(t, writer.writer.Tweets, "Written now")
+
+	// The queue is reopened to check the file was rewritten
+	deadLetters, _ = service.OpenDeadLetterQueue(path, writer)
+
+	letters, _ := deadLetters.List()
+
+	if len(letters) != 1 || letters[0].Id != 1 || letters[0].Error != "no space left on device" {
+		t.Fatalf("Expected the tweet that failed again to be kept but were %v", letters)
+	}
+
+	if replayed, err := deadLetters.Replay(); replayed != 1 || err != nil {
+		t.Errorf("Expected the last tweet to be replayed but were %d (%v)", replayed, err)
+	}
+
+	if letters, _ := deadLetters.List(); len(letters) != 0 {
+		t.Errorf("Expected no dead letters but were %v", letters)
+	}
+}
+
+func TestEncryptedDeadLettersAreNotReadableWithoutTheKey(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	keyring := newKeyring(t, 1, map[uint32]string{1: "0123456789abcdef"})
+
+	deadLetters, _ := service.OpenEncryptedDeadLetterQueue(path, service.NewMemoryTweetWriter(), keyring)
+
+	// Operation
+	err := deadLetters.Add([]domain.Tweet{domain.NewTextTweet("grupoesfera", "My secret gopher")}, fmt.Errorf("disk is full"))
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	if content, _ := ioutil.ReadFile(path); strings.Contains(string(content), "secret") {
+		t.Errorf("Expected the dead letters to be encrypted but were %s", content)
+	}
+
+	if _, err := service.OpenDeadLetterQueue(path, service.NewMemoryTweetWriter()); err == nil {
+		t.Errorf("Expected the dead letters not to be read without the key")
+	}
+
+	if letters, _ := deadLetters.List(); len(letters) != 1 || letters[0].Tweet.GetText() != "My secret gopher" {
+		t.Errorf("Expected the dead letter to be read with the key but were %v", letters)
+	}
+}
+
+func TestFileTweetWriterReturnsTheErrorWhenTheDirectoryCantBeCreated(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	// The directory of the file is a file, so the tweets can't be written
+	ioutil.WriteFile(config.Directory, []byte("not a directory"), 0666)
+
+	// Operation
+	writer, err := service.NewFileTweetWriter(config)
+
+	// Validation
+	if err == nil || !strings.Contains(err.Error(), "tweet directory couldn't be created") {
+		t.Errorf("Expected the error creating the directory but was %v", err)
+	}
+
+	if writer != nil {
+		t.Errorf("Expected no writer when its directory can't be created")
+	}
+}
+
+func TestTweetStoreKeepsDeletionsThatFailAsDeadLetters(t *testing.T) {
+
+	// Initialization
+	directory, removeDirectory := tempStoreDirectory(t)
+	defer removeDirectory()
+
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	config := service.DefaultTweetStoreConfig()
+	config.RetryDelay = time.Millisecond
+
+	store, _ := service.OpenTweetStore(directory, config)
+	deadLetters, _ := service.OpenDeadLetterQueue(path, store)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(store))
+	store.Attach(tweetManager, deadLetters)
+
+	id := publishAndWait(t, tweetManager, "grupoesfera", "Deleted later")
+
+	// The closed store can't write the deletion
+	store.Close()
+
+	// Operation
+	tweetManager.DeleteTweet("grupoesfera", id)
+
+	// Validation
+	for _, stats := range tweetManager.Events().Stats() {
+		if stats.Name == "tweet store" && (stats.Failed != 1 || !strings.Contains(stats.LastError, "delete of tweet 1 couldn't be written and was kept as a dead letter")) {
+			t.Errorf("Expected the store to fail writing the deletion but the stats were %+v", stats)
+		}
+	}
+
+	letters, _ := deadLetters.List()
+
+	if len(letters) != 1 || letters[0].Tweet != nil || letters[0].Operation != "delete of tweet 1" {
+		t.Fatalf("Expected the deletion as a dead letter but were %v", letters)
+	}
+
+	store, _ = openStore(t, directory, config)
+	deadLetters, _ = service.OpenDeadLetterQueue(path, store)
+
+	if replayed, err := deadLetters.Replay(); replayed != 1 || err != nil {
+		t.Errorf("Expected the deletion to be replayed but were %d (%v)", replayed, err)
+	}
+
+	store.Close()
+
+	if _, tweetManager := openStore(t, directory, config); len(tweetManager.GetTweets()) != 0 {
+		t.Errorf("Expected the replayed deletion to be stored but the tweets were %v", tweetManager.GetTweets())
+	}
+}
+
+func TestDeadLetterQueueKeepsTheNewErrorsWhenNothingWasReplayed(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	writer := &failingTweetWriter{failures: 100, writer: service.NewMemoryTweetWriter()}
+
+	deadLetters, _ := service.OpenDeadLetterQueue(path, writer)
+	deadLetters.Add([]domain.Tweet{domain.NewTextTweet("grupoesfera", "Fails again")}, fmt.Errorf("disk is full"))
+
+	// Operation
+	replayed, err := deadLetters.Replay()
+
+	// Validation
+	if replayed != 0 || err == nil {
+		t.Errorf("Expected the tweet to fail again but were %d (%v)", replayed, err)
+	}
+
+	if letters, _ := deadLetters.List(); len(letters) != 1 || letters[0].Error != "no space left on device" {
+		t.Errorf("Expected the new error to be kept but were %v", letters)
+	}
+}
+
+func TestDeadLetterQueueReportsTheReplayedTweetsWhenTheyCantBeRemoved(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	writer := service.NewMemoryTweetWriter()
+
+	deadLetters, _ := service.OpenDeadLetterQueue(path, writer)
+	deadLetters.Add([]domain.Tweet{
+		domain.NewTextTweet("grupoesfera", "First"),
+		domain.NewTextTweet("grupoesfera", "Second"),
+	}, fmt.Errorf("disk is full"))
+
+	// The temporary file can't be created, so the file can't be rewritten
+	os.Mkdir(path+".tmp", 0777)
+
+	// Operation
+	replayed, err := deadLetters.Replay()
+
+	// Validation
+	if replayed != 2 || err == nil || !strings.Contains(err.Error(), "2 dead letters were written but couldn't be removed") {
+		t.Errorf("Expected the two tweets to be reported as written but were %d (%v)", replayed, err)
+	}
+
+	assertTexts(t, writer.Tweets, "First", "Second")
+}
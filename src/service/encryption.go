@@ -0,0 +1,299 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EncryptionKeysVariable is the environment variable with the encryption
+// keys, like `2:<base64 key>,1:<base64 key>`. The first key encrypts and
+// all of them decrypt
+const EncryptionKeysVariable = "TWEETER_ENCRYPTION_KEYS"
+
+// MigratePlaintextVariable is the environment variable which lets the
+// files be read with plain records along the encrypted ones, while the
+// files written before the encryption are migrated
+const MigratePlaintextVariable = "TWEETER_MIGRATE_PLAINTEXT"
+
+// sealedVersion is the first byte of the sealed data. It is never the
+// first byte of a JSON record, so sealed and plain records can be mixed
+const sealedVersion = 1
+
+const sealedHeaderSize = 5
+
+// KeyringConfig has the encryption keys encoded in base64 by their id.
+// Current is the id of the key used to encrypt
+type KeyringConfig struct {
+	Current uint32
+	Keys    map[uint32]string
+}
+
+// Keyring encrypts with its current key using AES-GCM. Data encrypted with
+// a previous key can still be decrypted while that key is in the keyring,
+// so keys can be rotated without rewriting the old files
+type Keyring struct {
+	current          uint32
+	ciphers          map[uint32]cipher.AEAD
+	migratePlaintext bool
+}
+
+// NewKeyring builds a keyring from the config. Keys must have 16, 24 or 32
+// bytes
+func NewKeyring(config KeyringConfig) (*Keyring, error) {
+
+	keyring := new(Keyring)
+
+	keyring.current = config.Current
+	keyring.ciphers = make(map[uint32]cipher.AEAD)
+
+	for id, encodedKey := range config.Keys {
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d is not valid base64", id)
+		}
+
+		block, err := aes.NewCipher(key)
+
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d must have 16, 24 or 32 bytes but has %d", id, len(key))
+		}
+
+		keyring.ciphers[id], err = cipher.NewGCM(block)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if keyring.ciphers[config.Current] == nil {
+		return nil, fmt.Errorf("current encryption key %d does not exist", config.Current)
+	}
+
+	return keyring, nil
+}
+
+func LoadKeyring(path string) (*Keyring, error) {
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var config KeyringConfig
+
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return nil, fmt.Errorf("encryption keys file %s is invalid: %s", path, err.Error())
+	}
+
+	return NewKeyring(config)
+}
+
+// ParseKeyring reads keys written like `2:<base64 key>,1:<base64 key>`, as
+// in EncryptionKeysVariable. The first key is the current one
+func ParseKeyring(text string) (*Keyring, error) {
+
+	config := KeyringConfig{Keys: make(map[uint32]string)}
+
+	for index, entry := range strings.Split(text, ",") {
+
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("encryption key %s must be written as <id>:<base64 key>", entry)
+		}
+
+		id, err := strconv.ParseUint(parts[0], 10, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("encryption key id %s is not a number", parts[0])
+		}
+
+		if index == 0 {
+			config.Current = uint32(id)
+		}
+
+		config.Keys[uint32(id)] = parts[1]
+	}
+
+	return NewKeyring(config)
+}
+
+func (keyring *Keyring) CurrentKey() uint32 {
+	return keyring.current
+}
+
+// SetMigratePlaintext lets the plain records be read. Otherwise they are
+// rejected, so records can't be added to an encrypted file without the key
+func (keyring *Keyring) SetMigratePlaintext(migrate bool) {
+	keyring.migratePlaintext = migrate
+}
+
+// seal encrypts the data with the current key. The result has the version,
+// the key id, the nonce and the encrypted data
+func (keyring *Keyring) seal(data []byte) []byte {
+
+	aead := keyring.ciphers[keyring.current]
+
+	sealed := make([]byte, sealedHeaderSize+aead.NonceSize(), sealedHeaderSize+aead.NonceSize()+len(data)+aead.Overhead())
+
+	sealed[0] = sealedVersion
+	binary.BigEndian.PutUint32(sealed[1:sealedHeaderSize], keyring.current)
+
+	nonce := sealed[sealedHeaderSize:]
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(fmt.Sprintf("random nonce couldn't be read: %s", err.Error()))
+	}
+
+	return aead.Seal(sealed, nonce, data, sealed[:sealedHeaderSize])
+}
+
+func (keyring *Keyring) sealedSize(length int) int {
+
+	aead := keyring.ciphers[keyring.current]
+
+	return sealedHeaderSize + aead.NonceSize() + length + aead.Overhead()
+}
+
+// open decrypts data sealed with any key of the keyring. A key that can't
+// authenticate the data is reported as wrong
+func (keyring *Keyring) open(sealed []byte) ([]byte, error) {
+
+	if len(sealed) < sealedHeaderSize || sealed[0] != sealedVersion {
+		return nil, fmt.Errorf("data is not encrypted")
+	}
+
+	id := binary.BigEndian.Uint32(sealed[1:sealedHeaderSize])
+	aead := keyring.ciphers[id]
+
+	if aead == nil {
+		return nil, fmt.Errorf("encryption key %d is missing", id)
+	}
+
+	if len(sealed) < sealedHeaderSize+aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+
+	nonce := sealed[sealedHeaderSize : sealedHeaderSize+aead.NonceSize()]
+
+	data, err := aead.Open(nil, nonce, sealed[sealedHeaderSize+aead.NonceSize():], sealed[:sealedHeaderSize])
+
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %d is wrong or the data was modified", id)
+	}
+
+	return data, nil
+}
+
+func isSealed(data []byte) bool {
+	return len(data) > 0 && data[0] == sealedVersion
+}
+
+func readsPlaintext(keyring *Keyring) bool {
+	return keyring == nil || keyring.migratePlaintext
+}
+
+// EncryptedWriter encrypts every write as a separate block, preceded by its
+// length, so a file can be appended to by writers with different keys
+type EncryptedWriter struct {
+	writer  io.Writer
+	keyring *Keyring
+}
+
+func NewEncryptedWriter(writer io.Writer, keyring *Keyring) *EncryptedWriter {
+
+	encryptedWriter := new(EncryptedWriter)
+
+	encryptedWriter.writer = writer
+	encryptedWriter.keyring = keyring
+
+	return encryptedWriter
+}
+
+func (encryptedWriter *EncryptedWriter) Write(data []byte) (int, error) {
+
+	sealed := encryptedWriter.keyring.seal(data)
+
+	block := make([]byte, 4+len(sealed))
+
+	binary.BigEndian.PutUint32(block[0:4], uint32(len(sealed)))
+	copy(block[4:], sealed)
+
+	if _, err := encryptedWriter.writer.Write(block); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+type EncryptedReader struct {
+	reader  io.Reader
+	keyring *Keyring
+	pending []byte
+}
+
+func NewEncryptedReader(reader io.Reader, keyring *Keyring) *EncryptedReader {
+
+	encryptedReader := new(EncryptedReader)
+
+	encryptedReader.reader = reader
+	encryptedReader.keyring = keyring
+
+	return encryptedReader
+}
+
+// Read returns the decrypted data, reading the next block when the
+// previous one was consumed
+func (encryptedReader *EncryptedReader) Read(data []byte) (int, error) {
+
+	for len(encryptedReader.pending) == 0 {
+
+		header := make([]byte, 4)
+
+		if _, err := io.ReadFull(encryptedReader.reader, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, fmt.Errorf("encrypted block is incomplete")
+			}
+			return 0, err
+		}
+
+		length := binary.BigEndian.Uint32(header)
+
+		if length > maxRecordSize {
+			return 0, fmt.Errorf("encrypted block of %d bytes is too big, the data may not be encrypted", length)
+		}
+
+		sealed := make([]byte, length)
+
+		if _, err := io.ReadFull(encryptedReader.reader, sealed); err != nil {
+			return 0, fmt.Errorf("encrypted block is incomplete")
+		}
+
+		pending, err := encryptedReader.keyring.open(sealed)
+
+		if err != nil {
+			return 0, err
+		}
+
+		encryptedReader.pending = pending
+	}
+
+	read := copy(data, encryptedReader.pending)
+	encryptedReader.pending = encryptedReader.pending[read:]
+
+	return read, nil
+}
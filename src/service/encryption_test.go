@@ -0,0 +1,279 @@
+package service_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func newKeyring(t *testing.T, current uint32, keys map[uint32]string) *service.Keyring {
+
+	config := service.KeyringConfig{Current: current, Keys: make(map[uint32]string)}
+
+	for id, key := range keys {
+		config.Keys[id] = base64.StdEncoding.EncodeToString([]byte(key))
+	}
+
+	keyring, err := service.NewKeyring(config)
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating the keyring: %s", err.Error())
+	}
+
+	return keyring
+}
+
+// containsInFiles tells if any file of the directory has the text
+func containsInFiles(directory, text string) bool {
+
+	for _, name := range storeFiles(directory) {
+		if content, _ := ioutil.ReadFile(filepath.Join(directory, name)); bytes.Contains(content, []byte(text)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestEncryptedTweetStoreIsNotReadableWithoutTheKey(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	config := service.TweetStoreConfig{Keyring: newKeyring(t, 1, map[uint32]string{1: "0123456789abcdef"})}
+
+	store, tweetManager := openStore(t, directory, config)
+
+	// Operation
+	publishAndWait(t, tweetManager, "grupoesfera", "My secret gopher")
+	store.Close()
+
+	store, tweetManager = openStore(t, directory, config)
+	defer store.Close()
+
+	plainStore, _ := service.OpenTweetStore(directory, service.TweetStoreConfig{})
+	_, plainErr := plainStore.Replay(service.NewMemoryTweetRepository())
+	plainStore.Close()
+
+	// Validation
+	assertTexts(t, tweetManager.GetTweets(), "My secret gopher")
+
+	if containsInFiles(directory, "secret") {
+		t.Errorf("Expected the tweets to be encrypted on disk")
+	}
+
+	if plainErr == nil || !strings.Contains(plainErr.Error(), "encrypted") {
+		t.Errorf("Expected an error replaying without the key but was %v", plainErr)
+	}
+}
+
+func TestRotatedKeyKeepsOldSegmentsReadable(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	oldKey := map[uint32]string{1: "0123456789abcdef"}
+	bothKeys := map[uint32]string{1: "0123456789abcdef", 2: "fedcba9876543210fedcba9876543210"}
+	newKey := map[uint32]string{2: "fedcba9876543210fedcba9876543210"}
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{Keyring: newKeyring(t, 1, oldKey)})
+	publishAndWait(t, tweetManager, "grupoesfera", "With the old key")
+	store.Close()
+
+	// Operation
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{Keyring: newKeyring(t, 2, bothKeys)})
+	publishAndWait(t, tweetManager, "grupoesfera", "With the new key")
+
+	rotatedTweets := tweetManager.GetTweets()
+
+	err := store.Snapshot()
+	store.Close()
+
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{Keyring: newKeyring(t, 2, newKey)})
+	defer store.Close()
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error taking the snapshot: %s", err.Error())
+	}
+
+	assertTexts(t, rotatedTweets, "With the old key", "With the new key")
+	assertTexts(t, tweetManager.GetTweets(), "With the old key", "With the new key")
+}
+
+func TestWrongKeyFailsWhenTheStoreIsReplayed(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{Keyring: newKeyring(t, 1, map[uint32]string{1: "0123456789abcdef"})})
+	publishAndWait(t, tweetManager, "grupoesfera", "My secret gopher")
+	store.Close()
+
+	configs := map[string]service.TweetStoreConfig{
+		"is wrong":   {Keyring: newKeyring(t, 1, map[uint32]string{1: "abcdef0123456789"})},
+		"is missing": {Keyring: newKeyring(t, 2, map[uint32]string{2: "0123456789abcdef"})},
+	}
+
+	for expected, config := range configs {
+
+		// Operation
+		store, err := service.OpenTweetStore(directory, config)
+
+		if err != nil {
+			t.Fatalf("Unexpected error opening the store: %s", err.Error())
+		}
+
+		_, err = store.Replay(service.NewMemoryTweetRepository())
+		store.Close()
+
+		// Validation
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error saying the key %s but was %v", expected, err)
+		}
+	}
+}
+
+func TestPlainTweetsAreOnlyReadByAnEncryptedStoreWhileMigrating(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{})
+	publishAndWait(t, tweetManager, "grupoesfera", "Before the encryption")
+	store.Close()
+
+	keyring := newKeyring(t, 1, map[uint32]string{1: "0123456789abcdef"})
+
+	// Operation
+	encryptedStore, err := service.OpenTweetStore(directory, service.TweetStoreConfig{Keyring: keyring})
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening the store: %s", err.Error())
+	}
+
+	_, encryptedErr := encryptedStore.Replay(service.NewMemoryTweetRepository())
+	encryptedStore.Close()
+
+	keyring.SetMigratePlaintext(true)
+
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{Keyring: keyring})
+	defer store.Close()
+
+	// Validation
+	if encryptedErr == nil || !strings.Contains(encryptedErr.Error(), "plain text") {
+		t.Errorf("Expected an error replaying plain tweets with a key but was %v", encryptedErr)
+	}
+
+	assertTexts(t, tweetManager.GetTweets(), "Before the encryption")
+}
+
+func TestFileTweetWriterEncryptsEveryLine(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	config.Keyring = newKeyring(t, 1, map[uint32]string{1: "0123456789abcdef"})
+
+	writer := newFileWriter(t, config)
+	writer.WriteTweet(domain.NewTextTweet("grupoesfera", "My secret gopher"))
+	writer.Close()
+
+	// Operation
+	writer = newFileWriter(t, config)
+	writer.WriteTweet(domain.NewTextTweet("nick", "Another secret"))
+	writer.Close()
+
+	// Validation
+	path := filepath.Join(config.Directory, config.FileName)
+
+	if content, _ := ioutil.ReadFile(path); bytes.Contains(content, []byte("secret")) {
+		t.Errorf("Expected the file to be encrypted but was %s", content)
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening the file: %s", err.Error())
+	}
+
+	defer file.Close()
+
+	lines := make([]string, 0)
+
+	scanner := bufio.NewScanner(service.NewEncryptedReader(file, config.Keyring))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if scanner.Err() != nil || len(lines) != 2 || !strings.Contains(lines[0], "My secret gopher") || !strings.Contains(lines[1], "Another secret") {
+		t.Errorf("Expected the two tweets decrypted but were %v (%v)", lines, scanner.Err())
+	}
+}
+
+func TestKeyringRejectsInvalidKeys(t *testing.T) {
+
+	invalidKeys := []string{
+		"1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"1:not base64!",
+		"one:" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+		base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")),
+	}
+
+	for _, keys := range invalidKeys {
+
+		// Operation
+		_, err := service.ParseKeyring(keys)
+
+		// Validation
+		if err == nil {
+			t.Errorf("Expected error parsing keys %s", keys)
+		}
+	}
+}
+
+func TestEncryptedFileTweetWriterRotatesByTheEncryptedSize(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	config.Keyring = newKeyring(t, 1, map[uint32]string{1: "0123456789abcdef"})
+	config.MaxSize = 200
+
+	writer := newFileWriter(t, config)
+
+	// Operation
+	for n := 0; n < 20; n++ {
+		writer.WriteTweet(domain.NewTextTweet("grupoesfera", fmt.Sprintf("Tweet number %d", n)))
+	}
+
+	writer.Close()
+
+	// Validation
+	paths := append(writer.RotatedFiles(), filepath.Join(config.Directory, config.FileName))
+
+	if len(paths) < 2 {
+		t.Fatalf("Expected the file to be rotated but the files were %v", paths)
+	}
+
+	for _, path := range paths {
+		if info, _ := os.Stat(path); info.Size() > config.MaxSize {
+			t.Errorf("Expected %s to be smaller than the max size but was %d bytes", path, info.Size())
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/cursoGo/src/domain"
+)
+
+// EventHandler handles an event. The error or panic of a handler is kept in
+// the stats of its subscriber and doesn't reach the publisher nor the other
+// subscribers
+type EventHandler func(Event) error
+
+type AsyncSubscriberConfig struct {
+
+	// Workers is how many events are handled at the same time. The events
+	// of a user always go to the same worker, so they are handled in order
+	Workers int
+
+	// QueueSize is how many events every worker can have waiting. Publish
+	// waits while the queue is full
+	QueueSize int
+}
+
+func DefaultAsyncSubscriberConfig() AsyncSubscriberConfig {
+	return AsyncSubscriberConfig{
+		Workers:   4,
+		QueueSize: 256,
+	}
+}
+
+type SubscriberStats struct {
+	Name      string
+	Async     bool
+	Handled   int
+	Failed    int
+	Pending   int
+	LastError string `json:",omitempty"`
+}
+
+// EventBus sends the published events to the subscribers. Synchronous
+// subscribers handle them before Publish returns, asynchronous ones in
+// their own goroutines. Events published one after the other reach every
+// subscriber in that order, except that asynchronous subscribers only keep
+// the order of the events of the same user
+type EventBus struct {
+	mutex       sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+
+	// resolveUser returns the actual handle of a user, so the events of a
+	// user reach the same worker whatever handle they were published with
+	resolveUser func(string) string
+}
+
+type subscriber struct {
+	name    string
+	handler EventHandler
+
+	// types are the events the subscriber wants. Empty means all of them
+	types map[reflect.Type]bool
+
+	queues  []chan busItem
+	workers sync.WaitGroup
+
+	mutex sync.Mutex
+	stats SubscriberStats
+}
+
+// busItem is an event for a worker, or a marker which is closed once the
+// events queued before were handled
+type busItem struct {
+	event   Event
+	drained chan bool
+}
+
+func NewEventBus() *EventBus {
+
+	bus := new(EventBus)
+
+	bus.subscribers = make([]*subscriber, 0)
+	bus.resolveUser = func(user string) string { return user }
+
+	return bus
+}
+
+// SubscribeSync registers a handler which is called by Publish. When only
+// has events, the handler just gets the events of the same types
+func (bus *EventBus) SubscribeSync(name string, handler EventHandler, only ...Event) {
+	bus.subscribe(newSubscriber(name, handler, only))
+}
+
+// SubscribeAsync registers a handler which is called by the workers of the
+// subscriber, so a slow handler doesn't slow down the publisher until its
+// queue is full. When only has events, the handler just gets the events of
+// the same types
+func (bus *EventBus) SubscribeAsync(name string, handler EventHandler, config AsyncSubscriberConfig, only ...Event) {
+
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+
+	asyncSubscriber := newSubscriber(name, handler, only)
+	asyncSubscriber.stats.Async = true
+	asyncSubscriber.queues = make([]chan busItem, config.Workers)
+
+	for index := range asyncSubscriber.queues {
+
+		queue := make(chan busItem, config.QueueSize)
+		asyncSubscriber.queues[index] = queue
+
+		asyncSubscriber.workers.Add(1)
+		go asyncSubscriber.run(queue)
+	}
+
+	bus.subscribe(asyncSubscriber)
+}
+
+// Publish sends the event to every subscriber that wants it. Events
+// published after the bus is closed are only sent to the synchronous
+// subscribers
+func (bus *EventBus) Publish(event Event) {
+
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for _, eventSubscriber := range bus.subscribers {
+
+		if !eventSubscriber.wants(event) {
+			continue
+		}
+
+		if eventSubscriber.queues == nil {
+			eventSubscriber.handle(event)
+			continue
+		}
+
+		if bus.closed {
+			continue
+		}
+
+		eventSubscriber.mutex.Lock()
+		eventSubscriber.stats.Pending++
+		eventSubscriber.mutex.Unlock()
+
+		worker := workerOf(bus.handleOf(EventUser(event)), len(eventSubscriber.queues))
+
+		// The events of a renamed user went to the worker of its old handle,
+		// which now resolves to the new one, so they are handled before the
+		// rename and the events that follow
+		if renamed, ok := event.(UserRenamed); ok {
+			if previous := workerOf(domain.NormalizeHandle(renamed.From), len(eventSubscriber.queues)); previous != worker {
+				drained := make(chan bool)
+				eventSubscriber.queues[previous] <- busItem{drained: drained}
+				<-drained
+			}
+		}
+
+		eventSubscriber.queues[worker] <- busItem{event: event}
+	}
+}
+
+func (bus *EventBus) handleOf(user string) string {
+	return domain.NormalizeHandle(bus.resolveUser(user))
+}
+
+// Drain waits for the asynchronous subscribers to handle the events
+// published before, or for the context to be done
+func (bus *EventBus) Drain(ctx context.Context) error {
+
+	bus.mutex.RLock()
+
+	if bus.closed {
+		bus.mutex.RUnlock()
+		return nil
+	}
+
+	markers := make([]chan bool, 0)
+
+	for _, eventSubscriber := range bus.subscribers {
+		for _, queue := range eventSubscriber.queues {
+
+			drained := make(chan bool)
+			markers = append(markers, drained)
+
+			select {
+			case queue <- busItem{drained: drained}:
+			case <-ctx.Done():
+				bus.mutex.RUnlock()
+				return ctx.Err()
+			}
+		}
+	}
+
+	bus.mutex.RUnlock()
+
+	for _, drained := range markers {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Close waits for the asynchronous subscribers to handle the queued events
+// and stops their workers
+func (bus *EventBus) Close() {
+
+	bus.mutex.Lock()
+
+	if bus.closed {
+		bus.mutex.Unlock()
+		return
+	}
+
+	bus.closed = true
+
+	for _, eventSubscriber := range bus.subscribers {
+		for _, queue := range eventSubscriber.queues {
+			close(queue)
+		}
+	}
+
+	subscribers := bus.subscribers
+
+	bus.mutex.Unlock()
+
+	for _, eventSubscriber := range subscribers {
+		eventSubscriber.workers.Wait()
+	}
+}
+
+func (bus *EventBus) Stats() []SubscriberStats {
+
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	stats := make([]SubscriberStats, 0, len(bus.subscribers))
+
+	for _, eventSubscriber := range bus.subscribers {
+		eventSubscriber.mutex.Lock()
+		stats = append(stats, eventSubscriber.stats)
+		eventSubscriber.mutex.Unlock()
+	}
+
+	return stats
+}
+
+func (bus *EventBus) subscribe(eventSubscriber *subscriber) {
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.subscribers = append(bus.subscribers, eventSubscriber)
+}
+
+func newSubscriber(name string, handler EventHandler, only []Event) *subscriber {
+
+	eventSubscriber := new(subscriber)
+
+	eventSubscriber.name = name
+	eventSubscriber.handler = handler
+	eventSubscriber.types = make(map[reflect.Type]bool, len(only))
+	eventSubscriber.stats.Name = name
+
+	for _, event := range only {
+		eventSubscriber.types[reflect.TypeOf(event)] = true
+	}
+
+	return eventSubscriber
+}
+
+func (eventSubscriber *subscriber) wants(event Event) bool {
+	return len(eventSubscriber.types) == 0 || eventSubscriber.types[reflect.TypeOf(event)]
+}
+
+func (eventSubscriber *subscriber) run(queue chan busItem) {
+
+	defer eventSubscriber.workers.Done()
+
+	for item := range queue {
+
+		if item.drained != nil {
+			close(item.drained)
+			continue
+		}
+
+		eventSubscriber.handle(item.event)
+
+		eventSubscriber.mutex.Lock()
+		eventSubscriber.stats.Pending--
+		eventSubscriber.mutex.Unlock()
+	}
+}
+
+func (eventSubscriber *subscriber) handle(event Event) {
+
+	err := eventSubscriber.call(event)
+
+	eventSubscriber.mutex.Lock()
+	defer eventSubscriber.mutex.Unlock()
+
+	eventSubscriber.stats.Handled++
+
+	if err != nil {
+		eventSubscriber.stats.Failed++
+		eventSubscriber.stats.LastError = err.Error()
+	}
+}
+
+func (eventSubscriber *subscriber) call(event Event) (err error) {
+
+	defer func() {
+		if failure := recover(); failure != nil {
+			err = fmt.Errorf("subscriber %s failed: %v", eventSubscriber.name, failure)
+		}
+	}()
+
+	return eventSubscriber.handler(event)
+}
+
+func workerOf(user string, workers int) int {
+
+	hash := fnv.New32a()
+	hash.Write([]byte(user))
+
+	return int(hash.Sum32() % uint32(workers))
+}
@@ -0,0 +1,201 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func TestTweetManagerPublishesQuotesAndMentions(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	ctx := context.Background()
+
+	quoted := domain.NewTextTweet("nick", "Learning Go")
+	tweetManager.PublishTweet(ctx, quoted)
+
+	events := make([]service.Event, 0)
+
+	tweetManager.Events().SubscribeSync("quotes", func(event service.Event) error {
+		events = append(events, event)
+		return nil
+	}, service.TweetQuoted{}, service.UserMentioned{})
+
+	// Operation
+	tweetManager.PublishTweet(ctx, domain.NewQuoteTweet("grupoesfera", "Me too @nick", quoted))
+
+	// Validation
+	if len(events) != 2 {
+		t.Fatalf("Expected the quote and the mention but the events were %v", events)
+	}
+
+	if quote, ok := events[0].(service.TweetQuoted); !ok || quote.Quoted != quoted {
+		t.Errorf("Expected the quote of the tweet first but was %v", events[0])
+	}
+
+	if mention, ok := events[1].(service.UserMentioned); !ok || mention.User != "nick" {
+		t.Errorf("Expected the mention of nick but was %v", events[1])
+	}
+}
+
+func TestAsyncSubscribersKeepTheOrderOfEveryUser(t *testing.T) {
+
+	// Initialization
+	bus := service.NewEventBus()
+	defer bus.Close()
+
+	var mutex sync.Mutex
+	handled := make(map[string][]int)
+
+	bus.SubscribeAsync("recorder", func(event service.Event) error {
+
+		liked := event.(service.TweetLiked)
+
+		// Slow handlers let the workers interleave
+		time.Sleep(time.Duration(liked.Tweet.GetId()%3) * time.Microsecond)
+
+		mutex.Lock()
+		handled[liked.User] = append(handled[liked.User], liked.Tweet.GetId())
+		mutex.Unlock()
+
+		return nil
+	}, service.AsyncSubscriberConfig{Workers: 4, QueueSize: 8})
+
+	// Operation
+	for id := 0; id < 200; id++ {
+		tweet := domain.NewTextTweet("grupoesfera", "Liked tweet")
+		tweet.SetId(id)
+		bus.Publish(service.TweetLiked{User: "user" + strconv.Itoa(id%5), Tweet: tweet})
+	}
+
+	err := bus.Drain(context.Background())
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	for user, ids := range handled {
+
+		if len(ids) != 40 {
+			t.Errorf("Expected 40 events of %s but were %d", user, len(ids))
+		}
+
+		for index := 1; index < len(ids); index++ {
+			if ids[index] < ids[index-1] {
+				t.Fatalf("Expected the events of %s in order but were %v", user, ids)
+			}
+		}
+	}
+
+	if stats := bus.Stats()[0]; stats.Handled != 200 || stats.Pending != 0 || !stats.Async {
+		t.Errorf("Expected every event to be handled but the stats were %+v", stats)
+	}
+}
+
+func TestAsyncSubscribersKeepTheOrderOfRenamedUsers(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+	defer tweetManager.Events().Close()
+
+	var mutex sync.Mutex
+	handled := make([]string, 0)
+
+	tweetManager.Events().SubscribeAsync("recorder", func(event service.Event) error {
+
+		record := "renamed"
+
+		if published, ok := event.(service.TweetPublished); ok {
+			// The tweets before the rename are slower, to be overtaken
+			// if they were in another worker
+			if published.Tweet.GetText() == "Before" {
+				time.Sleep(time.Millisecond)
+			}
+			record = published.Tweet.GetText()
+		}
+
+		mutex.Lock()
+		handled = append(handled, record)
+		mutex.Unlock()
+
+		return nil
+	}, service.AsyncSubscriberConfig{Workers: 16, QueueSize: 8}, service.TweetPublished{}, service.UserRenamed{})
+
+	ctx := context.Background()
+
+	// Operation
+	for n := 0; n < 5; n++ {
+		tweetManager.PublishTweet(ctx, domain.NewTextTweet("@Nick", "Before"))
+	}
+
+	tweetManager.RenameUser("nick", "nickname")
+
+	for n := 0; n < 5; n++ {
+		tweetManager.PublishTweet(ctx, domain.NewTextTweet("NICKNAME", "After"))
+	}
+
+	err := tweetManager.Events().Drain(ctx)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	expected := []string{"Before", "Before", "Before", "Before", "Before", "renamed", "After", "After", "After", "After", "After"}
+
+	if fmt.Sprint(handled) != fmt.Sprint(expected) {
+		t.Errorf("Expected the events in the order they happened but were %v", handled)
+	}
+}
+
+func TestFailingSubscribersDontStopTheOthers(t *testing.T) {
+
+	// Initialization
+	bus := service.NewEventBus()
+	defer bus.Close()
+
+	received := 0
+
+	bus.SubscribeSync("panicking", func(event service.Event) error {
+		panic("index is broken")
+	})
+
+	bus.SubscribeAsync("failing", func(event service.Event) error {
+		return fmt.Errorf("stream is closed")
+	}, service.DefaultAsyncSubscriberConfig())
+
+	bus.SubscribeSync("counter", func(event service.Event) error {
+		received++
+		return nil
+	})
+
+	// Operation
+	bus.Publish(service.UserFollowed{Follower: "grupoesfera", Followed: "nick"})
+	bus.Publish(service.UserUnfollowed{Follower: "grupoesfera", Followed: "nick"})
+
+	bus.Drain(context.Background())
+
+	// Validation
+	if received != 2 {
+		t.Errorf("Expected the counter to receive every event but received %d", received)
+	}
+
+	stats := bus.Stats()
+
+	if stats[0].Failed != 2 || stats[0].LastError != "subscriber panicking failed: index is broken" {
+		t.Errorf("Expected the panics to be counted but the stats were %+v", stats[0])
+	}
+
+	if stats[1].Failed != 2 || stats[1].LastError != "stream is closed" {
+		t.Errorf("Expected the errors to be counted but the stats were %+v", stats[1])
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/cursoGo/src/domain"
+)
+
+type Event interface{}
+
+type TweetPublished struct {
+	Tweet domain.Tweet
+}
+
+type TweetDeleted struct {
+	Tweet domain.Tweet
+}
+
+type TweetQuoted struct {
+	Tweet  domain.Tweet
+	Quoted domain.Tweet
+}
+
+// UserMentioned is sent after the TweetPublished of a tweet for every user
+// mentioned in its text, whether the user exists or not. User is the
+// handle the mention resolves to and Reply tells if the tweet replies to
+// the user
+type UserMentioned struct {
+	User  string
+	Tweet domain.Tweet
+	Reply bool
+}
+
+type UserFollowed struct {
+	Follower string
+	Followed string
+}
+
+type UserUnfollowed struct {
+	Follower string
+	Followed string
+}
+
+type TweetLiked struct {
+	User  string
+	Tweet domain.Tweet
+}
+
+type UserRenamed struct {
+	From string
+	To   string
+}
+
+// EventUser returns the user who caused the event, which decides the order
+// of the events in asynchronous subscribers. A rename is from the new handle
+// of the user, like the events that follow it
+func EventUser(event Event) string {
+
+	switch event := event.(type) {
+	case TweetPublished:
+		return event.Tweet.GetUser()
+	case TweetDeleted:
+		return event.Tweet.GetUser()
+	case TweetQuoted:
+		return event.Tweet.GetUser()
+	case UserMentioned:
+		return event.Tweet.GetUser()
+	case UserFollowed:
+		return event.Follower
+	case UserUnfollowed:
+		return event.Follower
+	case TweetLiked:
+		return event.User
+	case UserRenamed:
+		return event.To
+	}
+
+	return ""
+}
+
+func (manager *TweetManager) Events() *EventBus {
+	return manager.events
+}
+
+// Subscribe registers a listener which is called after every event. Events
+// are received one at a time in the order they happened. Listeners can read
+// from the manager but must not change it
+func (manager *TweetManager) Subscribe(listener func(Event)) {
+	manager.events.SubscribeSync(fmt.Sprintf("listener %d", len(manager.events.Stats())+1), func(event Event) error {
+		listener(event)
+		return nil
+	})
+}
+
+func (manager *TweetManager) publishEvent(event Event) {
+	manager.pendingEvents = append(manager.pendingEvents, event)
+}
+
+func (manager *TweetManager) lock() {
+	manager.mutex.Lock()
+}
+
+// unlock unlocks the manager and publishes in the bus the events of the
+// change. Events wait for the events of the previous changes to be
+// published, so every subscriber gets them in order
+func (manager *TweetManager) unlock() {
+	manager.unlockAfter(nil)
+}
+
+// unlockAfter unlocks the manager and, in the turn of the events of the
+// change, calls deliver before publishing them
+func (manager *TweetManager) unlockAfter(deliver func()) {
+
+	events := manager.pendingEvents
+	manager.pendingEvents = nil
+
+	if len(events) == 0 && deliver == nil {
+		manager.mutex.Unlock()
+		return
+	}
+
+	turn := manager.nextEvent
+	manager.nextEvent++
+
+	manager.mutex.Unlock()
+
+	manager.eventTurn.L.Lock()
+	for manager.deliveredEvents != turn {
+		manager.eventTurn.Wait()
+	}
+	manager.eventTurn.L.Unlock()
+
+	defer func() {
+		manager.eventTurn.L.Lock()
+		manager.deliveredEvents++
+		manager.eventTurn.Broadcast()
+		manager.eventTurn.L.Unlock()
+	}()
+
+	if deliver != nil {
+		deliver()
+	}
+
+	for _, event := range events {
+		manager.events.Publish(event)
+	}
+}
@@ -0,0 +1,485 @@
+package service
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const rotatedLayout = "2006-01-02T15-04-05.000"
+
+type FileTweetWriterConfig struct {
+	Directory string
+	FileName  string
+
+	// MaxSize is how many bytes the file can have before being rotated.
+	// Zero disables the rotation by size
+	MaxSize int64
+
+	Daily bool
+
+	Compress bool
+
+	// MaxFiles is how many rotated files are kept. Zero keeps all of them
+	MaxFiles int
+
+	// Keyring encrypts every line, which is then read with an
+	// EncryptedReader. Encrypted files aren't compressed, as they wouldn't
+	// get smaller
+	Keyring *Keyring
+}
+
+func DefaultFileTweetWriterConfig() FileTweetWriterConfig {
+	return FileTweetWriterConfig{
+		Directory: ".",
+		FileName:  "tweets.txt",
+		MaxSize:   10 << 20,
+		Daily:     true,
+		Compress:  true,
+		MaxFiles:  30,
+	}
+}
+
+// FileTweetWriter writes the tweets to a text file which is rotated by size
+// or day. Rotated files get the time of the rotation in their name
+type FileTweetWriter struct {
+	config FileTweetWriterConfig
+	clock  func() time.Time
+
+	// mutex makes writes wait while the file is rotated, so no tweet is
+	// lost or written twice
+	mutex  sync.Mutex
+	file   *os.File
+	output io.Writer
+	size   int64
+	day    string
+	closed bool
+
+	// cleanup lets only one rotated file be compressed and pruned at a time
+	cleanup      sync.Mutex
+	compressions sync.WaitGroup
+}
+
+func NewFileTweetWriter(config FileTweetWriterConfig) (*FileTweetWriter, error) {
+
+	writer := new(FileTweetWriter)
+
+	writer.config = config
+	writer.clock = time.Now
+
+	if err := os.MkdirAll(config.Directory, 0777); err != nil {
+		return nil, fmt.Errorf("tweet directory couldn't be created: %s", err.Error())
+	}
+
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (writer *FileTweetWriter) SetClock(clock func() time.Time) {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.clock = clock
+	writer.day = writer.fileDay()
+}
+
+func (writer *FileTweetWriter) WriteTweet(tweet domain.Tweet) error {
+	return writer.WriteTweets([]domain.Tweet{tweet})
+}
+
+// WriteTweets writes the lines of the tweets together, except when the
+// file has to be rotated between them. If the file couldn't be opened, it
+// is opened again first. A batch that fails is removed from the files, so
+// retrying it doesn't write its tweets twice
+func (writer *FileTweetWriter) WriteTweets(tweets []domain.Tweet) error {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	if writer.closed {
+		return fmt.Errorf("tweet file %s is closed", writer.path())
+	}
+
+	if writer.file == nil {
+		if err := writer.open(); err != nil {
+			return err
+		}
+	}
+
+	start := writer.size
+
+	rotated, err := writer.writeBatch(tweets)
+
+	if err != nil {
+		rotated, err = writer.removeBatch(start, rotated, err)
+	}
+
+	writer.cleanUp(rotated)
+
+	return err
+}
+
+func (writer *FileTweetWriter) writeBatch(tweets []domain.Tweet) ([]string, error) {
+
+	rotated := make([]string, 0)
+	lines := make([]byte, 0)
+
+	for _, tweet := range tweets {
+
+		line := []byte(tweet.PrintableTweet() + "\n")
+
+		if writer.mustRotate(len(lines), len(line)) {
+
+			if err := writer.writeLines(lines); err != nil {
+				return rotated, err
+			}
+
+			lines = lines[:0]
+
+			rotatedPath, err := writer.rotate()
+
+			if rotatedPath != "" {
+				rotated = append(rotated, rotatedPath)
+			}
+
+			if err != nil {
+				return rotated, err
+			}
+		}
+
+		lines = append(lines, line...)
+	}
+
+	return rotated, writer.writeLines(lines)
+}
+
+// removeBatch truncates the file where a failed batch started back to the
+// start of the batch. The files created after it only have lines of the
+// batch, so they are removed. It returns the rotated files which are kept
+func (writer *FileTweetWriter) removeBatch(start int64, rotated []string, err error) ([]string, error) {
+
+	var removeErr error
+
+	fail := func(failed error) {
+		if removeErr == nil {
+			removeErr = failed
+		}
+	}
+
+	current := start
+
+	for index, path := range rotated {
+		if index == 0 && start > 0 {
+			fail(os.Truncate(path, start))
+		} else {
+			fail(os.Remove(path))
+		}
+	}
+
+	if len(rotated) > 0 {
+		current = 0
+	}
+
+	if writer.file != nil {
+		fail(writer.file.Truncate(current))
+		writer.size = current
+	} else if truncateErr := os.Truncate(writer.path(), current); !os.IsNotExist(truncateErr) {
+		fail(truncateErr)
+	}
+
+	if len(rotated) > 0 && start > 0 {
+		rotated = rotated[:1]
+	} else {
+		rotated = nil
+	}
+
+	if removeErr != nil {
+		return rotated, fmt.Errorf("%s and the tweets written couldn't be removed: %s", err.Error(), removeErr.Error())
+	}
+
+	return rotated, err
+}
+
+func (writer *FileTweetWriter) writeLines(lines []byte) error {
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if _, err := writer.output.Write(lines); err != nil {
+		return fmt.Errorf("tweets couldn't be written to %s: %s", writer.path(), err.Error())
+	}
+
+	return nil
+}
+
+func (writer *FileTweetWriter) Close() error {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.compressions.Wait()
+
+	writer.closed = true
+
+	if writer.file == nil {
+		return nil
+	}
+
+	err := writer.file.Sync()
+
+	if closeErr := writer.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	writer.file = nil
+
+	return err
+}
+
+func (writer *FileTweetWriter) path() string {
+	return filepath.Join(writer.config.Directory, writer.config.FileName)
+}
+
+// open opens the file to append to it. If it fails, the file stays closed
+// until the next write opens it
+func (writer *FileTweetWriter) open() error {
+
+	file, err := os.OpenFile(writer.path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+
+	if err != nil {
+		writer.file = nil
+		return fmt.Errorf("tweet file couldn't be opened: %s", err.Error())
+	}
+
+	writer.file = file
+	writer.output = fileSizeWriter{writer}
+	writer.size = 0
+
+	if writer.config.Keyring != nil {
+		writer.output = NewEncryptedWriter(writer.output, writer.config.Keyring)
+	}
+
+	writer.day = writer.clock().Format(dayLayout)
+
+	if info, err := file.Stat(); err == nil {
+		writer.size = info.Size()
+		if writer.size > 0 {
+			writer.day = writer.fileDay()
+		}
+	}
+
+	return nil
+}
+
+func (writer *FileTweetWriter) fileDay() string {
+
+	if info, err := os.Stat(writer.path()); err == nil && info.Size() > 0 {
+		return info.ModTime().In(writer.clock().Location()).Format(dayLayout)
+	}
+
+	return writer.clock().Format(dayLayout)
+}
+
+func (writer *FileTweetWriter) mustRotate(pending, length int) bool {
+
+	if writer.size == 0 && pending == 0 {
+		return false
+	}
+
+	if writer.config.MaxSize > 0 && writer.size+writer.writtenSize(pending+length) > writer.config.MaxSize {
+		return true
+	}
+
+	return writer.config.Daily && writer.clock().Format(dayLayout) != writer.day
+}
+
+// writtenSize returns how many bytes take lines of the length in the file.
+// Encrypted lines are written as one block with its length
+func (writer *FileTweetWriter) writtenSize(length int) int64 {
+
+	if writer.config.Keyring == nil {
+		return int64(length)
+	}
+
+	return int64(4 + writer.config.Keyring.sealedSize(length))
+}
+
+// fileSizeWriter writes to the file of the writer, counting in its size the
+// bytes actually written
+type fileSizeWriter struct {
+	writer *FileTweetWriter
+}
+
+func (sizeWriter fileSizeWriter) Write(data []byte) (int, error) {
+
+	written, err := sizeWriter.writer.file.Write(data)
+
+	sizeWriter.writer.size += int64(written)
+
+	return written, err
+}
+
+// rotate renames the file and opens a new one, returning the path of the
+// renamed file. If it can't be renamed, the writer keeps appending to it
+func (writer *FileTweetWriter) rotate() (string, error) {
+
+	writer.file.Close()
+
+	rotatedPath := writer.rotatedPath()
+
+	if err := os.Rename(writer.path(), rotatedPath); err != nil {
+		return "", writer.open()
+	}
+
+	return rotatedPath, writer.open()
+}
+
+func (writer *FileTweetWriter) cleanUp(rotated []string) {
+
+	if len(rotated) == 0 {
+		return
+	}
+
+	writer.compressions.Add(1)
+
+	go func() {
+
+		defer writer.compressions.Done()
+
+		writer.cleanup.Lock()
+		defer writer.cleanup.Unlock()
+
+		if writer.config.Compress && writer.config.Keyring == nil {
+			for _, path := range rotated {
+				compressFile(path)
+			}
+		}
+
+		writer.removeOldFiles()
+	}()
+}
+
+func (writer *FileTweetWriter) rotatedPath() string {
+
+	extension := filepath.Ext(writer.config.FileName)
+	base := strings.TrimSuffix(writer.config.FileName, extension)
+	stamp := writer.clock().Format(rotatedLayout)
+
+	path := filepath.Join(writer.config.Directory, fmt.Sprintf("%s-%s%s", base, stamp, extension))
+
+	for number := 1; fileExists(path) || fileExists(path+".gz"); number++ {
+		path = filepath.Join(writer.config.Directory, fmt.Sprintf("%s-%s.%03d%s", base, stamp, number, extension))
+	}
+
+	return path
+}
+
+func (writer *FileTweetWriter) removeOldFiles() {
+
+	if writer.config.MaxFiles <= 0 {
+		return
+	}
+
+	rotated := writer.RotatedFiles()
+
+	for len(rotated) > writer.config.MaxFiles {
+		os.Remove(rotated[0])
+		rotated = rotated[1:]
+	}
+}
+
+func (writer *FileTweetWriter) RotatedFiles() []string {
+
+	extension := filepath.Ext(writer.config.FileName)
+	prefix := strings.TrimSuffix(writer.config.FileName, extension) + "-"
+
+	files, _ := ioutil.ReadDir(writer.config.Directory)
+
+	rotated := make([]string, 0)
+
+	for _, file := range files {
+
+		name := strings.TrimSuffix(file.Name(), ".gz")
+
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, extension) {
+			rotated = append(rotated, filepath.Join(writer.config.Directory, file.Name()))
+		}
+	}
+
+	// Files rotated at the same time have a number before the extension and
+	// go after the first one
+	sortKey := func(path string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), extension)
+	}
+
+	sort.Slice(rotated, func(i, j int) bool {
+		return sortKey(rotated[i]) < sortKey(rotated[j])
+	})
+
+	return rotated
+}
+
+// compressFile replaces the file with a gzipped copy. The copy is written
+// with a temporary name, so a half compressed file never replaces it
+func compressFile(path string) error {
+
+	source, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	temporaryPath := path + ".gz" + temporarySuffix
+
+	target, err := os.Create(temporaryPath)
+
+	if err != nil {
+		return err
+	}
+
+	compressor := gzip.NewWriter(target)
+
+	_, err = io.Copy(compressor, source)
+
+	if err == nil {
+		err = compressor.Close()
+	}
+
+	if err == nil {
+		err = target.Sync()
+	}
+
+	target.Close()
+
+	if err == nil {
+		err = os.Rename(temporaryPath, path+".gz")
+	}
+
+	if err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
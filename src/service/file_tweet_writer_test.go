@@ -0,0 +1,247 @@
+package service_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func tempWriterConfig(t *testing.T) (service.FileTweetWriterConfig, func()) {
+
+	directory, err := ioutil.TempDir("", "tweetwriter")
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	config := service.FileTweetWriterConfig{
+		Directory: filepath.Join(directory, "output"),
+		FileName:  "tweets.txt",
+	}
+
+	return config, func() { os.RemoveAll(directory) }
+}
+
+func newFileWriter(t *testing.T, config service.FileTweetWriterConfig) *service.FileTweetWriter {
+
+	writer, err := service.NewFileTweetWriter(config)
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating the writer: %s", err.Error())
+	}
+
+	return writer
+}
+
+// writtenLines returns the lines of the current file and the rotated ones,
+// uncompressing them if needed
+func writtenLines(t *testing.T, writer *service.FileTweetWriter, config service.FileTweetWriterConfig) []string {
+
+	lines := make([]string, 0)
+
+	paths := append(writer.RotatedFiles(), filepath.Join(config.Directory, config.FileName))
+
+	for _, path := range paths {
+
+		file, err := os.Open(path)
+
+		if err != nil {
+			t.Fatalf("Unexpected error opening %s: %s", path, err.Error())
+		}
+
+		var reader io.Reader = file
+
+		if strings.HasSuffix(path, ".gz") {
+			if reader, err = gzip.NewReader(file); err != nil {
+				t.Fatalf("Unexpected error uncompressing %s: %s", path, err.Error())
+			}
+		}
+
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+
+		file.Close()
+	}
+
+	return lines
+}
+
+func TestFileTweetWriterRotatesBySizeWithoutLosingTweets(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	config.MaxSize = 200
+	config.Compress = true
+
+	writer := newFileWriter(t, config)
+	channelWriter := service.NewChannelTweetWriter(writer)
+
+	ctx := context.Background()
+
+	var writers sync.WaitGroup
+
+	// Operation
+	for n := 0; n < 300; n++ {
+
+		writers.Add(1)
+
+		go func(n int) {
+			defer writers.Done()
+			if ack, err := channelWriter.Enqueue(ctx, domain.NewTextTweet("grupoesfera", fmt.Sprintf("Tweet number %d", n))); err == nil {
+				ack.Wait(ctx)
+			}
+		}(n)
+	}
+
+	writers.Wait()
+
+	writer.Close()
+
+	// Validation
+	lines := writtenLines(t, writer, config)
+	seen := make(map[string]bool)
+
+	for _, line := range lines {
+		if seen[line] {
+			t.Fatalf("Expected every tweet once but %s was written twice", line)
+		}
+		seen[line] = true
+	}
+
+	if len(seen) != 300 {
+		t.Errorf("Expected 300 tweets but were %d", len(seen))
+	}
+
+	rotated := writer.RotatedFiles()
+
+	if len(rotated) < 2 {
+		t.Fatalf("Expected the file to be rotated but the rotated files were %v", rotated)
+	}
+
+	for _, path := range rotated {
+
+		info, _ := os.Stat(path)
+
+		if !strings.HasSuffix(path, ".txt.gz") {
+			t.Errorf("Expected %s to be compressed", path)
+		}
+
+		if info.Size() > 200 {
+			t.Errorf("Expected %s to be smaller than the max size", path)
+		}
+	}
+}
+
+func TestFileTweetWriterRotatesDaily(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	config.Daily = true
+
+	now := time.Date(2017, 11, 3, 23, 59, 0, 0, time.Local)
+
+	writer := newFileWriter(t, config)
+	writer.SetClock(func() time.Time { return now })
+
+	writer.WriteTweet(domain.NewTextTweet("grupoesfera", "Before midnight"))
+	writer.WriteTweet(domain.NewTextTweet("grupoesfera", "Still before midnight"))
+
+	// Operation
+	now = now.Add(2 * time.Minute)
+	writer.WriteTweet(domain.NewTextTweet("grupoesfera", "After midnight"))
+
+	writer.Close()
+
+	// Validation
+	rotated := writer.RotatedFiles()
+
+	if len(rotated) != 1 || filepath.Base(rotated[0]) != "tweets-2017-11-04T00-01-00.000.txt" {
+		t.Fatalf("Expected one file rotated at midnight but were %v", rotated)
+	}
+
+	content, _ := ioutil.ReadFile(rotated[0])
+
+	if string(content) != "@grupoesfera: Before midnight\n@grupoesfera: Still before midnight\n" {
+		t.Errorf("Expected the tweets of the first day in the rotated file but was %q", content)
+	}
+
+	current, _ := ioutil.ReadFile(filepath.Join(config.Directory, config.FileName))
+
+	if string(current) != "@grupoesfera: After midnight\n" {
+		t.Errorf("Expected the tweets of the new day in the file but was %q", current)
+	}
+}
+
+func TestFileTweetWriterKeepsOnlyMaxFiles(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	config.MaxSize = 1
+	config.MaxFiles = 2
+
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.Local)
+
+	writer := newFileWriter(t, config)
+	writer.SetClock(func() time.Time { return now })
+
+	// Operation
+	for n := 0; n < 6; n++ {
+		writer.WriteTweet(domain.NewTextTweet("grupoesfera", fmt.Sprintf("Tweet %d", n)))
+	}
+
+	writer.Close()
+
+	// Validation
+	lines := writtenLines(t, writer, config)
+
+	if strings.Join(lines, ",") != "@grupoesfera: Tweet 3,@grupoesfera: Tweet 4,@grupoesfera: Tweet 5" {
+		t.Errorf("Expected only the newest rotated files to be kept but the tweets were %v", lines)
+	}
+
+	if rotated := writer.RotatedFiles(); filepath.Base(rotated[1]) != "tweets-2017-11-03T12-00-00.000.004.txt" {
+		t.Errorf("Expected the files rotated at the same time to be numbered but were %v", rotated)
+	}
+}
+
+func TestFileTweetWriterAppendsToTheExistingFile(t *testing.T) {
+
+	// Initialization
+	config, remove := tempWriterConfig(t)
+	defer remove()
+
+	writer := newFileWriter(t, config)
+	writer.WriteTweet(domain.NewTextTweet("grupoesfera", "Before restart"))
+	writer.Close()
+
+	// Operation
+	writer = newFileWriter(t, config)
+	writer.WriteTweet(domain.NewTextTweet("grupoesfera", "After restart"))
+	writer.Close()
+
+	// Validation
+	lines := writtenLines(t, writer, config)
+
+	if len(lines) != 2 {
+		t.Errorf("Expected the tweets before the restart to be kept but were %v", lines)
+	}
+}
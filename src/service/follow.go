@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cursoGo/src/domain"
+)
+
+func (manager *TweetManager) Follow(follower, followed string) error {
+
+	manager.lock()
+	defer manager.unlock()
+
+	followerHandle, followedHandle, err := manager.resolveFollow(follower, followed)
+
+	if err != nil {
+		return err
+	}
+
+	if manager.following[followerHandle][followedHandle] {
+		return fmt.Errorf("user %s already follows %s", follower, followed)
+	}
+
+	if manager.following[followerHandle] == nil {
+		manager.following[followerHandle] = make(map[string]bool)
+	}
+
+	manager.following[followerHandle][followedHandle] = true
+
+	manager.publishEvent(UserFollowed{followerHandle, followedHandle})
+
+	return nil
+}
+
+func (manager *TweetManager) Unfollow(follower, followed string) error {
+
+	manager.lock()
+	defer manager.unlock()
+
+	followerHandle, followedHandle, err := manager.resolveFollow(follower, followed)
+
+	if err != nil {
+		return err
+	}
+
+	if !manager.following[followerHandle][followedHandle] {
+		return fmt.Errorf("user %s does not follow %s", follower, followed)
+	}
+
+	delete(manager.following[followerHandle], followedHandle)
+
+	manager.publishEvent(UserUnfollowed{followerHandle, followedHandle})
+
+	return nil
+}
+
+func (manager *TweetManager) GetFollowing(user string) []string {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	following := make([]string, 0)
+
+	for followed := range manager.following[manager.resolveUser(user)] {
+		following = append(following, followed)
+	}
+
+	sort.Strings(following)
+
+	return following
+}
+
+func (manager *TweetManager) GetFollowers(user string) []string {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	handle := manager.resolveUser(user)
+
+	followers := make([]string, 0)
+
+	for follower, following := range manager.following {
+		if following[handle] {
+			followers = append(followers, follower)
+		}
+	}
+
+	sort.Strings(followers)
+
+	return followers
+}
+
+func (manager *TweetManager) resolveFollow(follower, followed string) (string, string, error) {
+
+	followerHandle := manager.resolveUser(follower)
+	followedHandle := manager.resolveUser(followed)
+
+	for _, handle := range []string{followerHandle, followedHandle} {
+		if !manager.isRegistered(handle) {
+			return "", "", fmt.Errorf("user %s does not exist", handle)
+		}
+	}
+
+	if followerHandle == followedHandle {
+		return "", "", fmt.Errorf("user %s can't follow itself", domain.NormalizeHandle(follower))
+	}
+
+	return followerHandle, followedHandle, nil
+}
@@ -0,0 +1,81 @@
+package service_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func TestUserCanFollowAnotherUser(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "This is my first tweet"))
+
+	// Operation
+	err := tweetManager.Follow("nick", "grupoesfera")
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	if following := tweetManager.GetFollowing("nick"); !reflect.DeepEqual(following, []string{"grupoesfera"}) {
+		t.Errorf("Expected following is [grupoesfera] but was %v", following)
+	}
+
+	if followers := tweetManager.GetFollowers("grupoesfera"); !reflect.DeepEqual(followers, []string{"nick"}) {
+		t.Errorf("Expected followers is [nick] but was %v", followers)
+	}
+}
+
+func TestUserCantFollowAnUnknownUser(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+
+	// Operation
+	err := tweetManager.Follow("nick", "grupoesfera")
+
+	// Validation
+	if err == nil || err.Error() != "user grupoesfera does not exist" {
+		t.Errorf("Expected error is user grupoesfera does not exist but was %v", err)
+	}
+}
+
+func TestUserCanUnfollowAnotherUser(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "This is my first tweet"))
+	tweetManager.Follow("nick", "grupoesfera")
+
+	// Operation
+	err := tweetManager.Unfollow("nick", "grupoesfera")
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	if following := tweetManager.GetFollowing("nick"); len(following) != 0 {
+		t.Errorf("Expected following is empty but was %v", following)
+	}
+}
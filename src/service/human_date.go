@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{dayLayout, "02/01/2006"}
+
+var relativeDays = map[string]int{
+	"today":     0,
+	"hoy":       0,
+	"yesterday": -1,
+	"ayer":      -1,
+	"tomorrow":  1,
+	"mañana":    1,
+}
+
+// ParseDate parses dates written by people like "yesterday", "3 days ago",
+// "2017-11-03" or "03/11/2017". It returns the start of the day, relative
+// to now for the relative dates
+func ParseDate(text string, now time.Time) (time.Time, error) {
+
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	today := startOfDay(now)
+
+	if offset, found := relativeDays[text]; found {
+		return today.AddDate(0, 0, offset), nil
+	}
+
+	if fields := strings.Fields(text); len(fields) == 3 && fields[2] == "ago" {
+
+		amount, err := strconv.Atoi(fields[0])
+
+		if err == nil && amount >= 0 {
+			switch strings.TrimSuffix(fields[1], "s") {
+			case "day":
+				return today.AddDate(0, 0, -amount), nil
+			case "week":
+				return today.AddDate(0, 0, -7*amount), nil
+			case "month":
+				return today.AddDate(0, -amount, 0), nil
+			case "year":
+				return today.AddDate(-amount, 0, 0), nil
+			}
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, text, now.Location()); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %s, use a date like yesterday, 3 days ago or 2017-11-03", text)
+}
+
+func startOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
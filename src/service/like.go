@@ -0,0 +1,54 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+)
+
+func (manager *TweetManager) LikeTweet(user string, id int) error {
+
+	manager.lock()
+	defer manager.unlock()
+
+	handle := manager.resolveUser(user)
+
+	if !manager.isRegistered(handle) {
+		return fmt.Errorf("user %s does not exist", handle)
+	}
+
+	tweet := manager.tweetById(id)
+
+	if tweet == nil {
+		return fmt.Errorf("tweet %d does not exist", id)
+	}
+
+	if manager.likes[id][handle] {
+		return fmt.Errorf("user %s already likes tweet %d", handle, id)
+	}
+
+	if manager.likes[id] == nil {
+		manager.likes[id] = make(map[string]bool)
+	}
+
+	manager.likes[id][handle] = true
+
+	manager.publishEvent(TweetLiked{handle, tweet})
+
+	return nil
+}
+
+func (manager *TweetManager) GetLikes(id int) []string {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	users := make([]string, 0)
+
+	for user := range manager.likes[id] {
+		users = append(users, user)
+	}
+
+	sort.Strings(users)
+
+	return users
+}
@@ -0,0 +1,36 @@
+package service_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+)
+
+func TestUserCanLikeATweetOnce(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	ctx := context.Background()
+
+	id, _ := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "This is my tweet"))
+
+	// Operation
+	err := tweetManager.LikeTweet("nick", id)
+	secondErr := tweetManager.LikeTweet("nick", id)
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+
+	if secondErr == nil {
+		t.Error("Expected error liking the tweet twice")
+	}
+
+	if likes := tweetManager.GetLikes(id); !reflect.DeepEqual(likes, []string{"nick"}) {
+		t.Errorf("Expected likes are [nick] but were %v", likes)
+	}
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+type SinkStatus struct {
+	Name string
+
+	// Healthy is false when the last write failed or the queue is full
+	Healthy bool
+
+	Lag int
+
+	Written      int
+	Dropped      int
+	Rejected     int
+	DeadLettered int
+
+	// Failures counts the writes that failed, retries included
+	Failures  int
+	LastWrite time.Time
+	LastError string `json:",omitempty"`
+}
+
+// DefaultSinkConfig drops the tweets when the queue of a sink is full, so a
+// slow sink doesn't hold up the others
+func DefaultSinkConfig() ChannelTweetWriterConfig {
+
+	config := DefaultChannelTweetWriterConfig()
+	config.Overflow = DropOnOverflow
+
+	return config
+}
+
+// MultiTweetWriter sends every tweet to many sinks. Each sink has its own
+// queue and goroutine, so a slow or failing sink doesn't stop the others
+// from being written. A sink with BlockOnOverflow holds up the others only
+// while its queue is full
+type MultiTweetWriter struct {
+	mutex sync.RWMutex
+	sinks []*tweetSink
+}
+
+type tweetSink struct {
+	name     string
+	required bool
+	writer   *sinkWriter
+	queue    *ChannelTweetWriter
+}
+
+func NewMultiTweetWriter() *MultiTweetWriter {
+
+	multiWriter := new(MultiTweetWriter)
+
+	multiWriter.sinks = make([]*tweetSink, 0)
+
+	return multiWriter
+}
+
+func (multiWriter *MultiTweetWriter) AddSink(name string, writer TweetWriter, config ChannelTweetWriterConfig) error {
+	return multiWriter.addSink(name, writer, config, false)
+}
+
+// AddRequiredSink adds a sink whose writes are waited for, like the store
+// that keeps the tweets. Its errors are returned by the writes, once it
+// retried them and kept them as dead letters
+func (multiWriter *MultiTweetWriter) AddRequiredSink(name string, writer TweetWriter, config ChannelTweetWriterConfig) error {
+	return multiWriter.addSink(name, writer, config, true)
+}
+
+func (multiWriter *MultiTweetWriter) addSink(name string, writer TweetWriter, config ChannelTweetWriterConfig, required bool) error {
+
+	multiWriter.mutex.Lock()
+	defer multiWriter.mutex.Unlock()
+
+	for _, sink := range multiWriter.sinks {
+		if sink.name == name {
+			return fmt.Errorf("sink %s already exists", name)
+		}
+	}
+
+	wrapped := newSinkWriter(writer)
+
+	multiWriter.sinks = append(multiWriter.sinks, &tweetSink{
+		name:     name,
+		required: required,
+		writer:   wrapped,
+		queue:    NewChannelTweetWriterWithConfig(wrapped, config),
+	})
+
+	return nil
+}
+
+func (multiWriter *MultiTweetWriter) WriteTweet(tweet domain.Tweet) error {
+	return multiWriter.WriteTweets([]domain.Tweet{tweet})
+}
+
+// WriteTweets queues the tweets in every sink following the overflow
+// policy of the sink, and waits for the required sinks to write them. The
+// sinks retry on their own, so the error of a required sink is final and
+// the tweets shouldn't be written again
+func (multiWriter *MultiTweetWriter) WriteTweets(tweets []domain.Tweet) error {
+
+	multiWriter.mutex.RLock()
+	defer multiWriter.mutex.RUnlock()
+
+	ctx := context.Background()
+
+	acks := make([]*WriteAck, 0)
+	ackSinks := make([]string, 0)
+
+	for _, sink := range multiWriter.sinks {
+		for _, tweet := range tweets {
+
+			ack, err := sink.queue.Enqueue(ctx, tweet)
+
+			if err != nil && sink.required {
+				return fmt.Errorf("sink %s: %s", sink.name, err.Error())
+			}
+
+			if sink.required {
+				acks = append(acks, ack)
+				ackSinks = append(ackSinks, sink.name)
+			}
+		}
+	}
+
+	for index, ack := range acks {
+		if err := ack.Wait(ctx); err != nil {
+			return fmt.Errorf("sink %s: %s", ackSinks[index], err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Flush waits for the tweets queued before in every sink to be written, or
+// for the context to be done
+func (multiWriter *MultiTweetWriter) Flush(ctx context.Context) error {
+
+	multiWriter.mutex.RLock()
+	defer multiWriter.mutex.RUnlock()
+
+	for _, sink := range multiWriter.sinks {
+		if err := sink.queue.Flush(ctx); err != nil {
+			return fmt.Errorf("sink %s couldn't be flushed: %s", sink.name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Close writes the queued tweets of every sink and stops their queues. The
+// writers of the sinks aren't closed
+func (multiWriter *MultiTweetWriter) Close() {
+
+	multiWriter.mutex.RLock()
+	defer multiWriter.mutex.RUnlock()
+
+	for _, sink := range multiWriter.sinks {
+		sink.queue.Close()
+	}
+}
+
+func (multiWriter *MultiTweetWriter) Status() []SinkStatus {
+
+	multiWriter.mutex.RLock()
+	defer multiWriter.mutex.RUnlock()
+
+	statuses := make([]SinkStatus, 0, len(multiWriter.sinks))
+
+	for _, sink := range multiWriter.sinks {
+		statuses = append(statuses, sink.status())
+	}
+
+	return statuses
+}
+
+func (multiWriter *MultiTweetWriter) Healthy() bool {
+
+	for _, status := range multiWriter.Status() {
+		if !status.Healthy {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sink *tweetSink) status() SinkStatus {
+
+	stats := sink.queue.Stats()
+
+	status := SinkStatus{
+		Name:         sink.name,
+		Lag:          stats.Queued,
+		Written:      stats.Written,
+		Dropped:      stats.Dropped,
+		Rejected:     stats.Rejected,
+		DeadLettered: stats.DeadLettered,
+	}
+
+	sink.writer.mutex.Lock()
+	status.Failures = sink.writer.failures
+	status.LastWrite = sink.writer.lastWrite
+	if sink.writer.lastError != nil {
+		status.LastError = sink.writer.lastError.Error()
+	}
+	sink.writer.mutex.Unlock()
+
+	status.Healthy = status.LastError == "" && status.Lag < sink.queue.config.QueueSize
+
+	return status
+}
+
+// sinkWriter writes to the writer of a sink, keeping the result of the last
+// write. A writer that panics fails the write instead of the process, so
+// it is retried like any other failure
+type sinkWriter struct {
+	writer TweetWriter
+
+	mutex     sync.Mutex
+	failures  int
+	lastWrite time.Time
+	lastError error
+}
+
+func newSinkWriter(writer TweetWriter) *sinkWriter {
+
+	wrapped := new(sinkWriter)
+
+	wrapped.writer = writer
+
+	return wrapped
+}
+
+func (wrapped *sinkWriter) WriteTweet(tweet domain.Tweet) error {
+	return wrapped.WriteTweets([]domain.Tweet{tweet})
+}
+
+func (wrapped *sinkWriter) WriteTweets(tweets []domain.Tweet) error {
+
+	err := wrapped.write(tweets)
+
+	wrapped.mutex.Lock()
+	defer wrapped.mutex.Unlock()
+
+	wrapped.lastError = err
+
+	if err != nil {
+		wrapped.failures++
+		return err
+	}
+
+	wrapped.lastWrite = time.Now()
+
+	return nil
+}
+
+func (wrapped *sinkWriter) write(tweets []domain.Tweet) (err error) {
+
+	defer func() {
+		if failure := recover(); failure != nil {
+			err = fmt.Errorf("write failed: %v", failure)
+		}
+	}()
+
+	return writeTweets(wrapped.writer, tweets)
+}
@@ -0,0 +1,177 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+// panickingTweetWriter fails every write
+type panickingTweetWriter struct{}
+
+func (writer panickingTweetWriter) WriteTweet(tweet domain.Tweet) error {
+	panic("disk is full")
+}
+
+func sinkConfig(queueSize int) service.ChannelTweetWriterConfig {
+	return service.ChannelTweetWriterConfig{
+		QueueSize:  queueSize,
+		BatchSize:  1,
+		BatchDelay: time.Millisecond,
+		Overflow:   service.DropOnOverflow,
+	}
+}
+
+func TestMultiTweetWriterWritesToFastSinksWhileOneIsSlow(t *testing.T) {
+
+	// Initialization
+	slowWriter := &blockingTweetWriter{make(chan bool), service.NewMemoryTweetWriter()}
+	fastWriter := service.NewMemoryTweetWriter()
+
+	multiWriter := service.NewMultiTweetWriter()
+	multiWriter.AddSink("slow", slowWriter, sinkConfig(2))
+	multiWriter.AddSink("fast", fastWriter, sinkConfig(10))
+
+	ctx := context.Background()
+
+	// Operation
+	for n := 0; n < 5; n++ {
+		multiWriter.WriteTweet(domain.NewTextTweet("grupoesfera", fmt.Sprintf("Tweet %d", n)))
+	}
+
+	flushed := make(chan error)
+	go func() {
+		flushed <- multiWriter.Flush(ctx)
+	}()
+
+	// Validation
+	for n := 0; n < 100 && multiWriter.Status()[1].Written < 5; n++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	statuses := multiWriter.Status()
+
+	if statuses[1].Written != 5 || !statuses[1].Healthy {
+		t.Errorf("Expected the fast sink to write every tweet but was %+v", statuses[1])
+	}
+
+	if statuses[0].Dropped == 0 || statuses[0].Lag == 0 || statuses[0].Healthy {
+		t.Errorf("Expected the slow sink to lag behind and drop tweets but was %+v", statuses[0])
+	}
+
+	if multiWriter.Healthy() {
+		t.Errorf("Expected the writer to be unhealthy while a sink is full")
+	}
+
+	close(slowWriter.release)
+
+	if err := <-flushed; err != nil {
+		t.Errorf("Unexpected error flushing %s", err.Error())
+	}
+
+	multiWriter.Close()
+}
+
+func TestMultiTweetWriterIsolatesFailingSinks(t *testing.T) {
+
+	// Initialization
+	memoryWriter := service.NewMemoryTweetWriter()
+
+	multiWriter := service.NewMultiTweetWriter()
+	multiWriter.AddSink("broken", panickingTweetWriter{}, sinkConfig(10))
+	multiWriter.AddSink("memory", memoryWriter, sinkConfig(10))
+
+	// Operation
+	multiWriter.WriteTweet(domain.NewTextTweet("grupoesfera", "Survives the broken sink"))
+
+	err := multiWriter.Flush(context.Background())
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	if len(memoryWriter.Tweets) != 1 {
+		t.Errorf("Expected the tweet in the memory sink but were %v", memoryWriter.Tweets)
+	}
+
+	statuses := multiWriter.Status()
+
+	if statuses[0].Healthy || statuses[0].Failures != 1 || statuses[0].LastError != "write failed: disk is full" {
+		t.Errorf("Expected the broken sink to report its failure but was %+v", statuses[0])
+	}
+
+	if !statuses[1].Healthy || statuses[1].LastWrite.IsZero() {
+		t.Errorf("Expected the memory sink to be healthy but was %+v", statuses[1])
+	}
+
+	multiWriter.Close()
+}
+
+func TestMultiTweetWriterRejectsRepeatedSinkNames(t *testing.T) {
+
+	// Initialization
+	multiWriter := service.NewMultiTweetWriter()
+	multiWriter.AddSink("memory", service.NewMemoryTweetWriter(), service.DefaultSinkConfig())
+
+	// Operation
+	err := multiWriter.AddSink("memory", service.NewMemoryTweetWriter(), service.DefaultSinkConfig())
+
+	// Validation
+	if err == nil || err.Error() != "sink memory already exists" {
+		t.Errorf("Expected the repeated sink to be rejected but the error was %v", err)
+	}
+}
+
+func TestMultiTweetWriterAcknowledgesOnceTheRequiredSinksWrote(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDeadLettersPath(t)
+	defer remove()
+
+	storeWriter := &failingTweetWriter{failures: 2, writer: service.NewMemoryTweetWriter()}
+	deadLetters, _ := service.OpenDeadLetterQueue(path, storeWriter)
+
+	storeConfig := retryConfig(deadLetters)
+	storeConfig.MaxRetries = 1
+
+	multiWriter := service.NewMultiTweetWriter()
+	multiWriter.AddRequiredSink("store", storeWriter, storeConfig)
+	multiWriter.AddSink("memory", service.NewMemoryTweetWriter(), sinkConfig(10))
+
+	tweetWriter := service.NewChannelTweetWriterWithConfig(multiWriter, service.ChannelTweetWriterConfig{
+		QueueSize:  10,
+		BatchSize:  1,
+		BatchDelay: time.Millisecond,
+	})
+	defer tweetWriter.Close()
+
+	ctx := context.Background()
+
+	// Operation
+	failedAck, _ := tweetWriter.Enqueue(ctx, domain.NewTextTweet("grupoesfera", "Dead letter"))
+	failedErr := failedAck.Wait(ctx)
+
+	writtenAck, _ := tweetWriter.Enqueue(ctx, domain.NewTextTweet("grupoesfera", "Stored"))
+	writtenErr := writtenAck.Wait(ctx)
+
+	// Validation
+	if failedErr == nil || failedErr.Error() != "tweet couldn't be written: sink store: tweet couldn't be written and was kept as a dead letter: no space left on device" {
+		t.Errorf("Expected the error of the store but was %v", failedErr)
+	}
+
+	if writtenErr != nil {
+		t.Errorf("Unexpected error %s", writtenErr.Error())
+	}
+
+	// The tweet is in the store once it is acknowledged
+	assertTexts(t, storeWriter.writer.Tweets, "Stored")
+
+	if letters, _ := deadLetters.List(); len(letters) != 1 {
+		t.Errorf("Expected the failed tweet as a dead letter but were %v", letters)
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+type NotificationType string
+
+const (
+	MentionNotification NotificationType = "mention"
+	QuoteNotification   NotificationType = "quote"
+	ReplyNotification   NotificationType = "reply"
+	LikeNotification    NotificationType = "like"
+	FollowNotification  NotificationType = "follow"
+)
+
+var NotificationTypes = []NotificationType{
+	MentionNotification, QuoteNotification, ReplyNotification, LikeNotification, FollowNotification,
+}
+
+type Notification struct {
+	Id    int
+	Type  NotificationType
+	From  string
+	Tweet domain.Tweet
+	Date  time.Time
+	Read  bool
+}
+
+type NotificationService struct {
+	mutex         sync.RWMutex
+	tweetManager  *TweetManager
+	lastId        int
+	notifications map[string][]*Notification
+	mentions      map[string][]domain.Tweet
+	disabled      map[string]map[NotificationType]bool
+}
+
+func NewNotificationService(tweetManager *TweetManager) *NotificationService {
+
+	notifier := new(NotificationService)
+
+	notifier.tweetManager = tweetManager
+	notifier.notifications = make(map[string][]*Notification)
+	notifier.mentions = make(map[string][]domain.Tweet)
+	notifier.disabled = make(map[string]map[NotificationType]bool)
+
+	tweetManager.Events().SubscribeSync("notifications", notifier.handleEvent, TweetQuoted{}, UserMentioned{}, TweetLiked{}, UserFollowed{}, UserRenamed{})
+
+	return notifier
+}
+
+// GetNotifications returns the notifications of the user, the newest first
+func (notifier *NotificationService) GetNotifications(user string) []Notification {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.RLock()
+	defer notifier.mutex.RUnlock()
+
+	userNotifications := notifier.notifications[handle]
+
+	notifications := make([]Notification, 0, len(userNotifications))
+
+	for index := len(userNotifications) - 1; index >= 0; index-- {
+		notifications = append(notifications, *userNotifications[index])
+	}
+
+	return notifications
+}
+
+// GetMentions returns the tweets that mention the user, the newest first
+func (notifier *NotificationService) GetMentions(user string) []domain.Tweet {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.RLock()
+	defer notifier.mutex.RUnlock()
+
+	userMentions := notifier.mentions[handle]
+
+	mentions := make([]domain.Tweet, 0, len(userMentions))
+
+	for index := len(userMentions) - 1; index >= 0; index-- {
+		mentions = append(mentions, userMentions[index])
+	}
+
+	return mentions
+}
+
+func (notifier *NotificationService) GetMentionsPage(user, pageCursor string, limit int) (Page, error) {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.RLock()
+	mentions := append([]domain.Tweet(nil), notifier.mentions[handle]...)
+	notifier.mutex.RUnlock()
+
+	return paginate(mentions, pageCursor, limit)
+}
+
+func (notifier *NotificationService) CountUnread(user string) int {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.RLock()
+	defer notifier.mutex.RUnlock()
+
+	var count int
+
+	for _, notification := range notifier.notifications[handle] {
+		if !notification.Read {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (notifier *NotificationService) MarkAsRead(user string, id int) error {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	for _, notification := range notifier.notifications[handle] {
+		if notification.Id == id {
+			notification.Read = true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("notification %d does not exist", id)
+}
+
+func (notifier *NotificationService) MarkAllAsRead(user string) {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	for _, notification := range notifier.notifications[handle] {
+		notification.Read = true
+	}
+}
+
+func (notifier *NotificationService) SetPreference(user string, notificationType NotificationType, enabled bool) error {
+
+	if !isNotificationType(notificationType) {
+		return fmt.Errorf("notification type %s does not exist", notificationType)
+	}
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	if notifier.disabled[handle] == nil {
+		notifier.disabled[handle] = make(map[NotificationType]bool)
+	}
+
+	if enabled {
+		delete(notifier.disabled[handle], notificationType)
+	} else {
+		notifier.disabled[handle][notificationType] = true
+	}
+
+	return nil
+}
+
+func (notifier *NotificationService) GetPreferences(user string) map[NotificationType]bool {
+
+	handle := notifier.tweetManager.ResolveUser(user)
+
+	notifier.mutex.RLock()
+	defer notifier.mutex.RUnlock()
+
+	preferences := make(map[NotificationType]bool)
+
+	for _, notificationType := range NotificationTypes {
+		preferences[notificationType] = !notifier.disabled[handle][notificationType]
+	}
+
+	return preferences
+}
+
+func (notifier *NotificationService) handleEvent(event Event) error {
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	switch event := event.(type) {
+	case TweetQuoted:
+		notifier.notify(event.Quoted.GetUser(), QuoteNotification, domain.NormalizeHandle(event.Tweet.GetUser()), event.Tweet)
+	case UserMentioned:
+		notifier.userMentioned(event)
+	case TweetLiked:
+		notifier.notify(event.Tweet.GetUser(), LikeNotification, event.User, event.Tweet)
+	case UserFollowed:
+		notifier.notify(event.Followed, FollowNotification, event.Follower, nil)
+	case UserRenamed:
+		notifier.userRenamed(event.From, event.To)
+	}
+
+	return nil
+}
+
+func (notifier *NotificationService) userMentioned(event UserMentioned) {
+
+	author := domain.NormalizeHandle(event.Tweet.GetUser())
+
+	if event.User == author || !notifier.tweetManager.IsRegistered(event.User) {
+		return
+	}
+
+	notifier.mentions[event.User] = append(notifier.mentions[event.User], event.Tweet)
+
+	if event.Reply {
+		notifier.notify(event.User, ReplyNotification, author, event.Tweet)
+	} else {
+		notifier.notify(event.User, MentionNotification, author, event.Tweet)
+	}
+}
+
+func (notifier *NotificationService) notify(user string, notificationType NotificationType, from string, tweet domain.Tweet) {
+
+	handle := domain.NormalizeHandle(user)
+
+	if handle == from || notifier.disabled[handle][notificationType] {
+		return
+	}
+
+	notifier.lastId++
+
+	notification := &Notification{
+		Id:    notifier.lastId,
+		Type:  notificationType,
+		From:  from,
+		Tweet: tweet,
+		Date:  notifier.tweetManager.now(),
+	}
+
+	notifier.notifications[handle] = append(notifier.notifications[handle], notification)
+}
+
+func (notifier *NotificationService) userRenamed(from, to string) {
+
+	if notifications, found := notifier.notifications[from]; found {
+		notifier.notifications[to] = notifications
+		delete(notifier.notifications, from)
+	}
+
+	if mentions, found := notifier.mentions[from]; found {
+		notifier.mentions[to] = mentions
+		delete(notifier.mentions, from)
+	}
+
+	if disabled, found := notifier.disabled[from]; found {
+		notifier.disabled[to] = disabled
+		delete(notifier.disabled, from)
+	}
+}
+
+func isNotificationType(notificationType NotificationType) bool {
+
+	for _, existingType := range NotificationTypes {
+		if existingType == notificationType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func TestInteractionsAreNotified(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "mariana")
+	notificationService := service.NewNotificationService(tweetManager)
+
+	ctx := context.Background()
+
+	tweet := domain.NewTextTweet("grupoesfera", "Welcome to the course")
+	id, _ := tweetManager.PublishTweet(ctx, tweet)
+
+	// Operation
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "@grupoesfera thanks!"))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("mariana", "Look at what @grupoesfera said"))
+	tweetManager.PublishTweet(ctx, domain.NewQuoteTweet("mariana", "Great", tweet))
+	tweetManager.LikeTweet("nick", id)
+	tweetManager.Follow("nick", "grupoesfera")
+
+	// Validation
+	notifications := notificationService.GetNotifications("grupoesfera")
+
+	expectedTypes := []service.NotificationType{
+		service.FollowNotification,
+		service.LikeNotification,
+		service.QuoteNotification,
+		service.MentionNotification,
+		service.ReplyNotification,
+	}
+
+	if len(notifications) != len(expectedTypes) {
+		t.Errorf("Expected size is %d but was %d", len(expectedTypes), len(notifications))
+		return
+	}
+
+	for index, notification := range notifications {
+		if notification.Type != expectedTypes[index] {
+			t.Errorf("Expected type is %s but was %s", expectedTypes[index], notification.Type)
+		}
+	}
+
+	if mentions := notificationService.GetMentions("grupoesfera"); len(mentions) != 2 {
+		t.Errorf("Expected mentions size is 2 but was %d", len(mentions))
+	}
+}
+
+func TestMentionsOfARenamedHandleInItsGracePeriodAreNotified(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "mariana")
+	notificationService := service.NewNotificationService(tweetManager)
+
+	tweetManager.RenameUser("nick", "nicolas")
+
+	// Operation
+	tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("mariana", "@nick did you rename?"))
+
+	// Validation
+	notifications := notificationService.GetNotifications("nicolas")
+
+	if len(notifications) != 1 || notifications[0].Type != service.ReplyNotification {
+		t.Errorf("Expected the renamed user to be notified of the reply but the notifications were %v", notifications)
+	}
+
+	if mentions := notificationService.GetMentions("nicolas"); len(mentions) != 1 {
+		t.Errorf("Expected the mention of the old handle but were %v", mentions)
+	}
+}
+
+func TestNotificationsCanBeMarkedAsRead(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera", "mariana")
+	notificationService := service.NewNotificationService(tweetManager)
+
+	tweetManager.Follow("nick", "grupoesfera")
+	tweetManager.Follow("mariana", "grupoesfera")
+
+	notifications := notificationService.GetNotifications("grupoesfera")
+
+	// Operation
+	err := notificationService.MarkAsRead("grupoesfera", notifications[0].Id)
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+
+	if unread := notificationService.CountUnread("grupoesfera"); unread != 1 {
+		t.Errorf("Expected unread is 1 but was %d", unread)
+	}
+
+	notificationService.MarkAllAsRead("grupoesfera")
+
+	if unread := notificationService.CountUnread("grupoesfera"); unread != 0 {
+		t.Errorf("Expected unread is 0 but was %d", unread)
+	}
+}
+
+func TestDisabledNotificationsAreNotRecorded(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera")
+	notificationService := service.NewNotificationService(tweetManager)
+
+	// Operation
+	notificationService.SetPreference("grupoesfera", service.FollowNotification, false)
+	tweetManager.Follow("nick", "grupoesfera")
+
+	// Validation
+	if notifications := notificationService.GetNotifications("grupoesfera"); len(notifications) != 0 {
+		t.Errorf("Expected no notifications but were %v", notifications)
+	}
+
+	if preferences := notificationService.GetPreferences("grupoesfera"); preferences[service.FollowNotification] {
+		t.Error("Expected follow notifications to be disabled")
+	}
+}
+
+func TestMentionsArePaginated(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("grupoesfera")
+	notificationService := service.NewNotificationService(tweetManager)
+
+	publishTweets(tweetManager, "nick", 1)
+	ctx := context.Background()
+	for n := 0; n < 3; n++ {
+		tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "Hi @grupoesfera"))
+	}
+
+	// Operation
+	page, _ := notificationService.GetMentionsPage("grupoesfera", "", 2)
+	lastPage, _ := notificationService.GetMentionsPage("grupoesfera", page.NextCursor, 2)
+
+	// Validation
+	if ids := pageIds(page); len(ids) != 2 || ids[0] != 5 || ids[1] != 4 {
+		t.Errorf("Expected page is [5 4] but was %v", ids)
+	}
+
+	if ids := pageIds(lastPage); len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("Expected last page is [3] but was %v", ids)
+	}
+}
+
+func TestNotificationsCanBeReadWhileUsersInteract(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera")
+	notificationService := service.NewNotificationService(tweetManager)
+
+	ctx := context.Background()
+
+	var writers sync.WaitGroup
+	writers.Add(1)
+
+	// Operation
+	go func() {
+		defer writers.Done()
+		for n := 0; n < 100; n++ {
+			tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "Hi @grupoesfera"))
+		}
+	}()
+
+	readUntilDone(&writers, func() {
+		notificationService.GetNotifications("grupoesfera")
+		notificationService.GetMentionsPage("grupoesfera", "", 10)
+		notificationService.MarkAllAsRead("grupoesfera")
+	})
+
+	// Validation
+	if mentions := notificationService.GetMentions("grupoesfera"); len(mentions) != 100 {
+		t.Errorf("Expected 100 mentions but were %d", len(mentions))
+	}
+}
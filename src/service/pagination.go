@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Page is a part of a list of tweets, the newest first. NextCursor gets the
+// older tweets and is empty on the last page, PrevCursor gets the tweets
+// published after the ones of the page
+type Page struct {
+	Tweets     []domain.Tweet
+	NextCursor string
+	PrevCursor string
+}
+
+type cursor struct {
+	maxId   int
+	sinceId int
+}
+
+// GetTweetsPage returns a page of all the tweets. An empty cursor starts
+// from the newest tweet and a limit of 0 uses DefaultPageSize
+func (manager *TweetManager) GetTweetsPage(pageCursor string, limit int) (Page, error) {
+	return paginate(manager.GetTweets(), pageCursor, limit)
+}
+
+func (manager *TweetManager) GetTweetsByUserPage(user, pageCursor string, limit int) (Page, error) {
+	return paginate(manager.GetTweetsByUser(user), pageCursor, limit)
+}
+
+// paginate returns a page of the tweets, which must be sorted by id.
+// Cursors point to ids, so pages don't change when new tweets are published
+func paginate(tweets []domain.Tweet, pageCursor string, limit int) (Page, error) {
+
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	if limit < 0 || limit > MaxPageSize {
+		return Page{}, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+	}
+
+	position, err := decodeCursor(pageCursor)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	var from, to int
+
+	if position.sinceId > 0 {
+		from = sort.Search(len(tweets), func(index int) bool { return tweets[index].GetId() > position.sinceId })
+		to = from + limit
+		if to > len(tweets) {
+			to = len(tweets)
+		}
+	} else {
+		to = len(tweets)
+		if position.maxId > 0 {
+			to = sort.Search(len(tweets), func(index int) bool { return tweets[index].GetId() >= position.maxId })
+		}
+		from = to - limit
+		if from < 0 {
+			from = 0
+		}
+	}
+
+	page := Page{Tweets: make([]domain.Tweet, 0, to-from)}
+
+	for index := to - 1; index >= from; index-- {
+		page.Tweets = append(page.Tweets, tweets[index])
+	}
+
+	if from > 0 {
+		page.NextCursor = encodeCursor(cursor{maxId: tweets[from].GetId()})
+	}
+
+	if to > from {
+		page.PrevCursor = encodeCursor(cursor{sinceId: tweets[to-1].GetId()})
+	} else {
+		page.PrevCursor = pageCursor
+	}
+
+	return page, nil
+}
+
+func encodeCursor(position cursor) string {
+
+	if position.sinceId > 0 {
+		return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("since_id:%d", position.sinceId)))
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("max_id:%d", position.maxId)))
+}
+
+func decodeCursor(pageCursor string) (cursor, error) {
+
+	var position cursor
+
+	if pageCursor == "" {
+		return position, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(pageCursor)
+
+	if err != nil {
+		return position, fmt.Errorf("invalid cursor %s", pageCursor)
+	}
+
+	if _, err := fmt.Sscanf(string(decoded), "since_id:%d", &position.sinceId); err == nil && position.sinceId > 0 {
+		return position, nil
+	}
+
+	if _, err := fmt.Sscanf(string(decoded), "max_id:%d", &position.maxId); err == nil && position.maxId > 0 {
+		return position, nil
+	}
+
+	return cursor{}, fmt.Errorf("invalid cursor %s", pageCursor)
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func publishTweets(tweetManager *service.TweetManager, user string, count int) {
+
+	ctx := context.Background()
+
+	for n := 0; n < count; n++ {
+		tweetManager.PublishTweet(ctx, domain.NewTextTweet(user, "This is a tweet"))
+	}
+}
+
+func pageIds(page service.Page) []int {
+
+	ids := make([]int, 0)
+
+	for _, tweet := range page.Tweets {
+		ids = append(ids, tweet.GetId())
+	}
+
+	return ids
+}
+
+func TestTweetsArePaginatedNewestFirst(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+	publishTweets(tweetManager, "grupoesfera", 5)
+
+	// Operation
+	firstPage, _ := tweetManager.GetTweetsPage("", 2)
+	secondPage, _ := tweetManager.GetTweetsPage(firstPage.NextCursor, 2)
+	lastPage, _ := tweetManager.GetTweetsPage(secondPage.NextCursor, 2)
+
+	// Validation
+	if ids := pageIds(firstPage); len(ids) != 2 || ids[0] != 5 || ids[1] != 4 {
+		t.Errorf("Expected first page is [5 4] but was %v", ids)
+	}
+
+	if ids := pageIds(secondPage); len(ids) != 2 || ids[0] != 3 || ids[1] != 2 {
+		t.Errorf("Expected second page is [3 2] but was %v", ids)
+	}
+
+	if ids := pageIds(lastPage); len(ids) != 1 || ids[0] != 1 || lastPage.NextCursor != "" {
+		t.Errorf("Expected last page is [1] without next cursor but was %v %s", ids, lastPage.NextCursor)
+	}
+}
+
+func TestPagesDontChangeWhenNewTweetsArePublished(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+	publishTweets(tweetManager, "grupoesfera", 4)
+
+	firstPage, _ := tweetManager.GetTweetsPage("", 2)
+
+	// Operation
+	publishTweets(tweetManager, "grupoesfera", 3)
+
+	secondPage, _ := tweetManager.GetTweetsPage(firstPage.NextCursor, 2)
+	newerPage, _ := tweetManager.GetTweetsPage(firstPage.PrevCursor, 10)
+
+	// Validation
+	if ids := pageIds(secondPage); len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Errorf("Expected second page is [2 1] but was %v", ids)
+	}
+
+	if ids := pageIds(newerPage); len(ids) != 3 || ids[0] != 7 || ids[2] != 5 {
+		t.Errorf("Expected newer page is [7 6 5] but was %v", ids)
+	}
+}
+
+func TestTweetsOfAnUserArePaginated(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+	publishTweets(tweetManager, "grupoesfera", 2)
+	publishTweets(tweetManager, "nick", 3)
+
+	// Operation
+	page, err := tweetManager.GetTweetsByUserPage("grupoesfera", "", 10)
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+
+	if ids := pageIds(page); len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Errorf("Expected page is [2 1] but was %v", ids)
+	}
+}
+
+func TestInvalidPagesFail(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+
+	// Operation
+	_, cursorErr := tweetManager.GetTweetsPage("not a cursor", 10)
+	_, limitErr := tweetManager.GetTweetsPage("", service.MaxPageSize+1)
+
+	// Validation
+	if cursorErr == nil || cursorErr.Error() != "invalid cursor not a cursor" {
+		t.Errorf("Expected error is invalid cursor not a cursor but was %v", cursorErr)
+	}
+
+	if limitErr == nil || limitErr.Error() != "limit must be between 1 and 100" {
+		t.Errorf("Expected error is limit must be between 1 and 100 but was %v", limitErr)
+	}
+}
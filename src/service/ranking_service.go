@@ -0,0 +1,211 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const (
+	EngagementSignal = "engagement"
+	RecencySignal    = "recency"
+	AffinitySignal   = "affinity"
+	MediaSignal      = "media"
+)
+
+// rankingSignals are added always in the same order so scores don't change
+// with the order of the map
+var rankingSignals = []string{EngagementSignal, RecencySignal, AffinitySignal, MediaSignal}
+
+type RankingConfig struct {
+	EngagementWeight float64
+	RecencyWeight    float64
+	AffinityWeight   float64
+	MediaWeight      float64
+
+	// RecencyHalfLife is the age at which the recency signal is halved
+	RecencyHalfLife time.Duration
+
+	// MaxCandidates is how many of the newest tweets are ranked
+	MaxCandidates int
+}
+
+func DefaultRankingConfig() RankingConfig {
+	return RankingConfig{
+		EngagementWeight: 1,
+		RecencyWeight:    2,
+		AffinityWeight:   1.5,
+		MediaWeight:      0.5,
+		RecencyHalfLife:  6 * time.Hour,
+		MaxCandidates:    500,
+	}
+}
+
+// LoadRankingConfig reads the ranking config from a JSON file. The values
+// missing in the file keep their default
+func LoadRankingConfig(path string) (RankingConfig, error) {
+
+	config := DefaultRankingConfig()
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return config, err
+	}
+
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&config)
+
+	return config, err
+}
+
+// RankedTweet is a tweet with its score. Signals has the weighted score of
+// every signal and is only filled in debug mode
+type RankedTweet struct {
+	Tweet   domain.Tweet
+	Score   float64
+	Signals map[string]float64 `json:",omitempty"`
+}
+
+type RankingService struct {
+	mutex        sync.RWMutex
+	tweetManager *TweetManager
+	config       RankingConfig
+	quotes       map[int]int
+	likedAuthors map[string]map[string]int
+}
+
+func NewRankingService(tweetManager *TweetManager, config RankingConfig) *RankingService {
+
+	ranker := new(RankingService)
+
+	ranker.tweetManager = tweetManager
+	ranker.config = config
+	ranker.quotes = make(map[int]int)
+	ranker.likedAuthors = make(map[string]map[string]int)
+
+	tweetManager.Events().SubscribeSync("ranking", ranker.handleEvent, TweetPublished{}, TweetLiked{}, UserRenamed{})
+
+	return ranker
+}
+
+// GetTopTweets returns up to limit tweets ranked for the user, the best
+// first. Ties are broken by id so the ranking is deterministic
+func (ranker *RankingService) GetTopTweets(user string, limit int, debug bool) []RankedTweet {
+
+	viewer := ranker.tweetManager.ResolveUser(user)
+	now := ranker.tweetManager.now()
+
+	tweets := ranker.tweetManager.GetTweets()
+	if len(tweets) > ranker.config.MaxCandidates {
+		tweets = tweets[len(tweets)-ranker.config.MaxCandidates:]
+	}
+
+	following := make(map[string]bool)
+	for _, followed := range ranker.tweetManager.GetFollowing(viewer) {
+		following[followed] = true
+	}
+
+	ranker.mutex.RLock()
+	defer ranker.mutex.RUnlock()
+
+	ranked := make([]RankedTweet, 0, len(tweets))
+
+	for _, tweet := range tweets {
+
+		signals := ranker.signals(tweet, viewer, following, now)
+
+		rankedTweet := RankedTweet{Tweet: tweet}
+
+		for _, signal := range rankingSignals {
+			rankedTweet.Score += signals[signal]
+		}
+
+		if debug {
+			rankedTweet.Signals = signals
+		}
+
+		ranked = append(ranked, rankedTweet)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Tweet.GetId() > ranked[j].Tweet.GetId()
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked
+}
+
+func (ranker *RankingService) signals(tweet domain.Tweet, viewer string, following map[string]bool, now time.Time) map[string]float64 {
+
+	author := domain.NormalizeHandle(tweet.GetUser())
+
+	engagement := math.Log1p(float64(len(ranker.tweetManager.GetLikes(tweet.GetId())) + ranker.quotes[tweet.GetId()]))
+
+	var recency float64
+	if tweet.GetDate() != nil {
+		age := now.Sub(*tweet.GetDate())
+		if age < 0 {
+			age = 0
+		}
+		recency = math.Pow(0.5, age.Hours()/ranker.config.RecencyHalfLife.Hours())
+	}
+
+	affinity := math.Log1p(float64(ranker.likedAuthors[viewer][author]))
+	if following[author] {
+		affinity++
+	}
+
+	var media float64
+	if imageTweet, ok := tweet.(*domain.ImageTweet); ok && imageTweet.URL != "" {
+		media = 1
+	}
+
+	return map[string]float64{
+		EngagementSignal: ranker.config.EngagementWeight * engagement,
+		RecencySignal:    ranker.config.RecencyWeight * recency,
+		AffinitySignal:   ranker.config.AffinityWeight * affinity,
+		MediaSignal:      ranker.config.MediaWeight * media,
+	}
+}
+
+func (ranker *RankingService) handleEvent(event Event) error {
+
+	ranker.mutex.Lock()
+	defer ranker.mutex.Unlock()
+
+	switch event := event.(type) {
+
+	case TweetPublished:
+		if quoteTweet, ok := event.Tweet.(*domain.QuoteTweet); ok && quoteTweet.QuotedTweet != nil {
+			ranker.quotes[quoteTweet.QuotedTweet.GetId()]++
+		}
+
+	case TweetLiked:
+		author := domain.NormalizeHandle(event.Tweet.GetUser())
+		if ranker.likedAuthors[event.User] == nil {
+			ranker.likedAuthors[event.User] = make(map[string]int)
+		}
+		ranker.likedAuthors[event.User][author]++
+
+	case UserRenamed:
+		renameKey(ranker.likedAuthors, event.From, event.To)
+		for _, authors := range ranker.likedAuthors {
+			renameKey(authors, event.From, event.To)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func TestTopTweetsAreRankedBySignals(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("mariana")
+
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.UTC)
+	tweetManager.SetClock(func() time.Time { return now })
+
+	ranker := service.NewRankingService(tweetManager, service.DefaultRankingConfig())
+
+	ctx := context.Background()
+
+	oldTweet := domain.NewTextTweet("grupoesfera", "An old tweet")
+	oldDate := now.Add(-12 * time.Hour)
+	oldTweet.Date = &oldDate
+	oldId, _ := tweetManager.PublishTweet(ctx, oldTweet)
+
+	imageTweet := domain.NewImageTweet("nick", "A gopher", "http://gopher.png")
+	imageTweet.Date = &now
+	imageId, _ := tweetManager.PublishTweet(ctx, imageTweet)
+
+	textTweet := domain.NewTextTweet("nick", "Just text")
+	textTweet.Date = &now
+	textId, _ := tweetManager.PublishTweet(ctx, textTweet)
+
+	// Operation
+	ranked := ranker.GetTopTweets("mariana", 10, false)
+
+	// Validation
+	if len(ranked) != 4 {
+		t.Errorf("Expected size is 4 but was %d", len(ranked))
+		return
+	}
+
+	expectedIds := []int{imageId, textId}
+	for index, expectedId := range expectedIds {
+		if ranked[index].Tweet.GetId() != expectedId {
+			t.Errorf("Expected tweet %d at %d but was %d", expectedId, index, ranked[index].Tweet.GetId())
+		}
+	}
+
+	if ranked[3].Tweet.GetId() != oldId {
+		t.Errorf("Expected the old tweet last but was %d", ranked[3].Tweet.GetId())
+	}
+
+	if ranked[0].Signals != nil {
+		t.Error("Expected no signals out of debug mode")
+	}
+}
+
+func TestAffinityAndEngagementRaiseTheScore(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("mariana", "grupoesfera", "nick")
+
+	now := time.Now().Add(24 * time.Hour)
+	tweetManager.SetClock(func() time.Time { return now })
+
+	ranker := service.NewRankingService(tweetManager, service.DefaultRankingConfig())
+
+	ctx := context.Background()
+
+	followedTweet := domain.NewTextTweet("grupoesfera", "Followed")
+	followedTweet.Date = &now
+	followedId, _ := tweetManager.PublishTweet(ctx, followedTweet)
+
+	likedTweet := domain.NewTextTweet("nick", "Liked")
+	likedTweet.Date = &now
+	likedId, _ := tweetManager.PublishTweet(ctx, likedTweet)
+
+	tweetManager.Follow("mariana", "grupoesfera")
+	tweetManager.LikeTweet("grupoesfera", likedId)
+
+	// Operation
+	ranked := ranker.GetTopTweets("mariana", 2, true)
+
+	// Validation
+	if len(ranked) != 2 || ranked[0].Tweet.GetId() != followedId || ranked[1].Tweet.GetId() != likedId {
+		t.Errorf("Expected tweets %d and %d but were %v", followedId, likedId, ranked)
+		return
+	}
+
+	if ranked[0].Signals[service.AffinitySignal] != 1.5 {
+		t.Errorf("Expected affinity is 1.5 but was %v", ranked[0].Signals[service.AffinitySignal])
+	}
+
+	if ranked[1].Signals[service.EngagementSignal] <= 0 {
+		t.Errorf("Expected engagement but was %v", ranked[1].Signals[service.EngagementSignal])
+	}
+}
+
+func TestTopTweetsCanBeReadWhileTweetsAreLikedAndQuoted(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "mariana")
+	ranker := service.NewRankingService(tweetManager, service.DefaultRankingConfig())
+
+	ctx := context.Background()
+
+	quoted := domain.NewTextTweet("mariana", "Learning Go")
+	id, _ := tweetManager.PublishTweet(ctx, quoted)
+
+	var writers sync.WaitGroup
+	writers.Add(1)
+
+	// Operation
+	go func() {
+		defer writers.Done()
+		for n := 0; n < 100; n++ {
+			tweetManager.PublishTweet(ctx, domain.NewQuoteTweet("nick", "Me too", quoted))
+			tweetManager.LikeTweet("nick", id)
+		}
+	}()
+
+	readUntilDone(&writers, func() {
+		ranker.GetTopTweets("nick", 10, true)
+	})
+
+	// Validation
+	if top := ranker.GetTopTweets("nick", 1, false); len(top) != 1 || top[0].Tweet.GetId() != id {
+		t.Errorf("Expected the liked and quoted tweet first but was %v", top)
+	}
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+// RateLimit lets Burst tweets be published at once and then one every
+// Every. A zero Burst disables the limit
+type RateLimit struct {
+	Burst int
+	Every time.Duration
+}
+
+type RateLimiterConfig struct {
+
+	// RESTUser and ShellUser limit the tweets of every user through the
+	// REST API and the shell
+	RESTUser  RateLimit
+	ShellUser RateLimit
+
+	// ClientIP and Token limit the tweets of every client of the REST API,
+	// whatever the user
+	ClientIP RateLimit
+	Token    RateLimit
+
+	// DailyQuota is how many tweets every user can publish in a UTC day.
+	// Zero disables the quota
+	DailyQuota int
+}
+
+func DefaultRateLimiterConfig() RateLimiterConfig {
+	return RateLimiterConfig{
+		RESTUser:   RateLimit{Burst: 10, Every: 6 * time.Second},
+		ShellUser:  RateLimit{Burst: 30, Every: 2 * time.Second},
+		ClientIP:   RateLimit{Burst: 60, Every: time.Second},
+		Token:      RateLimit{Burst: 60, Every: time.Second},
+		DailyQuota: 1000,
+	}
+}
+
+// LoadRateLimiterConfig reads the config from a JSON file. Missing fields
+// keep their default value
+func LoadRateLimiterConfig(path string) (RateLimiterConfig, error) {
+
+	config := DefaultRateLimiterConfig()
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return config, err
+	}
+
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&config)
+
+	return config, err
+}
+
+const (
+	RESTChannel  = "rest"
+	ShellChannel = "shell"
+)
+
+// PublishSource is who publishes a tweet. ClientIP and Token are only known
+// for the REST API
+type PublishSource struct {
+	Channel  string
+	ClientIP string
+	Token    string
+}
+
+type publishSourceKey struct{}
+
+// WithPublishSource returns a context that tells PublishTweet who publishes,
+// so the limits of the source are applied. Tweets published with a context
+// without source aren't limited
+func WithPublishSource(ctx context.Context, source PublishSource) context.Context {
+	return context.WithValue(ctx, publishSourceKey{}, source)
+}
+
+// RateLimitError is returned when a tweet isn't published because a limit
+// was reached
+type RateLimitError struct {
+
+	// Limit is what was reached, like "user grupoesfera" or "daily quota"
+	Limit string
+
+	Burst     int
+	Remaining int
+
+	// Reset is when the limit lets a tweet through again
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %s exceeded, retry in %s", err.Limit, err.RetryAfter)
+}
+
+// bucket is a token bucket which is filled lazily when used
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter applies token buckets to the sources of the tweets and a
+// daily quota to their users
+type RateLimiter struct {
+	config RateLimiterConfig
+	clock  func() time.Time
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	day     string
+	daily   map[string]int
+	checks  int
+}
+
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+
+	limiter := new(RateLimiter)
+
+	limiter.config = config
+	limiter.clock = time.Now
+	limiter.buckets = make(map[string]*bucket)
+	limiter.daily = make(map[string]int)
+
+	return limiter
+}
+
+func (limiter *RateLimiter) SetClock(clock func() time.Time) {
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.clock = clock
+}
+
+type limitCheck struct {
+	name  string
+	key   string
+	limit RateLimit
+}
+
+// Allow takes a tweet of the user from every limit of the source. Nothing
+// is taken unless every limit allows it
+func (limiter *RateLimiter) Allow(source PublishSource, user string) error {
+	return limiter.allow(source, user, 1)
+}
+
+func (limiter *RateLimiter) allow(source PublishSource, user string, tweets int) error {
+
+	user = domain.NormalizeHandle(user)
+	checks := limiter.checksOf(source, user)
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.clock()
+
+	limiter.prune(now)
+
+	if err := limiter.checkQuota(user, tweets, now); err != nil {
+		return err
+	}
+
+	buckets := make([]*bucket, 0, len(checks))
+
+	for _, check := range checks {
+
+		if check.limit.Burst <= 0 {
+			continue
+		}
+
+		current := limiter.fill(check.key, check.limit, now)
+
+		if current.tokens < 1 {
+
+			retryAfter := time.Duration((1 - current.tokens) * float64(check.limit.Every))
+
+			return &RateLimitError{
+				Limit:      check.name,
+				Burst:      check.limit.Burst,
+				Remaining:  0,
+				Reset:      now.Add(retryAfter),
+				RetryAfter: retryAfter,
+			}
+		}
+
+		buckets = append(buckets, current)
+	}
+
+	for _, current := range buckets {
+		current.tokens--
+	}
+
+	if limiter.config.DailyQuota > 0 {
+		limiter.daily[user] += tweets
+	}
+
+	return nil
+}
+
+// refund gives back the request taken from the limits of the source and
+// the tweets of the user that couldn't be published
+func (limiter *RateLimiter) refund(source PublishSource, user string, tweets int) {
+
+	user = domain.NormalizeHandle(user)
+	checks := limiter.checksOf(source, user)
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	for _, check := range checks {
+		if current, found := limiter.buckets[check.key]; found && check.limit.Burst > 0 {
+			current.tokens = math.Min(float64(check.limit.Burst), current.tokens+1)
+		}
+	}
+
+	if limiter.daily[user] -= tweets; limiter.daily[user] < 0 {
+		limiter.daily[user] = 0
+	}
+}
+
+func (limiter *RateLimiter) checksOf(source PublishSource, user string) []limitCheck {
+
+	checks := make([]limitCheck, 0, 3)
+
+	switch source.Channel {
+	case RESTChannel:
+		checks = append(checks, limitCheck{"user " + user, "rest-user:" + user, limiter.config.RESTUser})
+	case ShellChannel:
+		checks = append(checks, limitCheck{"user " + user, "shell-user:" + user, limiter.config.ShellUser})
+	}
+
+	if source.ClientIP != "" {
+		checks = append(checks, limitCheck{"client " + source.ClientIP, "ip:" + source.ClientIP, limiter.config.ClientIP})
+	}
+
+	if source.Token != "" {
+		checks = append(checks, limitCheck{"token", "token:" + source.Token, limiter.config.Token})
+	}
+
+	return checks
+}
+
+// checkQuota fails when the tweets would exceed the daily quota of the user
+func (limiter *RateLimiter) checkQuota(user string, tweets int, now time.Time) error {
+
+	if limiter.config.DailyQuota <= 0 {
+		return nil
+	}
+
+	now = now.UTC()
+
+	if day := now.Format(dayLayout); day != limiter.day {
+		limiter.day = day
+		limiter.daily = make(map[string]int)
+	}
+
+	if limiter.daily[user]+tweets <= limiter.config.DailyQuota {
+		return nil
+	}
+
+	remaining := limiter.config.DailyQuota - limiter.daily[user]
+
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	year, month, dayOfMonth := now.Date()
+	tomorrow := time.Date(year, month, dayOfMonth+1, 0, 0, 0, 0, now.Location())
+
+	return &RateLimitError{
+		Limit:      "daily quota",
+		Burst:      limiter.config.DailyQuota,
+		Remaining:  remaining,
+		Reset:      tomorrow,
+		RetryAfter: tomorrow.Sub(now),
+	}
+}
+
+// fill returns the bucket of the key with the tokens earned since it was
+// last used
+func (limiter *RateLimiter) fill(key string, limit RateLimit, now time.Time) *bucket {
+
+	current, found := limiter.buckets[key]
+
+	if !found {
+		current = &bucket{tokens: float64(limit.Burst), updated: now}
+		limiter.buckets[key] = current
+		return current
+	}
+
+	if limit.Every > 0 && now.After(current.updated) {
+		earned := float64(now.Sub(current.updated)) / float64(limit.Every)
+		current.tokens = math.Min(float64(limit.Burst), current.tokens+earned)
+	}
+
+	current.updated = now
+
+	return current
+}
+
+// prune removes every so often the buckets unused for long enough to be
+// full again, so they don't pile up
+func (limiter *RateLimiter) prune(now time.Time) {
+
+	if limiter.checks++; limiter.checks%1000 != 0 {
+		return
+	}
+
+	longest := time.Duration(0)
+
+	for _, limit := range []RateLimit{limiter.config.RESTUser, limiter.config.ShellUser, limiter.config.ClientIP, limiter.config.Token} {
+		if refill := time.Duration(limit.Burst) * limit.Every; refill > longest {
+			longest = refill
+		}
+	}
+
+	for key, current := range limiter.buckets {
+		if now.Sub(current.updated) > longest {
+			delete(limiter.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+// newLimitedManager returns a manager limited by the config, whose limiter
+// uses the time pointed by now
+func newLimitedManager(config service.RateLimiterConfig, now *time.Time) *service.TweetManager {
+
+	limiter := service.NewRateLimiter(config)
+	limiter.SetClock(func() time.Time { return *now })
+
+	tweetManager := newManagerWithUsers()
+	tweetManager.SetRateLimiter(limiter)
+
+	return tweetManager
+}
+
+func TestPublishTweetIsLimitedByUserUntilTheBucketRefills(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.Local)
+
+	tweetManager := newLimitedManager(service.RateLimiterConfig{
+		RESTUser: service.RateLimit{Burst: 2, Every: 10 * time.Second},
+	}, &now)
+
+	ctx := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.RESTChannel})
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "First"))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Second"))
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Third"))
+
+	// Validation
+	limitErr, ok := err.(*service.RateLimitError)
+
+	if !ok {
+		t.Fatalf("Expected a rate limit error but was %v", err)
+	}
+
+	if limitErr.Limit != "user grupoesfera" || limitErr.Burst != 2 || limitErr.RetryAfter != 10*time.Second {
+		t.Errorf("Expected to retry in 10 seconds but was %+v", limitErr)
+	}
+
+	if _, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "Another user")); err != nil {
+		t.Errorf("Expected other users not to be limited but was %s", err.Error())
+	}
+
+	now = now.Add(10 * time.Second)
+
+	if _, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Third")); err != nil {
+		t.Errorf("Expected the bucket to be refilled but was %s", err.Error())
+	}
+
+	if count := tweetManager.CountTweetsByUser("grupoesfera"); count != 3 {
+		t.Errorf("Expected 3 tweets but were %d", count)
+	}
+}
+
+func TestShellAndRESTHaveSeparateLimits(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.Local)
+
+	tweetManager := newLimitedManager(service.RateLimiterConfig{
+		RESTUser:  service.RateLimit{Burst: 1, Every: time.Minute},
+		ShellUser: service.RateLimit{Burst: 1, Every: time.Minute},
+	}, &now)
+
+	rest := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.RESTChannel})
+	shell := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.ShellChannel})
+
+	tweetManager.PublishTweet(rest, domain.NewTextTweet("grupoesfera", "Through REST"))
+
+	// Operation
+	_, err := tweetManager.PublishTweet(shell, domain.NewTextTweet("grupoesfera", "Through the shell"))
+
+	// Validation
+	if err != nil {
+		t.Errorf("Expected the shell to have its own limit but was %s", err.Error())
+	}
+
+	if _, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "Without source")); err != nil {
+		t.Errorf("Expected tweets without source not to be limited but was %s", err.Error())
+	}
+}
+
+func TestClientIPLimitsEveryUserOfTheClient(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.Local)
+
+	tweetManager := newLimitedManager(service.RateLimiterConfig{
+		RESTUser: service.RateLimit{Burst: 10, Every: time.Second},
+		ClientIP: service.RateLimit{Burst: 1, Every: time.Minute},
+	}, &now)
+
+	ctx := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.RESTChannel, ClientIP: "10.0.0.1"})
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "First user"))
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "Second user"))
+
+	// Validation
+	if limitErr, ok := err.(*service.RateLimitError); !ok || limitErr.Limit != "client 10.0.0.1" {
+		t.Errorf("Expected the client to be limited but was %v", err)
+	}
+
+	if tweetManager.CountTweetsByUser("nick") != 0 {
+		t.Errorf("Expected the limited tweet not to be published")
+	}
+}
+
+func TestTweetsThatArentPublishedDontSpendTheLimits(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.Local)
+
+	config := service.RateLimiterConfig{ShellUser: service.RateLimit{Burst: 1, Every: time.Hour}}
+	tweetManager := newLimitedManager(config, &now)
+	tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("nick", "Hello"))
+	tweetManager.RenameUser("nick", "nickname")
+
+	ctx := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.ShellChannel})
+
+	// Operation
+	_, invalidErr := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nickname", ""))
+	_, renamedErr := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "Old handle"))
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nickname", "New handle"))
+
+	// Validation
+	if invalidErr == nil || renamedErr == nil {
+		t.Fatalf("Expected the invalid tweet and the tweet of the old handle to fail but were %v and %v", invalidErr, renamedErr)
+	}
+
+	if err != nil {
+		t.Errorf("Expected the failed tweets not to spend the limit but was %s", err.Error())
+	}
+}
+
+func TestDailyQuotaResetsTheNextDay(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 23, 0, 0, 0, time.UTC)
+
+	tweetManager := newLimitedManager(service.RateLimiterConfig{DailyQuota: 1}, &now)
+
+	ctx := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.ShellChannel})
+
+	// Invalid tweets don't count for the quota
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", ""))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Only tweet of the day"))
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "One too many"))
+
+	// Validation
+	limitErr, ok := err.(*service.RateLimitError)
+
+	if !ok || limitErr.Limit != "daily quota" || limitErr.RetryAfter != time.Hour {
+		t.Fatalf("Expected the quota to reset at midnight but was %v", err)
+	}
+
+	now = now.Add(time.Hour)
+
+	if _, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Next day")); err != nil {
+		t.Errorf("Expected the quota to be reset but was %s", err.Error())
+	}
+}
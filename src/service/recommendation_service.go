@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const (
+	friendOfFriendWeight = 3
+	interactionWeight    = 2
+	sharedHashtagWeight  = 1
+)
+
+type Recommendation struct {
+	User    string
+	Score   int
+	Reasons []string
+}
+
+// RecommendationService suggests users to follow. It keeps its counters
+// updated with the events of the TweetManager, so recommending doesn't need
+// to go through every tweet
+type RecommendationService struct {
+	mutex        sync.RWMutex
+	tweetManager *TweetManager
+
+	following map[string]map[string]bool
+	followers map[string]map[string]bool
+
+	// friendsOfFriends counts, for each user, how many of the users it
+	// follows follow every other user
+	friendsOfFriends map[string]map[string]int
+
+	sharedHashtags map[string]map[string]int
+	hashtagUsers   map[string]map[string]bool
+
+	interactions map[string]map[string]int
+
+	dismissed map[string]map[string]bool
+}
+
+func NewRecommendationService(tweetManager *TweetManager) *RecommendationService {
+
+	recommender := new(RecommendationService)
+
+	recommender.tweetManager = tweetManager
+	recommender.following = make(map[string]map[string]bool)
+	recommender.followers = make(map[string]map[string]bool)
+	recommender.friendsOfFriends = make(map[string]map[string]int)
+	recommender.sharedHashtags = make(map[string]map[string]int)
+	recommender.hashtagUsers = make(map[string]map[string]bool)
+	recommender.interactions = make(map[string]map[string]int)
+	recommender.dismissed = make(map[string]map[string]bool)
+
+	tweetManager.Events().SubscribeSync("recommendations", recommender.handleEvent, TweetPublished{}, UserFollowed{}, UserUnfollowed{}, UserRenamed{})
+
+	return recommender
+}
+
+func (recommender *RecommendationService) GetRecommendations(user string, limit int) []Recommendation {
+
+	handle := recommender.tweetManager.ResolveUser(user)
+
+	recommender.mutex.RLock()
+	defer recommender.mutex.RUnlock()
+
+	candidates := make(map[string]bool)
+
+	for _, counters := range []map[string]int{
+		recommender.friendsOfFriends[handle],
+		recommender.sharedHashtags[handle],
+		recommender.interactions[handle],
+	} {
+		for candidate, count := range counters {
+			if count > 0 {
+				candidates[candidate] = true
+			}
+		}
+	}
+
+	recommendations := make([]Recommendation, 0)
+
+	for candidate := range candidates {
+
+		if candidate == handle || recommender.following[handle][candidate] || recommender.dismissed[handle][candidate] {
+			continue
+		}
+
+		recommendations = append(recommendations, recommender.recommend(handle, candidate))
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].User < recommendations[j].User
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return recommendations
+}
+
+func (recommender *RecommendationService) Dismiss(user, dismissed string) {
+
+	handle := recommender.tweetManager.ResolveUser(user)
+	dismissedHandle := recommender.tweetManager.ResolveUser(dismissed)
+
+	recommender.mutex.Lock()
+	defer recommender.mutex.Unlock()
+
+	setFlag(recommender.dismissed, handle, dismissedHandle, true)
+}
+
+func (recommender *RecommendationService) recommend(user, candidate string) Recommendation {
+
+	recommendation := Recommendation{User: candidate, Reasons: make([]string, 0)}
+
+	if count := recommender.friendsOfFriends[user][candidate]; count > 0 {
+		recommendation.Score += count * friendOfFriendWeight
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("followed by %d %s you follow", count, plural(count, "person", "people")))
+	}
+
+	if count := recommender.interactions[user][candidate]; count > 0 {
+		recommendation.Score += count * interactionWeight
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("quoted or replied %d %s between you", count, plural(count, "time", "times")))
+	}
+
+	if count := recommender.sharedHashtags[user][candidate]; count > 0 {
+		recommendation.Score += count * sharedHashtagWeight
+		recommendation.Reasons = append(recommendation.Reasons,
+			fmt.Sprintf("uses %d %s you use", count, plural(count, "hashtag", "hashtags")))
+	}
+
+	return recommendation
+}
+
+func (recommender *RecommendationService) handleEvent(event Event) error {
+
+	recommender.mutex.Lock()
+	defer recommender.mutex.Unlock()
+
+	switch event := event.(type) {
+	case TweetPublished:
+		recommender.tweetPublished(event.Tweet)
+	case UserFollowed:
+		recommender.userFollowed(event.Follower, event.Followed, 1)
+	case UserUnfollowed:
+		recommender.userFollowed(event.Follower, event.Followed, -1)
+	case UserRenamed:
+		recommender.userRenamed(event.From, event.To)
+	}
+
+	return nil
+}
+
+func (recommender *RecommendationService) tweetPublished(tweet domain.Tweet) {
+
+	user := domain.NormalizeHandle(tweet.GetUser())
+
+	for _, hashtag := range domain.Hashtags(tweet.GetText()) {
+
+		if recommender.hashtagUsers[hashtag] == nil {
+			recommender.hashtagUsers[hashtag] = make(map[string]bool)
+		}
+
+		if recommender.hashtagUsers[hashtag][user] {
+			continue
+		}
+
+		for other := range recommender.hashtagUsers[hashtag] {
+			addCount(recommender.sharedHashtags, user, other, 1)
+			addCount(recommender.sharedHashtags, other, user, 1)
+		}
+
+		recommender.hashtagUsers[hashtag][user] = true
+	}
+
+	interacted := ""
+
+	if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok && quoteTweet.QuotedTweet != nil {
+		interacted = domain.NormalizeHandle(quoteTweet.QuotedTweet.GetUser())
+	} else if replied := domain.RepliedUser(tweet.GetText()); replied != "" {
+		interacted = recommender.tweetManager.ResolveUser(replied)
+	}
+
+	if interacted != "" && interacted != user {
+		addCount(recommender.interactions, user, interacted, 1)
+		addCount(recommender.interactions, interacted, user, 1)
+	}
+}
+
+// userFollowed updates the friends of friends counters when the follower
+// starts (delta 1) or stops (delta -1) following the followed user
+func (recommender *RecommendationService) userFollowed(follower, followed string, delta int) {
+
+	for followedByFollowed := range recommender.following[followed] {
+		addCount(recommender.friendsOfFriends, follower, followedByFollowed, delta)
+	}
+
+	for followerOfFollower := range recommender.followers[follower] {
+		addCount(recommender.friendsOfFriends, followerOfFollower, followed, delta)
+	}
+
+	setFlag(recommender.following, follower, followed, delta > 0)
+	setFlag(recommender.followers, followed, follower, delta > 0)
+}
+
+func (recommender *RecommendationService) userRenamed(from, to string) {
+
+	for _, counters := range []map[string]map[string]int{
+		recommender.friendsOfFriends, recommender.sharedHashtags, recommender.interactions,
+	} {
+		renameKey(counters, from, to)
+		for _, inner := range counters {
+			renameKey(inner, from, to)
+		}
+	}
+
+	for _, flags := range []map[string]map[string]bool{
+		recommender.following, recommender.followers, recommender.dismissed,
+	} {
+		renameKey(flags, from, to)
+		for _, inner := range flags {
+			renameKey(inner, from, to)
+		}
+	}
+
+	for _, users := range recommender.hashtagUsers {
+		renameKey(users, from, to)
+	}
+}
+
+func addCount(counters map[string]map[string]int, user, other string, delta int) {
+
+	if counters[user] == nil {
+		counters[user] = make(map[string]int)
+	}
+
+	counters[user][other] += delta
+
+	if counters[user][other] <= 0 {
+		delete(counters[user], other)
+	}
+}
+
+func setFlag(flags map[string]map[string]bool, user, other string, value bool) {
+
+	if !value {
+		delete(flags[user], other)
+		return
+	}
+
+	if flags[user] == nil {
+		flags[user] = make(map[string]bool)
+	}
+
+	flags[user][other] = true
+}
+
+func renameKey(values interface{}, from, to string) {
+
+	switch values := values.(type) {
+	case map[string]int:
+		if value, found := values[from]; found {
+			values[to] = value
+			delete(values, from)
+		}
+	case map[string]bool:
+		if value, found := values[from]; found {
+			values[to] = value
+			delete(values, from)
+		}
+	case map[string]map[string]int:
+		if value, found := values[from]; found {
+			values[to] = value
+			delete(values, from)
+		}
+	case map[string]map[string]bool:
+		if value, found := values[from]; found {
+			values[to] = value
+			delete(values, from)
+		}
+	}
+}
+
+func plural(count int, singular, plural string) string {
+
+	if count == 1 {
+		return singular
+	}
+
+	return plural
+}
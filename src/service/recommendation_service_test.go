@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func newManagerWithUsers(users ...string) *service.TweetManager {
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	ctx := context.Background()
+
+	for _, user := range users {
+		tweetManager.PublishTweet(ctx, domain.NewTextTweet(user, "Hello"))
+	}
+
+	return tweetManager
+}
+
+func TestFriendsOfFriendsAreRecommended(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera", "gonzalo", "mariana")
+	recommendationService := service.NewRecommendationService(tweetManager)
+
+	tweetManager.Follow("nick", "grupoesfera")
+	tweetManager.Follow("nick", "gonzalo")
+	tweetManager.Follow("grupoesfera", "mariana")
+	tweetManager.Follow("gonzalo", "mariana")
+
+	// Operation
+	recommendations := recommendationService.GetRecommendations("nick", 10)
+
+	// Validation
+	expected := []service.Recommendation{
+		{User: "mariana", Score: 6, Reasons: []string{"followed by 2 people you follow"}},
+	}
+	if !reflect.DeepEqual(recommendations, expected) {
+		t.Errorf("Expected recommendations are %v but were %v", expected, recommendations)
+	}
+}
+
+func TestUnfollowingUpdatesRecommendations(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera", "mariana")
+	recommendationService := service.NewRecommendationService(tweetManager)
+
+	tweetManager.Follow("nick", "grupoesfera")
+	tweetManager.Follow("grupoesfera", "mariana")
+
+	// Operation
+	tweetManager.Unfollow("nick", "grupoesfera")
+
+	// Validation
+	if recommendations := recommendationService.GetRecommendations("nick", 10); len(recommendations) != 0 {
+		t.Errorf("Expected no recommendations but were %v", recommendations)
+	}
+}
+
+func TestUsersSharingHashtagsAndInteractingAreRecommended(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+	recommendationService := service.NewRecommendationService(tweetManager)
+
+	ctx := context.Background()
+
+	golangTweet := domain.NewTextTweet("grupoesfera", "Learning #golang")
+	tweetManager.PublishTweet(ctx, golangTweet)
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "I love #Golang"))
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("mariana", "@nick hi!"))
+	tweetManager.PublishTweet(ctx, domain.NewQuoteTweet("mariana", "So true", golangTweet))
+
+	// Operation
+	recommendations := recommendationService.GetRecommendations("nick", 10)
+
+	// Validation
+	expected := []service.Recommendation{
+		{User: "mariana", Score: 2, Reasons: []string{"quoted or replied 1 time between you"}},
+		{User: "grupoesfera", Score: 1, Reasons: []string{"uses 1 hashtag you use"}},
+	}
+	if !reflect.DeepEqual(recommendations, expected) {
+		t.Errorf("Expected recommendations are %v but were %v", expected, recommendations)
+	}
+}
+
+func TestDismissedUsersAreNotRecommended(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera", "mariana")
+	recommendationService := service.NewRecommendationService(tweetManager)
+
+	tweetManager.Follow("nick", "grupoesfera")
+	tweetManager.Follow("grupoesfera", "mariana")
+
+	// Operation
+	recommendationService.Dismiss("nick", "mariana")
+
+	// Validation
+	if recommendations := recommendationService.GetRecommendations("nick", 10); len(recommendations) != 0 {
+		t.Errorf("Expected no recommendations but were %v", recommendations)
+	}
+}
+
+func TestRecommendationsCanBeReadWhileUsersPublishAndFollow(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick", "grupoesfera", "mariana")
+	recommendationService := service.NewRecommendationService(tweetManager)
+
+	ctx := context.Background()
+
+	var writers sync.WaitGroup
+	writers.Add(2)
+
+	// Operation
+	go func() {
+		defer writers.Done()
+		for n := 0; n < 100; n++ {
+			tweetManager.PublishTweet(ctx, domain.NewTextTweet("mariana", "Learning #golang"))
+		}
+	}()
+
+	go func() {
+		defer writers.Done()
+		for n := 0; n < 100; n++ {
+			tweetManager.Follow("nick", "grupoesfera")
+			tweetManager.Follow("grupoesfera", "mariana")
+			tweetManager.Unfollow("nick", "grupoesfera")
+		}
+	}()
+
+	readUntilDone(&writers, func() {
+		recommendationService.GetRecommendations("nick", 10)
+		recommendationService.Dismiss("grupoesfera", "nick")
+	})
+
+	// Validation
+	if recommendations := recommendationService.GetRecommendations("grupoesfera", 10); len(recommendations) != 0 {
+		t.Errorf("Expected the dismissed user not to be recommended but were %v", recommendations)
+	}
+}
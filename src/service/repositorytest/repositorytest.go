@@ -0,0 +1,360 @@
+// Package repositorytest has the conformance tests every TweetRepository
+// implementation must pass
+package repositorytest
+
+import (
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+// TestRepository runs the conformance tests against the repositories
+// created by newRepository, which must return an empty repository each time
+func TestRepository(t *testing.T, newRepository func() service.TweetRepository) {
+
+	tests := map[string]func(*testing.T, service.TweetRepository){
+		"SaveGivesConsecutiveIds":         testSaveGivesConsecutiveIds,
+		"SaveKeepsTheIdOfTheTweet":        testSaveKeepsTheIdOfTheTweet,
+		"SaveFailsWithAnExistingId":       testSaveFailsWithAnExistingId,
+		"GetReturnsTheSavedTweet":         testGetReturnsTheSavedTweet,
+		"GetFailsWithAnUnknownId":         testGetFailsWithAnUnknownId,
+		"ListIsSortedById":                testListIsSortedById,
+		"ListByUserNormalizesTheHandle":   testListByUserNormalizesTheHandle,
+		"ListsAreCopies":                  testListsAreCopies,
+		"CountsTheTweets":                 testCountsTheTweets,
+		"DeleteRemovesTheTweet":           testDeleteRemovesTheTweet,
+		"DeleteFailsWithAnUnknownId":      testDeleteFailsWithAnUnknownId,
+		"IdsAreNotReusedAfterDelete":      testIdsAreNotReusedAfterDelete,
+		"SetLastIdSkipsIds":               testSetLastIdSkipsIds,
+		"RenameUserMovesItsTweets":        testRenameUserMovesItsTweets,
+		"RenameUserFailsIfTheUserIsTaken": testRenameUserFailsIfTheUserIsTaken,
+		"RenameUserKeepsReturnedTweets":   testRenameUserKeepsReturnedTweets,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepository())
+		})
+	}
+}
+
+func save(t *testing.T, repository service.TweetRepository, user, text string) domain.Tweet {
+
+	tweet := domain.NewTextTweet(user, text)
+
+	if _, err := repository.Save(tweet); err != nil {
+		t.Fatalf("Unexpected error saving the tweet: %s", err.Error())
+	}
+
+	return tweet
+}
+
+func assertIds(t *testing.T, tweets []domain.Tweet, ids ...int) {
+
+	if len(tweets) != len(ids) {
+		t.Fatalf("Expected %d tweets but were %d", len(ids), len(tweets))
+	}
+
+	for index, id := range ids {
+		if tweets[index].GetId() != id {
+			t.Fatalf("Expected tweet %d at position %d but was %d", id, index, tweets[index].GetId())
+		}
+	}
+}
+
+func testSaveGivesConsecutiveIds(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	first := domain.NewTextTweet("grupoesfera", "First")
+	second := domain.NewTextTweet("grupoesfera", "Second")
+
+	// Operation
+	firstId, firstErr := repository.Save(first)
+	secondId, secondErr := repository.Save(second)
+
+	// Validation
+	if firstErr != nil || secondErr != nil {
+		t.Fatalf("Unexpected errors %v and %v", firstErr, secondErr)
+	}
+
+	if firstId != 1 || secondId != 2 || first.GetId() != 1 || second.GetId() != 2 {
+		t.Errorf("Expected ids 1 and 2 but were %d and %d", firstId, secondId)
+	}
+}
+
+func testSaveKeepsTheIdOfTheTweet(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	tweet := domain.NewTextTweet("grupoesfera", "Restored")
+	tweet.SetId(10)
+
+	// Operation
+	id, _ := repository.Save(tweet)
+	next := save(t, repository, "grupoesfera", "New")
+
+	// Validation
+	if id != 10 || next.GetId() != 11 {
+		t.Errorf("Expected ids 10 and 11 but were %d and %d", id, next.GetId())
+	}
+}
+
+func testSaveFailsWithAnExistingId(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+
+	tweet := domain.NewTextTweet("nick", "Duplicated")
+	tweet.SetId(1)
+
+	// Operation
+	_, err := repository.Save(tweet)
+
+	// Validation
+	if err == nil || err.Error() != "tweet 1 already exists" {
+		t.Errorf("Expected a duplicated id error but was %v", err)
+	}
+}
+
+func testGetReturnsTheSavedTweet(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+	save(t, repository, "nick", "Second")
+
+	// Operation
+	tweet, err := repository.Get(2)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	if tweet.GetId() != 2 || tweet.GetUser() != "nick" || tweet.GetText() != "Second" {
+		t.Errorf("Expected the second tweet but was %s", tweet.PrintableTweet())
+	}
+}
+
+func testGetFailsWithAnUnknownId(t *testing.T, repository service.TweetRepository) {
+
+	// Operation
+	tweet, err := repository.Get(1)
+
+	// Validation
+	if tweet != nil || err == nil || err.Error() != "tweet 1 does not exist" {
+		t.Errorf("Expected a missing tweet error but was %v", err)
+	}
+}
+
+func testListIsSortedById(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	late := domain.NewTextTweet("grupoesfera", "Late")
+	late.SetId(5)
+	repository.Save(late)
+
+	early := domain.NewTextTweet("grupoesfera", "Early")
+	early.SetId(2)
+	repository.Save(early)
+
+	// Operation
+	save(t, repository, "nick", "Newest")
+
+	// Validation
+	assertIds(t, repository.List(), 2, 5, 6)
+}
+
+func testListByUserNormalizesTheHandle(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "GrupoEsfera", "First")
+	save(t, repository, "nick", "Second")
+	save(t, repository, "grupoesfera", "Third")
+
+	// Operation
+	tweets := repository.ListByUser("@grupoEsfera")
+
+	// Validation
+	assertIds(t, tweets, 1, 3)
+	assertIds(t, repository.ListByUser("nobody"))
+}
+
+func testListsAreCopies(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+
+	// Operation
+	tweets := repository.List()
+	tweets[0] = nil
+
+	userTweets := repository.ListByUser("grupoesfera")
+	userTweets[0] = nil
+
+	// Validation
+	assertIds(t, repository.List(), 1)
+	assertIds(t, repository.ListByUser("grupoesfera"), 1)
+}
+
+func testCountsTheTweets(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+	save(t, repository, "nick", "Second")
+	save(t, repository, "grupoesfera", "Third")
+
+	// Validation
+	if repository.Count() != 3 {
+		t.Errorf("Expected 3 tweets but were %d", repository.Count())
+	}
+
+	if count := repository.CountByUser("GrupoEsfera"); count != 2 {
+		t.Errorf("Expected 2 tweets of grupoesfera but were %d", count)
+	}
+
+	if count := repository.CountByUser("nobody"); count != 0 {
+		t.Errorf("Expected no tweets of nobody but were %d", count)
+	}
+}
+
+func testDeleteRemovesTheTweet(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+	save(t, repository, "grupoesfera", "Second")
+	save(t, repository, "nick", "Third")
+
+	// Operation
+	err := repository.Delete(2)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	if _, err := repository.Get(2); err == nil {
+		t.Errorf("Expected the deleted tweet to be missing")
+	}
+
+	assertIds(t, repository.List(), 1, 3)
+	assertIds(t, repository.ListByUser("grupoesfera"), 1)
+
+	if repository.Count() != 2 || repository.CountByUser("grupoesfera") != 1 {
+		t.Errorf("Expected the deleted tweet not to be counted")
+	}
+}
+
+func testDeleteFailsWithAnUnknownId(t *testing.T, repository service.TweetRepository) {
+
+	// Operation
+	err := repository.Delete(7)
+
+	// Validation
+	if err == nil || err.Error() != "tweet 7 does not exist" {
+		t.Errorf("Expected a missing tweet error but was %v", err)
+	}
+}
+
+func testIdsAreNotReusedAfterDelete(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+	save(t, repository, "grupoesfera", "Second")
+	repository.Delete(2)
+
+	// Operation
+	tweet := save(t, repository, "grupoesfera", "Third")
+
+	// Validation
+	if tweet.GetId() != 3 {
+		t.Errorf("Expected id 3 but was %d", tweet.GetId())
+	}
+}
+
+func testSetLastIdSkipsIds(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+
+	// Operation
+	repository.SetLastId(7)
+	afterSeven := save(t, repository, "grupoesfera", "Second")
+
+	repository.SetLastId(3)
+	afterThree := save(t, repository, "grupoesfera", "Third")
+
+	// Validation
+	if afterSeven.GetId() != 8 || afterThree.GetId() != 9 {
+		t.Errorf("Expected ids 8 and 9 but were %d and %d", afterSeven.GetId(), afterThree.GetId())
+	}
+}
+
+func testRenameUserMovesItsTweets(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+	save(t, repository, "nick", "Second")
+
+	// Operation
+	err := repository.RenameUser("grupoesfera", "Esfera")
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	assertIds(t, repository.ListByUser("esfera"), 1)
+	assertIds(t, repository.ListByUser("grupoesfera"))
+
+	if tweet, _ := repository.Get(1); tweet.GetUser() != "Esfera" {
+		t.Errorf("Expected the user of the tweet to be Esfera but was %s", tweet.GetUser())
+	}
+
+	if repository.CountByUser("esfera") != 1 || repository.CountByUser("grupoesfera") != 0 {
+		t.Errorf("Expected the tweets to be counted for the new user")
+	}
+}
+
+func testRenameUserFailsIfTheUserIsTaken(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	save(t, repository, "grupoesfera", "First")
+	save(t, repository, "nick", "Second")
+
+	// Operation
+	err := repository.RenameUser("grupoesfera", "nick")
+
+	// Validation
+	if err == nil || err.Error() != "user nick already has tweets" {
+		t.Errorf("Expected a taken user error but was %v", err)
+	}
+
+	assertIds(t, repository.ListByUser("grupoesfera"), 1)
+}
+
+func testRenameUserKeepsReturnedTweets(t *testing.T, repository service.TweetRepository) {
+
+	// Initialization
+	quoted := save(t, repository, "grupoesfera", "First")
+
+	if _, err := repository.Save(domain.NewQuoteTweet("nick", "Me too", quoted)); err != nil {
+		t.Fatalf("Unexpected error saving the quote: %s", err.Error())
+	}
+
+	before, _ := repository.Get(1)
+
+	// Operation
+	repository.RenameUser("grupoesfera", "Esfera")
+
+	// Validation
+	if before.GetUser() != "grupoesfera" {
+		t.Errorf("Expected the tweet returned before the rename not to change but its user was %s", before.GetUser())
+	}
+
+	after, _ := repository.Get(2)
+
+	if quoteTweet, ok := after.(*domain.QuoteTweet); !ok || quoteTweet.QuotedTweet == nil || quoteTweet.QuotedTweet.GetUser() != "Esfera" {
+		t.Errorf("Expected the quote to quote the renamed tweet but was %v", after)
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+// sqlMigrations create the schema of the SQLTweetRepository. Each one is
+// applied once, in order, so new ones must be added at the end
+var sqlMigrations = []string{
+	`CREATE TABLE tweets (
+		id        INTEGER PRIMARY KEY,
+		kind      VARCHAR(10) NOT NULL,
+		author    VARCHAR(20) NOT NULL,
+		handle    VARCHAR(20) NOT NULL,
+		text      VARCHAR(560) NOT NULL,
+		date      BIGINT NOT NULL,
+		url       VARCHAR(2048),
+		quoted_id INTEGER REFERENCES tweets (id) ON DELETE SET NULL
+	)`,
+	`CREATE TABLE sequences (
+		name  VARCHAR(20) PRIMARY KEY,
+		value INTEGER NOT NULL
+	)`,
+	`INSERT INTO sequences (name, value) VALUES ('tweets', 0)`,
+	`CREATE INDEX tweets_by_handle ON tweets (handle, id)`,
+	`CREATE INDEX tweets_by_date ON tweets (date)`,
+	`CREATE INDEX tweets_by_quoted ON tweets (quoted_id)`,
+	`ALTER TABLE tweets ADD COLUMN quoted_user VARCHAR(20)`,
+	`ALTER TABLE tweets ADD COLUMN quoted_text VARCHAR(560)`,
+}
+
+const tweetColumns = "id, kind, author, text, date, url, quoted_id, quoted_user, quoted_text"
+
+// SQLTweetRepository stores the tweets in a database. Quotes keep the id
+// of the quoted tweet, which is loaded with them. Reads that fail are
+// logged and return no tweets
+type SQLTweetRepository struct {
+	db *sql.DB
+}
+
+func NewSQLTweetRepository(db *sql.DB) (*SQLTweetRepository, error) {
+
+	repository := new(SQLTweetRepository)
+
+	repository.db = db
+
+	if err := repository.migrate(); err != nil {
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+func (repository *SQLTweetRepository) migrate() error {
+
+	_, err := repository.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+
+	if err != nil {
+		return err
+	}
+
+	var version int
+
+	if err := repository.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+
+		err := repository.inTransaction(func(tx *sql.Tx) error {
+
+			if _, err := tx.Exec(sqlMigrations[version]); err != nil {
+				return fmt.Errorf("migration %d failed: %s", version+1, err.Error())
+			}
+
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version+1)
+
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repository *SQLTweetRepository) Save(tweet domain.Tweet) (int, error) {
+
+	record := newTweetRecord(tweet)
+
+	err := repository.inTransaction(func(tx *sql.Tx) error {
+
+		var lastId int
+
+		if err := tx.QueryRow(`SELECT value FROM sequences WHERE name = 'tweets'`).Scan(&lastId); err != nil {
+			return err
+		}
+
+		if record.Id == 0 {
+			record.Id = lastId + 1
+		}
+
+		var exists int
+
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tweets WHERE id = ?`, record.Id).Scan(&exists); err != nil {
+			return err
+		}
+
+		if exists > 0 {
+			return fmt.Errorf("tweet %d already exists", record.Id)
+		}
+
+		_, err := tx.Exec(
+			`INSERT INTO tweets (`+tweetColumns+`, handle) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			record.Id, record.Kind, record.User, record.Text, record.Date.UnixNano(),
+			nullString(record.URL), nullInt(record.QuotedId), nullString(record.QuotedUser),
+			nullString(record.QuotedText), domain.NormalizeHandle(record.User),
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if record.Id > lastId {
+			_, err = tx.Exec(`UPDATE sequences SET value = ? WHERE name = 'tweets'`, record.Id)
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	tweet.SetId(record.Id)
+
+	return record.Id, nil
+}
+
+func (repository *SQLTweetRepository) Get(id int) (domain.Tweet, error) {
+
+	tweets, err := repository.query(`SELECT `+tweetColumns+` FROM tweets WHERE id = ?`, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tweets) == 0 {
+		return nil, fmt.Errorf("tweet %d does not exist", id)
+	}
+
+	return tweets[0], nil
+}
+
+func (repository *SQLTweetRepository) List() []domain.Tweet {
+
+	return repository.list(`SELECT ` + tweetColumns + ` FROM tweets ORDER BY id`)
+}
+
+func (repository *SQLTweetRepository) ListByUser(user string) []domain.Tweet {
+
+	return repository.list(`SELECT `+tweetColumns+` FROM tweets WHERE handle = ? ORDER BY id`,
+		domain.NormalizeHandle(user))
+}
+
+func (repository *SQLTweetRepository) ListBetween(from, to time.Time) []domain.Tweet {
+
+	return repository.list(`SELECT `+tweetColumns+` FROM tweets WHERE date >= ? AND date < ? ORDER BY date, id`,
+		from.UnixNano(), to.UnixNano())
+}
+
+func (repository *SQLTweetRepository) Count() int {
+	return repository.count(`SELECT COUNT(*) FROM tweets`)
+}
+
+func (repository *SQLTweetRepository) CountByUser(user string) int {
+	return repository.count(`SELECT COUNT(*) FROM tweets WHERE handle = ?`, domain.NormalizeHandle(user))
+}
+
+func (repository *SQLTweetRepository) Delete(id int) error {
+
+	return repository.inTransaction(func(tx *sql.Tx) error {
+
+		if _, err := tx.Exec(`UPDATE tweets SET quoted_id = NULL WHERE quoted_id = ?`, id); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`DELETE FROM tweets WHERE id = ?`, id)
+
+		if err != nil {
+			return err
+		}
+
+		if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+			return fmt.Errorf("tweet %d does not exist", id)
+		}
+
+		return nil
+	})
+}
+
+func (repository *SQLTweetRepository) SetLastId(id int) {
+
+	if _, err := repository.db.Exec(`UPDATE sequences SET value = ? WHERE name = 'tweets' AND value < ?`, id, id); err != nil {
+		log.Printf("tweet repository couldn't set the last id: %s", err.Error())
+	}
+}
+
+func (repository *SQLTweetRepository) RenameUser(oldUser, newUser string) error {
+
+	oldHandle := domain.NormalizeHandle(oldUser)
+	newHandle := domain.NormalizeHandle(newUser)
+
+	return repository.inTransaction(func(tx *sql.Tx) error {
+
+		if oldHandle != newHandle {
+
+			var count int
+
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM tweets WHERE handle = ?`, newHandle).Scan(&count); err != nil {
+				return err
+			}
+
+			if count > 0 {
+				return fmt.Errorf("user %s already has tweets", newHandle)
+			}
+		}
+
+		_, err := tx.Exec(`UPDATE tweets SET author = ?, handle = ? WHERE handle = ?`, newUser, newHandle, oldHandle)
+
+		return err
+	})
+}
+
+func (repository *SQLTweetRepository) list(query string, args ...interface{}) []domain.Tweet {
+
+	tweets, err := repository.query(query, args...)
+
+	if err != nil {
+		log.Printf("tweet repository couldn't list the tweets: %s", err.Error())
+		return nil
+	}
+
+	return tweets
+}
+
+func (repository *SQLTweetRepository) count(query string, args ...interface{}) int {
+
+	var count int
+
+	if err := repository.db.QueryRow(query, args...).Scan(&count); err != nil {
+		log.Printf("tweet repository couldn't count the tweets: %s", err.Error())
+		return 0
+	}
+
+	return count
+}
+
+// query returns the tweets of the rows, loading the tweets they quote
+func (repository *SQLTweetRepository) query(query string, args ...interface{}) ([]domain.Tweet, error) {
+
+	records, err := repository.queryRecords(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	found := len(records)
+
+	loaded := make(map[int]bool, len(records))
+	for _, record := range records {
+		loaded[record.Id] = true
+	}
+
+	for index := 0; index < len(records); index++ {
+
+		quotedId := records[index].QuotedId
+
+		if quotedId == 0 || loaded[quotedId] {
+			continue
+		}
+
+		quoted, err := repository.queryRecords(`SELECT `+tweetColumns+` FROM tweets WHERE id = ?`, quotedId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		loaded[quotedId] = true
+		records = append(records, quoted...)
+	}
+
+	tweets, err := tweetsOfRecords(records)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tweets[:found], nil
+}
+
+func (repository *SQLTweetRepository) queryRecords(query string, args ...interface{}) ([]tweetRecord, error) {
+
+	rows, err := repository.db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	records := make([]tweetRecord, 0)
+
+	for rows.Next() {
+
+		var record tweetRecord
+		var date int64
+		var url sql.NullString
+		var quotedId sql.NullInt64
+		var quotedUser, quotedText sql.NullString
+
+		err := rows.Scan(&record.Id, &record.Kind, &record.User, &record.Text, &date, &url, &quotedId,
+			&quotedUser, &quotedText)
+
+		if err != nil {
+			return nil, err
+		}
+
+		record.Date = time.Unix(0, date)
+		record.URL = url.String
+		record.QuotedId = int(quotedId.Int64)
+		record.QuotedUser = quotedUser.String
+		record.QuotedText = quotedText.String
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func (repository *SQLTweetRepository) inTransaction(operation func(tx *sql.Tx) error) error {
+
+	tx, err := repository.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err := operation(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
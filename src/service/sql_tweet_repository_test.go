@@ -0,0 +1,239 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+	"github.com/cursoGo/src/service/repositorytest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDatabase(t *testing.T, path string) *sql.DB {
+
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=1")
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening the database: %s", err.Error())
+	}
+
+	db.SetMaxOpenConns(1)
+
+	return db
+}
+
+func newSQLRepository(t *testing.T, db *sql.DB) *service.SQLTweetRepository {
+
+	repository, err := service.NewSQLTweetRepository(db)
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating the repository: %s", err.Error())
+	}
+
+	return repository
+}
+
+func tempDatabasePath(t *testing.T) (string, func()) {
+
+	directory, err := ioutil.TempDir("", "tweetdb")
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	return filepath.Join(directory, "tweets.db"), func() { os.RemoveAll(directory) }
+}
+
+func TestSQLTweetRepository(t *testing.T) {
+
+	path, remove := tempDatabasePath(t)
+	defer remove()
+
+	databases := make([]*sql.DB, 0)
+
+	repositorytest.TestRepository(t, func() service.TweetRepository {
+		db := openDatabase(t, fmt.Sprintf("%s.%d", path, len(databases)))
+		databases = append(databases, db)
+		return newSQLRepository(t, db)
+	})
+
+	for _, db := range databases {
+		db.Close()
+	}
+}
+
+func TestSQLTweetRepositoryKeepsTheTweetsAfterReopening(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDatabasePath(t)
+	defer remove()
+
+	db := openDatabase(t, path)
+	tweetManager := service.NewTweetManager(newSQLRepository(t, db), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	quoted := domain.NewTextTweet("grupoesfera", "First")
+	tweetManager.PublishTweet(context.Background(), quoted)
+	tweetManager.PublishTweet(context.Background(), domain.NewQuoteTweet("nick", "Nice", quoted))
+	tweetManager.PublishTweet(context.Background(), domain.NewImageTweet("nick", "Look", "http://www.grupoesfera.com.ar/logo.png"))
+	db.Close()
+
+	// Operation
+	db = openDatabase(t, path)
+	defer db.Close()
+
+	tweetManager = service.NewTweetManager(newSQLRepository(t, db), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	// Validation
+	quote, ok := tweetManager.GetTweetById(2).(*domain.QuoteTweet)
+
+	if !ok || quote.QuotedTweet == nil || quote.QuotedTweet.GetText() != "First" {
+		t.Fatalf("Expected the quote to be loaded with the quoted tweet but was %v", tweetManager.GetTweetById(2))
+	}
+
+	if date := tweetManager.GetTweetById(1).GetDate(); !date.Equal(*quoted.Date) {
+		t.Errorf("Expected the date to be %s but was %s", quoted.Date, date)
+	}
+
+	image, ok := tweetManager.GetTweetById(3).(*domain.ImageTweet)
+
+	if !ok || image.URL != "http://www.grupoesfera.com.ar/logo.png" {
+		t.Errorf("Expected the image tweet to be loaded but was %v", tweetManager.GetTweetById(3))
+	}
+
+	if count := tweetManager.CountTweetsByUser("nick"); count != 2 {
+		t.Errorf("Expected 2 tweets of nick but were %d", count)
+	}
+
+	id, _ := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "Again"))
+
+	if id != 4 {
+		t.Errorf("Expected the next id to be 4 but was %d", id)
+	}
+}
+
+func TestSQLTweetRepositoryStoresQuotesByForeignKey(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDatabasePath(t)
+	defer remove()
+
+	db := openDatabase(t, path)
+	defer db.Close()
+
+	repository := newSQLRepository(t, db)
+
+	quoted := domain.NewTextTweet("grupoesfera", "First")
+	repository.Save(quoted)
+
+	quote := domain.NewQuoteTweet("nick", "Nice", quoted)
+	repository.Save(quote)
+	repository.Save(domain.NewQuoteTweet("nick", "Quoting a quote", quote))
+
+	// Operation
+	var quotedId int
+	db.QueryRow(`SELECT quoted_id FROM tweets WHERE id = 2`).Scan(&quotedId)
+
+	chained := repository.ListByUser("nick")[1].(*domain.QuoteTweet)
+
+	repository.Delete(1)
+
+	// Validation
+	if quotedId != 1 {
+		t.Errorf("Expected the quote to reference tweet 1 but was %d", quotedId)
+	}
+
+	if inner, ok := chained.QuotedTweet.(*domain.QuoteTweet); !ok || inner.QuotedTweet.GetText() != "First" {
+		t.Errorf("Expected the quoted quotes to be loaded")
+	}
+
+	if orphan, _ := repository.Get(2); orphan.(*domain.QuoteTweet).QuotedTweet != nil {
+		t.Errorf("Expected the quote of a deleted tweet to have no quoted tweet")
+	}
+}
+
+func TestSQLTweetRepositoryKeepsTheQuotedTweetOfDetachedQuotes(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDatabasePath(t)
+	defer remove()
+
+	db := openDatabase(t, path)
+	defer db.Close()
+
+	repository := newSQLRepository(t, db)
+
+	quoted := domain.NewTextTweet("grupoesfera", "Archived")
+	id, _ := repository.Save(domain.NewQuoteTweet("nick", "Nice", quoted))
+
+	// Operation
+	tweet, err := repository.Get(id)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error getting the quote: %s", err.Error())
+	}
+
+	detached := tweet.(*domain.QuoteTweet).QuotedTweet
+
+	if detached == nil || detached.GetUser() != "grupoesfera" || detached.GetText() != "Archived" {
+		t.Errorf("Expected the quote to keep the quoted tweet of grupoesfera")
+	}
+}
+
+func TestSQLTweetRepositoryListsBetweenDates(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDatabasePath(t)
+	defer remove()
+
+	db := openDatabase(t, path)
+	defer db.Close()
+
+	repository := newSQLRepository(t, db)
+
+	day := time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	for offset, text := range []string{"Before", "During", "After"} {
+		tweet := domain.NewTextTweet("grupoesfera", text)
+		date := day.AddDate(0, 0, offset-1).Add(time.Hour)
+		tweet.Date = &date
+		repository.Save(tweet)
+	}
+
+	// Operation
+	tweets := repository.ListBetween(day, day.AddDate(0, 0, 1))
+
+	// Validation
+	assertTexts(t, tweets, "During")
+}
+
+func TestSQLTweetRepositoryMigratesOnlyOnce(t *testing.T) {
+
+	// Initialization
+	path, remove := tempDatabasePath(t)
+	defer remove()
+
+	db := openDatabase(t, path)
+	defer db.Close()
+
+	newSQLRepository(t, db).Save(domain.NewTextTweet("grupoesfera", "First"))
+
+	// Operation
+	repository, err := service.NewSQLTweetRepository(db)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error migrating again: %s", err.Error())
+	}
+
+	if repository.Count() != 1 {
+		t.Errorf("Expected the tweets to be kept but were %d", repository.Count())
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const dayLayout = "2006-01-02"
+
+// timeIndex buckets tweets by the day they were published in UTC, so
+// tweets and queries in different locations use the same buckets
+type timeIndex struct {
+	buckets map[string][]domain.Tweet
+	days    []string
+}
+
+func newTimeIndex() *timeIndex {
+
+	index := new(timeIndex)
+
+	index.buckets = make(map[string][]domain.Tweet)
+	index.days = make([]string, 0)
+
+	return index
+}
+
+func (index *timeIndex) add(tweet domain.Tweet) {
+
+	if tweet.GetDate() == nil {
+		return
+	}
+
+	day := tweet.GetDate().UTC().Format(dayLayout)
+
+	if _, found := index.buckets[day]; !found {
+		position := sort.SearchStrings(index.days, day)
+		index.days = append(index.days, "")
+		copy(index.days[position+1:], index.days[position:])
+		index.days[position] = day
+	}
+
+	index.buckets[day] = append(index.buckets[day], tweet)
+}
+
+func (index *timeIndex) remove(tweet domain.Tweet) {
+
+	if tweet.GetDate() == nil {
+		return
+	}
+
+	day := tweet.GetDate().UTC().Format(dayLayout)
+	tweets := index.buckets[day]
+
+	for position, indexed := range tweets {
+		if indexed.GetId() == tweet.GetId() {
+			tweets = append(tweets[:position], tweets[position+1:]...)
+			break
+		}
+	}
+
+	if len(tweets) > 0 {
+		index.buckets[day] = tweets
+		return
+	}
+
+	delete(index.buckets, day)
+
+	position := sort.SearchStrings(index.days, day)
+	if position < len(index.days) && index.days[position] == day {
+		index.days = append(index.days[:position], index.days[position+1:]...)
+	}
+}
+
+func (index *timeIndex) between(from, to time.Time) []domain.Tweet {
+
+	tweets := make([]domain.Tweet, 0)
+
+	first := sort.SearchStrings(index.days, from.UTC().Format(dayLayout))
+	last := to.UTC().Format(dayLayout)
+
+	for _, day := range index.days[first:] {
+
+		if day > last {
+			break
+		}
+
+		for _, tweet := range index.buckets[day] {
+			if date := *tweet.GetDate(); !date.Before(from) && date.Before(to) {
+				tweets = append(tweets, tweet)
+			}
+		}
+	}
+
+	sortByDate(tweets)
+
+	return tweets
+}
+
+// onDay returns the tweets published on the month and day of any year in
+// the location. Only the UTC buckets of the day before and after are
+// checked, as the day in the location is between them
+func (index *timeIndex) onDay(month time.Month, day int, location *time.Location) []domain.Tweet {
+
+	tweets := make([]domain.Tweet, 0)
+
+	for _, bucketDay := range index.days {
+
+		bucketDate, _ := time.Parse(dayLayout, bucketDay)
+
+		if !nearDay(bucketDate, month, day) {
+			continue
+		}
+
+		for _, tweet := range index.buckets[bucketDay] {
+			if date := tweet.GetDate().In(location); date.Month() == month && date.Day() == day {
+				tweets = append(tweets, tweet)
+			}
+		}
+	}
+
+	sortByDate(tweets)
+
+	return tweets
+}
+
+func nearDay(date time.Time, month time.Month, day int) bool {
+
+	for _, near := range []time.Time{date.AddDate(0, 0, -1), date, date.AddDate(0, 0, 1)} {
+		if near.Month() == month && near.Day() == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortByDate(tweets []domain.Tweet) {
+
+	sort.SliceStable(tweets, func(i, j int) bool {
+		if !tweets[i].GetDate().Equal(*tweets[j].GetDate()) {
+			return tweets[i].GetDate().Before(*tweets[j].GetDate())
+		}
+		return tweets[i].GetId() < tweets[j].GetId()
+	})
+}
+
+// GetTweetsBetween returns the tweets published from the from date and
+// before the to date, the oldest first
+func (manager *TweetManager) GetTweetsBetween(from, to time.Time) []domain.Tweet {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.tweetsByDay.between(from, to)
+}
+
+func (manager *TweetManager) GetTweetsByUserBetween(user string, from, to time.Time) []domain.Tweet {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	index, found := manager.tweetsByUserDay[manager.resolveUser(user)]
+
+	if !found {
+		return make([]domain.Tweet, 0)
+	}
+
+	return index.between(from, to)
+}
+
+// GetTweetsOnThisDay returns the tweets published on the same month and day
+// of the date on every year, the oldest first
+func (manager *TweetManager) GetTweetsOnThisDay(date time.Time) []domain.Tweet {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.tweetsByDay.onDay(date.Month(), date.Day(), date.Location())
+}
@@ -0,0 +1,189 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func tweetTexts(tweets []domain.Tweet) []string {
+
+	texts := make([]string, 0, len(tweets))
+
+	for _, tweet := range tweets {
+		texts = append(texts, tweet.GetText())
+	}
+
+	return texts
+}
+
+func assertTexts(t *testing.T, tweets []domain.Tweet, expected ...string) {
+
+	texts := tweetTexts(tweets)
+
+	if len(texts) != len(expected) {
+		t.Fatalf("Expected %v but was %v", expected, texts)
+	}
+
+	for index := range expected {
+		if texts[index] != expected[index] {
+			t.Fatalf("Expected %v but was %v", expected, texts)
+		}
+	}
+}
+
+func TestGetTweetsBetweenReturnsTheTweetsOfTheRangeOldestFirst(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	day := time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	publishAt(tweetManager, "grupoesfera", "Before", day.Add(-time.Minute))
+	publishAt(tweetManager, "grupoesfera", "Evening", day.Add(20*time.Hour))
+	publishAt(tweetManager, "nick", "Morning", day.Add(8*time.Hour))
+	publishAt(tweetManager, "nick", "After", day.AddDate(0, 0, 1))
+
+	// Operation
+	tweets := tweetManager.GetTweetsBetween(day, day.AddDate(0, 0, 1))
+
+	// Validation
+	assertTexts(t, tweets, "Morning", "Evening")
+}
+
+func TestGetTweetsBetweenSpansSeveralDays(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	day := time.Date(2017, 11, 3, 12, 0, 0, 0, time.UTC)
+
+	for offset := 0; offset < 5; offset++ {
+		date := day.AddDate(0, 0, offset)
+		publishAt(tweetManager, "grupoesfera", date.Format("2006-01-02"), date)
+	}
+
+	// Operation
+	tweets := tweetManager.GetTweetsBetween(day.AddDate(0, 0, 1), day.AddDate(0, 0, 3))
+
+	// Validation
+	assertTexts(t, tweets, "2017-11-04", "2017-11-05")
+}
+
+func TestGetTweetsByUserBetweenOnlyReturnsTheTweetsOfTheUser(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	day := time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	publishAt(tweetManager, "grupoesfera", "Mine", day.Add(time.Hour))
+	publishAt(tweetManager, "nick", "Not mine", day.Add(2*time.Hour))
+
+	// Operation
+	tweets := tweetManager.GetTweetsByUserBetween("@GrupoEsfera", day, day.AddDate(0, 0, 1))
+	unknown := tweetManager.GetTweetsByUserBetween("nobody", day, day.AddDate(0, 0, 1))
+
+	// Validation
+	assertTexts(t, tweets, "Mine")
+	assertTexts(t, unknown)
+}
+
+func TestGetTweetsByUserBetweenFollowsRenames(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	day := time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	publishAt(tweetManager, "grupoesfera", "Old name", day.Add(time.Hour))
+
+	// Operation
+	tweetManager.RenameUser("grupoesfera", "esfera")
+
+	// Validation
+	assertTexts(t, tweetManager.GetTweetsByUserBetween("esfera", day, day.AddDate(0, 0, 1)), "Old name")
+	assertTexts(t, tweetManager.GetTweetsByUserBetween("grupoesfera", day, day.AddDate(0, 0, 1)), "Old name")
+}
+
+func TestGetTweetsBetweenFindsTweetsOfOtherLocations(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	buenosAires := time.FixedZone("ART", -3*60*60)
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	// Late on the 3rd in Buenos Aires is the 4th in UTC and Tokyo
+	publishAt(tweetManager, "grupoesfera", "Late", time.Date(2017, 11, 3, 23, 0, 0, 0, buenosAires))
+	publishAt(tweetManager, "nick", "Early", time.Date(2017, 11, 4, 1, 0, 0, 0, tokyo))
+
+	// Operation
+	tweets := tweetManager.GetTweetsBetween(time.Date(2017, 11, 4, 8, 0, 0, 0, tokyo), time.Date(2017, 11, 4, 12, 0, 0, 0, tokyo))
+	early := tweetManager.GetTweetsBetween(time.Date(2017, 11, 3, 12, 0, 0, 0, buenosAires), time.Date(2017, 11, 3, 14, 0, 0, 0, buenosAires))
+
+	// Validation
+	assertTexts(t, tweets, "Late")
+	assertTexts(t, early, "Early")
+	assertTexts(t, tweetManager.GetTweetsOnThisDay(time.Date(2020, 11, 3, 0, 0, 0, 0, buenosAires)), "Early", "Late")
+	assertTexts(t, tweetManager.GetTweetsOnThisDay(time.Date(2020, 11, 4, 0, 0, 0, 0, tokyo)), "Early", "Late")
+}
+
+func TestGetTweetsOnThisDayReturnsTheTweetsOfEveryYear(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	publishAt(tweetManager, "grupoesfera", "2017", time.Date(2017, 11, 3, 10, 0, 0, 0, time.UTC))
+	publishAt(tweetManager, "grupoesfera", "Other day", time.Date(2016, 11, 4, 10, 0, 0, 0, time.UTC))
+	publishAt(tweetManager, "grupoesfera", "2015", time.Date(2015, 11, 3, 23, 0, 0, 0, time.UTC))
+
+	// Operation
+	tweets := tweetManager.GetTweetsOnThisDay(time.Date(2020, 11, 3, 0, 0, 0, 0, time.UTC))
+
+	// Validation
+	assertTexts(t, tweets, "2015", "2017")
+}
+
+func TestParseDateUnderstandsHumanDates(t *testing.T) {
+
+	// Initialization
+	now := time.Date(2017, 11, 3, 15, 30, 0, 0, time.UTC)
+
+	expected := map[string]time.Time{
+		"today":       time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC),
+		"Yesterday":   time.Date(2017, 11, 2, 0, 0, 0, 0, time.UTC),
+		"ayer":        time.Date(2017, 11, 2, 0, 0, 0, 0, time.UTC),
+		"3 days ago":  time.Date(2017, 10, 31, 0, 0, 0, 0, time.UTC),
+		"1 week ago":  time.Date(2017, 10, 27, 0, 0, 0, 0, time.UTC),
+		"2 years ago": time.Date(2015, 11, 3, 0, 0, 0, 0, time.UTC),
+		"2016-02-29":  time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC),
+		"03/11/2017":  time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	for text, date := range expected {
+
+		// Operation
+		parsed, err := service.ParseDate(text, now)
+
+		// Validation
+		if err != nil {
+			t.Errorf("Expected %s to be parsed but was %s", text, err.Error())
+		} else if !parsed.Equal(date) {
+			t.Errorf("Expected %s to be %s but was %s", text, date, parsed)
+		}
+	}
+}
+
+func TestParseDateRejectsUnknownDates(t *testing.T) {
+
+	// Operation
+	_, err := service.ParseDate("someday", time.Now())
+
+	// Validation
+	if err == nil || err.Error() != "invalid date someday, use a date like yesterday, 3 days ago or 2017-11-03" {
+		t.Errorf("Expected an invalid date error but was %v", err)
+	}
+}
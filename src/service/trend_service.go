@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/cursoGo/src/domain"
+)
+
+type TrendWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+type TrendConfig struct {
+	Windows []TrendWindow
+
+	// BaselineFactor is how many windows long is the baseline the actual
+	// window is compared with
+	BaselineFactor float64
+
+	// MinCount is how many times a term has to be used in the window to trend
+	MinCount float64
+
+	// MaxCandidates bounds the terms that are followed on every window
+	MaxCandidates int
+
+	SketchDepth int
+	SketchWidth int
+}
+
+func DefaultTrendConfig() TrendConfig {
+	return TrendConfig{
+		Windows: []TrendWindow{
+			{"hour", time.Hour},
+			{"day", 24 * time.Hour},
+		},
+		BaselineFactor: 24,
+		MinCount:       3,
+		MaxCandidates:  200,
+		SketchDepth:    4,
+		SketchWidth:    1024,
+	}
+}
+
+type Trend struct {
+	Term  string
+	Count int
+	Score float64
+}
+
+// TrendService finds the hashtags and terms whose usage grows faster than
+// their baseline. Counts decay exponentially and are kept in count-min
+// sketches, so memory doesn't grow with the amount of tweets or terms.
+// Tweets are counted asynchronously, so they may take a moment to trend
+type TrendService struct {
+	mutex        sync.Mutex
+	tweetManager *TweetManager
+	config       TrendConfig
+	windows      map[string]*windowTrends
+}
+
+type windowTrends struct {
+	current    *decayingCounter
+	baseline   *decayingCounter
+	candidates map[string]bool
+}
+
+// decayingCounter counts with forward decay: values are added already
+// scaled up from the landmark and scaled down when estimated
+type decayingCounter struct {
+	lifetime time.Duration
+	sketch   *CountMinSketch
+	landmark time.Time
+}
+
+// maxDecayExponent is how far from the landmark the counter can get before
+// being rescaled, to avoid overflows
+const maxDecayExponent = 50
+
+var stopwords = map[string]bool{
+	"this": true, "that": true, "with": true, "from": true, "have": true, "what": true,
+	"your": true, "about": true, "there": true, "their": true, "will": true, "just": true,
+	"para": true, "como": true, "pero": true, "esta": true, "este": true, "todo": true,
+	"porque": true, "cuando": true, "donde": true, "muy": true, "tiene": true, "sobre": true,
+}
+
+func NewTrendService(tweetManager *TweetManager, config TrendConfig) *TrendService {
+
+	trendService := new(TrendService)
+
+	trendService.tweetManager = tweetManager
+	trendService.config = config
+	trendService.windows = make(map[string]*windowTrends)
+
+	for _, window := range config.Windows {
+		trendService.windows[window.Name] = &windowTrends{
+			current:    newDecayingCounter(window.Duration, config),
+			baseline:   newDecayingCounter(time.Duration(float64(window.Duration)*config.BaselineFactor), config),
+			candidates: make(map[string]bool),
+		}
+	}
+
+	tweetManager.Events().SubscribeAsync("trends", trendService.handleEvent, DefaultAsyncSubscriberConfig(), TweetPublished{})
+
+	return trendService
+}
+
+// GetTrends returns up to limit trends of the window, the hottest first
+func (trendService *TrendService) GetTrends(window string, limit int) ([]Trend, error) {
+
+	trendService.mutex.Lock()
+	defer trendService.mutex.Unlock()
+
+	trends, found := trendService.windows[window]
+
+	if !found {
+		return nil, fmt.Errorf("trend window %s does not exist", window)
+	}
+
+	now := trendService.tweetManager.now()
+
+	result := make([]Trend, 0)
+
+	for term := range trends.candidates {
+
+		count := trends.current.estimate(term, now)
+
+		if count < trendService.config.MinCount {
+			continue
+		}
+
+		expected := trends.baseline.estimate(term, now) / trendService.config.BaselineFactor
+
+		result = append(result, Trend{
+			Term:  term,
+			Count: int(math.Floor(count + 0.5)),
+			Score: count / (expected + 1),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Term < result[j].Term
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+func (trendService *TrendService) GetWindows() []string {
+
+	names := make([]string, 0, len(trendService.config.Windows))
+
+	for _, window := range trendService.config.Windows {
+		names = append(names, window.Name)
+	}
+
+	return names
+}
+
+func (trendService *TrendService) handleEvent(event Event) error {
+
+	if published, ok := event.(TweetPublished); ok {
+
+		date := trendService.tweetManager.now()
+		if published.Tweet.GetDate() != nil {
+			date = *published.Tweet.GetDate()
+		}
+
+		trendService.mutex.Lock()
+		defer trendService.mutex.Unlock()
+
+		for _, term := range trendTerms(published.Tweet.GetText()) {
+			for _, trends := range trendService.windows {
+				trends.add(term, date, trendService.config.MaxCandidates)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (trends *windowTrends) add(term string, date time.Time, maxCandidates int) {
+
+	trends.current.add(term, date)
+	trends.baseline.add(term, date)
+
+	if trends.candidates[term] {
+		return
+	}
+
+	if len(trends.candidates) < maxCandidates {
+		trends.candidates[term] = true
+		return
+	}
+
+	weakest := ""
+	weakestCount := math.MaxFloat64
+
+	for candidate := range trends.candidates {
+		if count := trends.current.estimate(candidate, date); count < weakestCount {
+			weakest = candidate
+			weakestCount = count
+		}
+	}
+
+	if trends.current.estimate(term, date) > weakestCount {
+		delete(trends.candidates, weakest)
+		trends.candidates[term] = true
+	}
+}
+
+func newDecayingCounter(lifetime time.Duration, config TrendConfig) *decayingCounter {
+
+	counter := new(decayingCounter)
+
+	counter.lifetime = lifetime
+	counter.sketch = NewCountMinSketch(config.SketchDepth, config.SketchWidth)
+
+	return counter
+}
+
+func (counter *decayingCounter) add(key string, date time.Time) {
+
+	if counter.landmark.IsZero() {
+		counter.landmark = date
+	}
+
+	exponent := counter.exponent(date)
+
+	if exponent > maxDecayExponent {
+		counter.sketch.Scale(math.Exp(-exponent))
+		counter.landmark = date
+		exponent = 0
+	}
+
+	counter.sketch.Add(key, math.Exp(exponent))
+}
+
+func (counter *decayingCounter) estimate(key string, date time.Time) float64 {
+
+	if counter.landmark.IsZero() {
+		return 0
+	}
+
+	return counter.sketch.Estimate(key) * math.Exp(-counter.exponent(date))
+}
+
+func (counter *decayingCounter) exponent(date time.Time) float64 {
+	return date.Sub(counter.landmark).Seconds() / counter.lifetime.Seconds()
+}
+
+// trendTerms returns the hashtags of the text with their # and the words
+// that are long enough and aren't stopwords, each one once
+func trendTerms(text string) []string {
+
+	terms := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, hashtag := range domain.Hashtags(text) {
+		term := "#" + hashtag
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, word := range strings.Fields(text) {
+
+		if strings.HasPrefix(word, "#") || strings.HasPrefix(word, "@") || strings.Contains(word, "://") {
+			continue
+		}
+
+		term := strings.ToLower(strings.TrimFunc(word, func(character rune) bool {
+			return !unicode.IsLetter(character)
+		}))
+
+		if len([]rune(term)) < 4 || stopwords[term] || seen[term] {
+			continue
+		}
+
+		seen[term] = true
+		terms = append(terms, term)
+	}
+
+	return terms
+}
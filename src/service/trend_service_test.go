@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func publishAt(tweetManager *service.TweetManager, user, text string, date time.Time) {
+
+	tweet := domain.NewTextTweet(user, text)
+	tweet.Date = &date
+
+	tweetManager.PublishTweet(context.Background(), tweet)
+}
+
+func TestBurstingHashtagTrendsOverAlwaysPopularOne(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.UTC)
+	tweetManager.SetClock(func() time.Time { return now })
+
+	trendService := service.NewTrendService(tweetManager, service.DefaultTrendConfig())
+
+	for hour := 48; hour > 0; hour-- {
+		for n := 0; n < 10; n++ {
+			publishAt(tweetManager, "nick", "Always #golang", now.Add(-time.Duration(hour)*time.Hour))
+		}
+	}
+
+	// Operation
+	for n := 0; n < 10; n++ {
+		publishAt(tweetManager, "nick", "Always #golang", now.Add(-time.Duration(n)*time.Minute))
+		publishAt(tweetManager, "grupoesfera", "Starting #itacademy", now.Add(-time.Duration(n)*time.Minute))
+	}
+
+	tweetManager.Events().Drain(context.Background())
+
+	trends, err := trendService.GetTrends("hour", 2)
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	if len(trends) != 2 {
+		t.Errorf("Expected size is 2 but was %d", len(trends))
+		return
+	}
+
+	if trends[0].Term != "#itacademy" && trends[0].Term != "starting" {
+		t.Errorf("Expected the new term to trend first but was %s", trends[0].Term)
+	}
+
+	for _, trend := range trends {
+		if trend.Term == "#golang" {
+			t.Errorf("Expected #golang not to trend but had score %v", trend.Score)
+		}
+	}
+}
+
+func TestOldUsagesStopTrending(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers()
+
+	now := time.Date(2017, 11, 3, 12, 0, 0, 0, time.UTC)
+	tweetManager.SetClock(func() time.Time { return now })
+
+	trendService := service.NewTrendService(tweetManager, service.DefaultTrendConfig())
+
+	for n := 0; n < 10; n++ {
+		publishAt(tweetManager, "nick", "#golang", now)
+	}
+
+	tweetManager.Events().Drain(context.Background())
+
+	// Operation
+	now = now.Add(10 * time.Hour)
+
+	trends, _ := trendService.GetTrends("hour", 10)
+
+	// Validation
+	if len(trends) != 0 {
+		t.Errorf("Expected no trends but were %v", trends)
+	}
+}
+
+func TestUnknownTrendWindowFails(t *testing.T) {
+
+	// Initialization
+	trendService := service.NewTrendService(newManagerWithUsers(), service.DefaultTrendConfig())
+
+	// Operation
+	_, err := trendService.GetTrends("week", 10)
+
+	// Validation
+	if err == nil || err.Error() != "trend window week does not exist" {
+		t.Errorf("Expected error is trend window week does not exist but was %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const recordHeaderSize = 8
+
+// maxRecordSize bounds the length read from a header, so a corrupted
+// length isn't taken as a huge record
+const maxRecordSize = 1 << 20
+
+// TweetLog is an append-only file of tweets which survives restarts. Every
+// record is the length and the CRC-32 of its data followed by the tweet
+// encoded as JSON, encrypted when the log has a keyring
+type TweetLog struct {
+	path      string
+	file      *os.File
+	keyring   *Keyring
+	truncated int64
+	mutex     sync.Mutex
+}
+
+// OpenTweetLog opens the log in the path, creating it if it doesn't exist.
+// A torn last record, left by a crash in the middle of a write, is
+// truncated. A damaged record followed by others is an error
+func OpenTweetLog(path string) (*TweetLog, error) {
+	return OpenEncryptedTweetLog(path, nil)
+}
+
+// OpenEncryptedTweetLog opens the log encrypting the new records with the
+// current key of the keyring. Records written with previous keys can still
+// be read, and the ones written before the log was encrypted only while
+// the keyring migrates plain text
+func OpenEncryptedTweetLog(path string, keyring *Keyring) (*TweetLog, error) {
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tweetLog := new(TweetLog)
+
+	tweetLog.path = path
+	tweetLog.file = file
+	tweetLog.keyring = keyring
+
+	size, validSize, err := tweetLog.scan(nil)
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if validSize < size {
+
+		if err := file.Truncate(validSize); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		tweetLog.truncated = size - validSize
+	}
+
+	return tweetLog, nil
+}
+
+func (tweetLog *TweetLog) Truncated() int64 {
+	return tweetLog.truncated
+}
+
+func (tweetLog *TweetLog) WriteTweet(tweet domain.Tweet) error {
+	return tweetLog.writeRecord(newTweetRecord(tweet))
+}
+
+func (tweetLog *TweetLog) WriteDelete(id int) error {
+	return tweetLog.writeRecord(tweetRecord{Operation: deleteOperation, Id: id})
+}
+
+func (tweetLog *TweetLog) WriteRename(oldUser, newUser string, lastId int) error {
+	return tweetLog.writeRecord(tweetRecord{
+		Operation: renameOperation,
+		Id:        lastId,
+		User:      domain.NormalizeHandle(oldUser),
+		NewUser:   newUser,
+	})
+}
+
+func (tweetLog *TweetLog) WriteTweets(tweets []domain.Tweet) error {
+
+	records := make([]tweetRecord, 0, len(tweets))
+
+	for _, tweet := range tweets {
+		records = append(records, newTweetRecord(tweet))
+	}
+
+	return tweetLog.writeRecords(records)
+}
+
+func (tweetLog *TweetLog) writeRecord(record tweetRecord) error {
+	return tweetLog.writeRecords([]tweetRecord{record})
+}
+
+func (tweetLog *TweetLog) writeRecords(records []tweetRecord) error {
+
+	frames := make([]byte, 0)
+
+	for _, record := range records {
+
+		frame, err := encodeRecord(record, tweetLog.keyring)
+
+		if err != nil {
+			return err
+		}
+
+		frames = append(frames, frame...)
+	}
+
+	tweetLog.mutex.Lock()
+	defer tweetLog.mutex.Unlock()
+
+	info, err := tweetLog.file.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tweetLog.file.Write(frames)
+
+	if err == nil {
+		err = tweetLog.file.Sync()
+	}
+
+	// A torn frame would be taken as damage in the middle of the log once
+	// other records are appended after it
+	if err != nil {
+		if truncateErr := tweetLog.file.Truncate(info.Size()); truncateErr != nil {
+			return fmt.Errorf("%s and the log couldn't be truncated: %s", err.Error(), truncateErr.Error())
+		}
+	}
+
+	return err
+}
+
+// Replay saves every tweet of the log that wasn't deleted in the
+// repository, keeping their ids so the quotes between them still point to
+// the right tweets
+func (tweetLog *TweetLog) Replay(repository TweetRepository) (int, error) {
+
+	records, err := tweetLog.readRecords()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return replayRecords(records, repository)
+}
+
+func (tweetLog *TweetLog) readRecords() ([]tweetRecord, error) {
+
+	records := make([]tweetRecord, 0)
+
+	_, _, err := tweetLog.scan(func(data []byte, offset int64) error {
+
+		var record tweetRecord
+
+		if isSealed(data) {
+
+			if tweetLog.keyring == nil {
+				return fmt.Errorf("tweet log %s is encrypted and no encryption key was given", tweetLog.path)
+			}
+
+			opened, err := tweetLog.keyring.open(data)
+
+			if err != nil {
+				return fmt.Errorf("tweet log %s can't be decrypted at offset %d: %s", tweetLog.path, offset, err.Error())
+			}
+
+			data = opened
+
+		} else if !readsPlaintext(tweetLog.keyring) {
+			return fmt.Errorf("tweet log %s has a plain text record at offset %d but it is encrypted", tweetLog.path, offset)
+		}
+
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("tweet log %s has an invalid record at offset %d: %s", tweetLog.path, offset, err.Error())
+		}
+
+		records = append(records, record)
+
+		return nil
+	})
+
+	return records, err
+}
+
+func (tweetLog *TweetLog) Close() error {
+
+	tweetLog.mutex.Lock()
+	defer tweetLog.mutex.Unlock()
+
+	err := tweetLog.file.Sync()
+
+	if closeErr := tweetLog.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// scan reads the records of the log from the start, calling handle with
+// the data of every valid one. It returns the size of the file and the size
+// up to the end of the last valid record
+func (tweetLog *TweetLog) scan(handle func(data []byte, offset int64) error) (int64, int64, error) {
+
+	tweetLog.mutex.Lock()
+	defer tweetLog.mutex.Unlock()
+
+	info, err := tweetLog.file.Stat()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := info.Size()
+	reader := io.NewSectionReader(tweetLog.file, 0, size)
+
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+
+	for offset < size {
+
+		if _, err := reader.ReadAt(header, offset); err != nil {
+			return size, offset, nil
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		checksum := binary.BigEndian.Uint32(header[4:8])
+		end := offset + recordHeaderSize + length
+
+		if length > maxRecordSize || end > size {
+
+			// A torn write only leaves damage at the end of the log
+			if !hasValidRecord(reader, offset+1, size) {
+				return size, offset, nil
+			}
+			return size, offset, fmt.Errorf("tweet log %s is corrupted at offset %d", tweetLog.path, offset)
+		}
+
+		data := make([]byte, length)
+
+		if _, err := reader.ReadAt(data, offset+recordHeaderSize); err != nil {
+			return size, offset, nil
+		}
+
+		if crc32.ChecksumIEEE(data) != checksum {
+			if end == size {
+				return size, offset, nil
+			}
+			return size, offset, fmt.Errorf("tweet log %s is corrupted at offset %d", tweetLog.path, offset)
+		}
+
+		if handle != nil {
+			if err := handle(data, offset); err != nil {
+				return size, offset, err
+			}
+		}
+
+		offset = end
+	}
+
+	return size, offset, nil
+}
+
+// hasValidRecord tells if a valid record starts anywhere between from and
+// the end of the log. Empty records are never written, so the zeros a crash
+// can leave at the end of a file aren't taken as records
+func hasValidRecord(reader *io.SectionReader, from int64, size int64) bool {
+
+	header := make([]byte, recordHeaderSize)
+
+	for offset := from; offset+recordHeaderSize < size; offset++ {
+
+		if _, err := reader.ReadAt(header, offset); err != nil {
+			return false
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+
+		if length == 0 || length > maxRecordSize || offset+recordHeaderSize+length > size {
+			continue
+		}
+
+		data := make([]byte, length)
+
+		if _, err := reader.ReadAt(data, offset+recordHeaderSize); err != nil {
+			continue
+		}
+
+		if crc32.ChecksumIEEE(data) == binary.BigEndian.Uint32(header[4:8]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeRecord(record tweetRecord, keyring *Keyring) ([]byte, error) {
+
+	data, err := json.Marshal(record)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring != nil {
+		data = keyring.seal(data)
+	}
+
+	frame := make([]byte, recordHeaderSize+len(data))
+
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(data))
+	copy(frame[recordHeaderSize:], data)
+
+	return frame, nil
+}
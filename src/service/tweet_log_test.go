@@ -0,0 +1,262 @@
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func tempLogPath(t *testing.T) (string, func()) {
+
+	directory, err := ioutil.TempDir("", "tweetlog")
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	return filepath.Join(directory, "tweets.log"), func() { os.RemoveAll(directory) }
+}
+
+func openLog(t *testing.T, path string) *service.TweetLog {
+
+	tweetLog, err := service.OpenTweetLog(path)
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening the log: %s", err.Error())
+	}
+
+	return tweetLog
+}
+
+// publishToLog publishes the tweets with a manager writing to the log and
+// waits for them to be written
+func publishToLog(t *testing.T, tweetLog *service.TweetLog, repository service.TweetRepository, tweets ...domain.Tweet) {
+
+	tweetManager := service.NewTweetManager(repository, service.NewChannelTweetWriter(tweetLog))
+
+	for _, tweet := range tweets {
+
+		ctx := context.Background()
+
+		if _, err := tweetManager.PublishTweetAndWait(ctx, tweet); err != nil {
+			t.Fatalf("Unexpected error publishing: %s", err.Error())
+		}
+	}
+}
+
+func replayLog(t *testing.T, tweetLog *service.TweetLog) *service.MemoryTweetRepository {
+
+	repository := service.NewMemoryTweetRepository()
+
+	if _, err := tweetLog.Replay(repository); err != nil {
+		t.Fatalf("Unexpected error replaying the log: %s", err.Error())
+	}
+
+	return repository
+}
+
+func TestTweetLogIsReplayedAfterRestart(t *testing.T) {
+
+	// Initialization
+	path, remove := tempLogPath(t)
+	defer remove()
+
+	tweetLog := openLog(t, path)
+
+	text := domain.NewTextTweet("grupoesfera", "First")
+	image := domain.NewImageTweet("nick", "Look", "http://www.grupoesfera.com.ar/common/img/grupoesfera.png")
+	quote := domain.NewQuoteTweet("nick", "Nice", text)
+
+	publishToLog(t, tweetLog, service.NewMemoryTweetRepository(), text, image, quote)
+	tweetLog.Close()
+
+	// Operation
+	tweetLog = openLog(t, path)
+	defer tweetLog.Close()
+
+	repository := replayLog(t, tweetLog)
+	tweetManager := service.NewTweetManager(repository, service.NewChannelTweetWriter(tweetLog))
+
+	// Validation
+	if len(tweetManager.GetTweets()) != 3 {
+		t.Fatalf("Expected 3 tweets but were %d", len(tweetManager.GetTweets()))
+	}
+
+	replayedImage, ok := tweetManager.GetTweetById(2).(*domain.ImageTweet)
+
+	if !ok || replayedImage.URL != image.URL || !replayedImage.Date.Equal(*image.Date) {
+		t.Errorf("Expected the image tweet to be replayed but was %v", tweetManager.GetTweetById(2))
+	}
+
+	replayedQuote, ok := tweetManager.GetTweetById(3).(*domain.QuoteTweet)
+
+	if !ok || replayedQuote.QuotedTweet != tweetManager.GetTweetById(1) {
+		t.Errorf("Expected the quote to point to the replayed tweet")
+	}
+
+	if count := tweetManager.CountTweetsByUser("nick"); count != 2 {
+		t.Errorf("Expected 2 tweets of nick but were %d", count)
+	}
+
+	id, _ := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "After restart"))
+
+	if id != 4 {
+		t.Errorf("Expected the next id to be 4 but was %d", id)
+	}
+}
+
+func TestTweetLogTruncatesATornLastRecord(t *testing.T) {
+
+	// Initialization
+	path, remove := tempLogPath(t)
+	defer remove()
+
+	tweetLog := openLog(t, path)
+	publishToLog(t, tweetLog, service.NewMemoryTweetRepository(), domain.NewTextTweet("grupoesfera", "First"),
+		domain.NewTextTweet("grupoesfera", "Second"))
+	tweetLog.Close()
+
+	info, _ := os.Stat(path)
+	validSize := info.Size()
+
+	tweetLog = openLog(t, path)
+	publishToLog(t, tweetLog, replayLog(t, tweetLog), domain.NewTextTweet("grupoesfera", "Torn"))
+	tweetLog.Close()
+
+	info, _ = os.Stat(path)
+	os.Truncate(path, validSize+(info.Size()-validSize)/2)
+
+	// Operation
+	tweetLog = openLog(t, path)
+	defer tweetLog.Close()
+
+	// Validation
+	info, _ = os.Stat(path)
+
+	if info.Size() != validSize || tweetLog.Truncated() == 0 {
+		t.Errorf("Expected the log to be truncated to %d bytes but was %d", validSize, info.Size())
+	}
+
+	repository := replayLog(t, tweetLog)
+
+	if repository.Count() != 2 {
+		t.Fatalf("Expected 2 tweets but were %d", repository.Count())
+	}
+
+	publishToLog(t, tweetLog, repository, domain.NewTextTweet("grupoesfera", "Third"))
+
+	if count := replayLog(t, tweetLog).Count(); count != 3 {
+		t.Errorf("Expected the tweets written after the truncation to be replayed but were %d", count)
+	}
+}
+
+func TestTweetLogTruncatesALastRecordWithWrongChecksum(t *testing.T) {
+
+	// Initialization
+	path, remove := tempLogPath(t)
+	defer remove()
+
+	tweetLog := openLog(t, path)
+	publishToLog(t, tweetLog, service.NewMemoryTweetRepository(), domain.NewTextTweet("grupoesfera", "First"))
+	tweetLog.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	data[len(data)-2] ^= 0xff
+	ioutil.WriteFile(path, data, 0666)
+
+	// Operation
+	tweetLog = openLog(t, path)
+	defer tweetLog.Close()
+
+	// Validation
+	if count := replayLog(t, tweetLog).Count(); count != 0 || tweetLog.Truncated() != int64(len(data)) {
+		t.Errorf("Expected the damaged record to be dropped but %d tweets were replayed", count)
+	}
+}
+
+func TestTweetLogFailsWithACorruptedRecordInTheMiddle(t *testing.T) {
+
+	// Initialization
+	path, remove := tempLogPath(t)
+	defer remove()
+
+	tweetLog := openLog(t, path)
+	publishToLog(t, tweetLog, service.NewMemoryTweetRepository(), domain.NewTextTweet("grupoesfera", "First"),
+		domain.NewTextTweet("grupoesfera", "Second"))
+	tweetLog.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	data[10] ^= 0xff
+	ioutil.WriteFile(path, data, 0666)
+
+	// Operation
+	_, err := service.OpenTweetLog(path)
+
+	// Validation
+	if err == nil || err.Error() != "tweet log "+path+" is corrupted at offset 0" {
+		t.Errorf("Expected a corruption error but was %v", err)
+	}
+
+	if after, _ := ioutil.ReadFile(path); len(after) != len(data) {
+		t.Errorf("Expected the corrupted log not to be truncated")
+	}
+}
+
+func TestTweetLogFailsWithACorruptedLengthInTheMiddle(t *testing.T) {
+
+	// Initialization
+	path, remove := tempLogPath(t)
+	defer remove()
+
+	tweetLog := openLog(t, path)
+	publishToLog(t, tweetLog, service.NewMemoryTweetRepository(), domain.NewTextTweet("grupoesfera", "First"),
+		domain.NewTextTweet("grupoesfera", "Second"))
+	tweetLog.Close()
+
+	// The length of the first record goes past the end of the log
+	data, _ := ioutil.ReadFile(path)
+	data[0] = 0xff
+	ioutil.WriteFile(path, data, 0666)
+
+	// Operation
+	_, err := service.OpenTweetLog(path)
+
+	// Validation
+	if err == nil || err.Error() != "tweet log "+path+" is corrupted at offset 0" {
+		t.Errorf("Expected a corruption error but was %v", err)
+	}
+
+	if after, _ := ioutil.ReadFile(path); len(after) != len(data) {
+		t.Errorf("Expected the corrupted log not to be truncated")
+	}
+}
+
+func TestTweetLogKeepsTheUserAndTextOfDetachedQuotedTweets(t *testing.T) {
+
+	// Initialization
+	path, remove := tempLogPath(t)
+	defer remove()
+
+	tweetLog := openLog(t, path)
+
+	// The quoted tweet was never published, like one imported from an archive
+	detached := domain.NewTextTweet("nick", "Learning Go")
+	publishToLog(t, tweetLog, service.NewMemoryTweetRepository(), domain.NewQuoteTweet("grupoesfera", "Me too", detached))
+	tweetLog.Close()
+
+	// Operation
+	repository := replayLog(t, openLog(t, path))
+
+	// Validation
+	tweet, _ := repository.Get(1)
+	quote, ok := tweet.(*domain.QuoteTweet)
+
+	if !ok || quote.QuotedTweet == nil || quote.QuotedTweet.GetUser() != "nick" || quote.QuotedTweet.GetText() != "Learning Go" {
+		t.Errorf("Expected the detached quoted tweet to be kept but was %v", tweet)
+	}
+}
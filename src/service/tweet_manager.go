@@ -1,86 +1,272 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/cursoGo/src/domain"
 )
 
+// TweetManager is safe for concurrent use. Its state is guarded by mutex and
+// the events of every change are published in its EventBus after the
+// change, once the mutex is released
 type TweetManager struct {
-	tweets             []domain.Tweet
-	tweetsByUser       map[string][]domain.Tweet
+	mutex              sync.RWMutex
+	repository         TweetRepository
+	tweetsByDay        *timeIndex
+	tweetsByUserDay    map[string]*timeIndex
+	usersBySkeleton    map[string]string
+	renames            []userRename
+	following          map[string]map[string]bool
+	likes              map[int]map[string]bool
+	events             *EventBus
+	pendingEvents      []Event
+	eventTurn          *sync.Cond
+	nextEvent          uint64
+	deliveredEvents    uint64
+	renameGracePeriod  time.Duration
+	clock              func() time.Time
 	channelTweetWriter *ChannelTweetWriter
+	rateLimiter        *RateLimiter
 }
 
-func NewTweetManager(channelTweetWriter *ChannelTweetWriter) *TweetManager {
+func NewTweetManager(repository TweetRepository, channelTweetWriter *ChannelTweetWriter) *TweetManager {
 
 	tweetManager := new(TweetManager)
 
-	tweetManager.tweets = make([]domain.Tweet, 0)
-	tweetManager.tweetsByUser = make(map[string][]domain.Tweet)
+	tweetManager.repository = repository
+	tweetManager.tweetsByDay = newTimeIndex()
+	tweetManager.tweetsByUserDay = make(map[string]*timeIndex)
+	tweetManager.usersBySkeleton = make(map[string]string)
+	tweetManager.renames = make([]userRename, 0)
+	tweetManager.following = make(map[string]map[string]bool)
+	tweetManager.likes = make(map[int]map[string]bool)
+	tweetManager.events = NewEventBus()
+	tweetManager.events.resolveUser = tweetManager.ResolveUser
+	tweetManager.eventTurn = sync.NewCond(new(sync.Mutex))
+	tweetManager.renameGracePeriod = DefaultRenameGracePeriod
+	tweetManager.clock = time.Now
 	tweetManager.channelTweetWriter = channelTweetWriter
 
+	for _, tweet := range repository.List() {
+		if user, err := tweetManager.registerUser(tweet.GetUser()); err == nil {
+			tweetManager.indexTweet(user, tweet)
+		}
+	}
+
 	return tweetManager
 }
 
-func (manager *TweetManager) PublishTweet(tweetToPublish domain.Tweet, quit chan bool) (int, error) {
+func (manager *TweetManager) reindex() {
+
+	manager.tweetsByDay = newTimeIndex()
+	manager.tweetsByUserDay = make(map[string]*timeIndex)
+
+	for _, tweet := range manager.repository.List() {
+		manager.indexTweet(domain.NormalizeHandle(tweet.GetUser()), tweet)
+	}
+}
+
+// PublishTweet saves the tweet and queues it to be written, without
+// waiting for the write. It fails with the error of the context if it is
+// done while waiting for room in the queue
+func (manager *TweetManager) PublishTweet(ctx context.Context, tweetToPublish domain.Tweet) (int, error) {
+
+	id, _, err := manager.publishLimitedTweet(ctx, tweetToPublish)
+
+	return id, err
+}
+
+// PublishTweetAndWait publishes the tweet and waits for it to be written.
+// If the context is done first the tweet stays published, and is still
+// written, but the error of the context is returned with its id
+func (manager *TweetManager) PublishTweetAndWait(ctx context.Context, tweetToPublish domain.Tweet) (int, error) {
+
+	id, ack, err := manager.publishLimitedTweet(ctx, tweetToPublish)
+
+	if err != nil {
+		return id, err
+	}
+
+	return id, ack.Wait(ctx)
+}
+
+// SetRateLimiter limits the tweets published with a context that has a
+// PublishSource. A nil limiter removes the limits
+func (manager *TweetManager) SetRateLimiter(limiter *RateLimiter) {
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.rateLimiter = limiter
+}
+
+// publishLimitedTweet publishes the tweet if the limits of the source of
+// the context allow it. It fails with a *RateLimitError otherwise
+func (manager *TweetManager) publishLimitedTweet(ctx context.Context, tweetToPublish domain.Tweet) (int, *WriteAck, error) {
+
+	limiter, source := manager.limiterOf(ctx)
 
-	if tweetToPublish.GetUser() == "" {
-		return 0, fmt.Errorf("user is required")
+	if limiter == nil {
+		return manager.publishTweet(ctx, tweetToPublish)
 	}
 
-	if tweetToPublish.GetText() == "" {
-		return 0, fmt.Errorf("text is required")
+	// Invalid tweets are rejected before taking anything from the limits
+	if err := validateTweet(tweetToPublish); err != nil {
+		return 0, nil, err
 	}
 
-	if len(tweetToPublish.GetText()) > 140 {
-		return 0, fmt.Errorf("text exceeds 140 characters")
+	user := manager.ResolveUser(tweetToPublish.GetUser())
+
+	if err := limiter.Allow(source, user); err != nil {
+		return 0, nil, err
 	}
 
-	manager.tweets = append(manager.tweets, tweetToPublish)
+	id, ack, err := manager.publishTweet(ctx, tweetToPublish)
+
+	if err != nil {
+		limiter.refund(source, user, 1)
+	}
+
+	return id, ack, err
+}
+
+func (manager *TweetManager) limiterOf(ctx context.Context) (*RateLimiter, PublishSource) {
+
+	manager.mutex.RLock()
+	limiter := manager.rateLimiter
+	manager.mutex.RUnlock()
 
-	tweetToPublish.SetId(len(manager.tweets))
+	source, limited := ctx.Value(publishSourceKey{}).(PublishSource)
 
-	userTweets := manager.tweetsByUser[tweetToPublish.GetUser()]
-	manager.tweetsByUser[tweetToPublish.GetUser()] = append(userTweets, tweetToPublish)
+	if !limited {
+		return nil, source
+	}
 
-	tweetsToWrite := make(chan domain.Tweet)
+	return limiter, source
+}
+
+func validateTweet(tweet domain.Tweet) error {
+
+	if tweet.GetUser() == "" {
+		return fmt.Errorf("user is required")
+	}
 
-	go manager.channelTweetWriter.WriteTweet(tweetsToWrite, quit)
+	if err := domain.ValidateHandle(tweet.GetUser()); err != nil {
+		return err
+	}
 
-	tweetsToWrite <- tweetToPublish
+	if tweet.GetText() == "" {
+		return fmt.Errorf("text is required")
+	}
 
-	close(tweetsToWrite)
+	if len(tweet.GetText()) > 140 {
+		return fmt.Errorf("text exceeds 140 characters")
+	}
 
-	return tweetToPublish.GetId(), nil
+	return nil
+}
+
+func (manager *TweetManager) publishTweet(ctx context.Context, tweetToPublish domain.Tweet) (int, *WriteAck, error) {
+
+	if err := validateTweet(tweetToPublish); err != nil {
+		return 0, nil, err
+	}
+
+	// Room in the queue is taken first, so a tweet that can't be written is
+	// never seen
+	slot, err := manager.channelTweetWriter.reserve(ctx)
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	manager.lock()
+
+	user, err := manager.registerUser(tweetToPublish.GetUser())
+
+	if err != nil {
+		slot.cancel()
+		manager.unlock()
+		return 0, nil, err
+	}
+
+	tweetToPublish.SetId(0)
+
+	id, err := manager.repository.Save(tweetToPublish)
+
+	if err != nil {
+		slot.cancel()
+		manager.unlock()
+		return 0, nil, err
+	}
+
+	manager.indexTweet(user, tweetToPublish)
+
+	manager.publishEvent(TweetPublished{tweetToPublish})
+
+	if quoteTweet, ok := tweetToPublish.(*domain.QuoteTweet); ok && quoteTweet.QuotedTweet != nil {
+		manager.publishEvent(TweetQuoted{tweetToPublish, quoteTweet.QuotedTweet})
+	}
+
+	mentioned := make(map[string]bool)
+
+	for index, mention := range manager.getMentions(tweetToPublish) {
+		if !mentioned[mention] {
+			mentioned[mention] = true
+			reply := index == 0 && domain.RepliedUser(tweetToPublish.GetText()) != ""
+			manager.publishEvent(UserMentioned{mention, tweetToPublish, reply})
+		}
+	}
+
+	// The tweet is sent in the turn of its events, so tweets are written in
+	// the order of their ids
+	var ack *WriteAck
+
+	manager.unlockAfter(func() {
+		ack = slot.send(tweetToPublish)
+	})
+
+	return id, ack, nil
 }
 
 // GetTweet returns the last published tweet
 func (manager *TweetManager) GetTweet() domain.Tweet {
-	lastTweetIndex := len(manager.tweets) - 1
 
-	return manager.tweets[lastTweetIndex]
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	tweets := manager.repository.List()
+
+	return tweets[len(tweets)-1]
 }
 
+// GetTweets returns all the tweets sorted by id. The slice is a copy, so it
+// can be kept while other tweets are published
 func (manager *TweetManager) GetTweets() []domain.Tweet {
-	return manager.tweets
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.repository.List()
 }
 
 func (manager *TweetManager) GetTweetById(id int) domain.Tweet {
 
-	var tweet domain.Tweet
-
-	tweetIndex := 0
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
 
-	for tweetIndex < len(manager.tweets) && tweet == nil {
+	return manager.tweetById(id)
+}
 
-		actualTweet := manager.tweets[tweetIndex]
+func (manager *TweetManager) tweetById(id int) domain.Tweet {
 
-		if actualTweet.GetId() == id {
-			tweet = actualTweet
-		}
+	tweet, err := manager.repository.Get(id)
 
-		tweetIndex++
+	if err != nil {
+		return nil
 	}
 
 	return tweet
@@ -88,18 +274,95 @@ func (manager *TweetManager) GetTweetById(id int) domain.Tweet {
 
 func (manager *TweetManager) CountTweetsByUser(user string) int {
 
-	var count int
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
 
-	for _, tweet := range manager.tweets {
-		if tweet.GetUser() == user {
-			count++
-		}
+	return manager.repository.CountByUser(manager.resolveUser(user))
+}
+
+func (manager *TweetManager) GetTweetsByUser(user string) []domain.Tweet {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.repository.ListByUser(manager.resolveUser(user))
+}
+
+func (manager *TweetManager) DeleteTweet(user string, id int) error {
+
+	manager.lock()
+	defer manager.unlock()
+
+	tweet := manager.tweetById(id)
+
+	if tweet == nil {
+		return fmt.Errorf("tweet %d does not exist", id)
 	}
 
-	return count
+	handle := domain.NormalizeHandle(tweet.GetUser())
+
+	if handle != manager.resolveUser(user) {
+		return fmt.Errorf("tweet %d is not from user %s", id, user)
+	}
+
+	if err := manager.repository.Delete(id); err != nil {
+		return err
+	}
+
+	manager.tweetsByDay.remove(tweet)
+	manager.tweetsByUserDay[handle].remove(tweet)
+
+	delete(manager.likes, id)
+
+	manager.publishEvent(TweetDeleted{tweet})
+
+	return nil
 }
 
-func (manager *TweetManager) GetTweetsByUser(user string) []domain.Tweet {
+func (manager *TweetManager) SetClock(clock func() time.Time) {
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.clock = clock
+}
+
+func (manager *TweetManager) now() time.Time {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.clock()
+}
+
+func (manager *TweetManager) indexTweet(user string, tweet domain.Tweet) {
+
+	if manager.tweetsByUserDay[user] == nil {
+		manager.tweetsByUserDay[user] = newTimeIndex()
+	}
+
+	manager.tweetsByDay.add(tweet)
+	manager.tweetsByUserDay[user].add(tweet)
+}
+
+// registerUser returns the normalized handle of the user, failing if it
+// can be confused with the handle of another user
+func (manager *TweetManager) registerUser(user string) (string, error) {
+
+	handle := domain.NormalizeHandle(user)
+	skeleton := domain.HandleSkeleton(handle)
+
+	if manager.isRedirected(handle) {
+		return "", fmt.Errorf("user %s has been renamed to %s", user, manager.resolveUser(handle))
+	}
+
+	registeredHandle, registered := manager.usersBySkeleton[skeleton]
+
+	if registered && registeredHandle != handle {
+		return "", fmt.Errorf("user %s is too similar to existing user %s", user, registeredHandle)
+	}
+
+	manager.usersBySkeleton[skeleton] = handle
 
-	return manager.tweetsByUser[user]
+	return handle, nil
 }
@@ -0,0 +1,269 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+const concurrentPublishers = 500
+
+// readUntilDone calls read until the writers are done
+func readUntilDone(writers *sync.WaitGroup, read func()) {
+
+	done := make(chan bool)
+
+	go func() {
+		writers.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			read()
+		}
+	}
+}
+
+func TestConcurrentPublishesGetUniqueIds(t *testing.T) {
+
+	// Initialization
+	writer := service.NewMemoryTweetWriter()
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(writer))
+
+	var publishers, readers sync.WaitGroup
+
+	ids := make(chan int, concurrentPublishers)
+
+	// Operation
+	for reader := 0; reader < 10; reader++ {
+		readers.Add(1)
+		go func(reader int) {
+			defer readers.Done()
+			for read := 0; read < 50; read++ {
+				tweetManager.GetTweets()
+				tweetManager.GetTweetsByUser(fmt.Sprintf("user%d", reader))
+				tweetManager.GetTweetsPage("", 10)
+				tweetManager.CountTweetsByUser("user0")
+			}
+		}(reader)
+	}
+
+	for publisher := 0; publisher < concurrentPublishers; publisher++ {
+		publishers.Add(1)
+		go func(publisher int) {
+			defer publishers.Done()
+
+			ctx := context.Background()
+			user := fmt.Sprintf("user%d", publisher%10)
+
+			id, err := tweetManager.PublishTweetAndWait(ctx, domain.NewTextTweet(user, fmt.Sprintf("Tweet %d", publisher)))
+
+			if err != nil {
+				t.Errorf("Unexpected error publishing: %s", err.Error())
+				return
+			}
+			ids <- id
+		}(publisher)
+	}
+
+	publishers.Wait()
+	readers.Wait()
+	close(ids)
+
+	// Validation
+	seen := make(map[int]bool)
+
+	for id := range ids {
+		if seen[id] || id < 1 || id > concurrentPublishers {
+			t.Errorf("Expected unique ids from 1 to %d but got %d twice or out of range", concurrentPublishers, id)
+		}
+		seen[id] = true
+	}
+
+	if tweets := tweetManager.GetTweets(); len(tweets) != concurrentPublishers {
+		t.Errorf("Expected %d tweets but were %d", concurrentPublishers, len(tweets))
+	}
+
+	if count := tweetManager.CountTweetsByUser("user3"); count != concurrentPublishers/10 {
+		t.Errorf("Expected %d tweets of user3 but were %d", concurrentPublishers/10, count)
+	}
+
+	if len(writer.Tweets) != concurrentPublishers {
+		t.Errorf("Expected %d written tweets but were %d", concurrentPublishers, len(writer.Tweets))
+	}
+}
+
+func TestConcurrentRenamesAndDeletesDontRaceWithReaders(t *testing.T) {
+
+	// Initialization
+	renamedUsers := make([]string, 0, 10)
+	for index := 0; index < 10; index++ {
+		renamedUsers = append(renamedUsers, fmt.Sprintf("old%d", index))
+	}
+
+	tweetManager := newManagerWithUsers(renamedUsers...)
+
+	var workers sync.WaitGroup
+
+	// Operation
+	for reader := 0; reader < 10; reader++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for read := 0; read < 50; read++ {
+				for _, tweet := range tweetManager.GetTweets() {
+					tweet.GetUser()
+					tweet.PrintableTweet()
+				}
+				tweetManager.GetTweetsByUser("user0")
+				tweetManager.GetTweetsBetween(time.Time{}, time.Now().Add(time.Hour))
+			}
+		}()
+	}
+
+	for publisher := 0; publisher < concurrentPublishers; publisher++ {
+		workers.Add(1)
+		go func(publisher int) {
+			defer workers.Done()
+
+			ctx := context.Background()
+			user := fmt.Sprintf("user%d", publisher%10)
+
+			id, err := tweetManager.PublishTweetAndWait(ctx, domain.NewTextTweet(user, fmt.Sprintf("Tweet %d", publisher)))
+
+			if err == nil && publisher%5 == 0 {
+				tweetManager.DeleteTweet(user, id)
+			}
+		}(publisher)
+	}
+
+	for index, user := range renamedUsers {
+		workers.Add(1)
+		go func(index int, user string) {
+			defer workers.Done()
+			if err := tweetManager.RenameUser(user, fmt.Sprintf("new%d", index)); err != nil {
+				t.Errorf("Unexpected error renaming: %s", err.Error())
+			}
+		}(index, user)
+	}
+
+	workers.Wait()
+
+	// Validation
+	expected := len(renamedUsers) + concurrentPublishers - concurrentPublishers/5
+
+	if tweets := tweetManager.GetTweets(); len(tweets) != expected {
+		t.Errorf("Expected %d tweets but were %d", expected, len(tweets))
+	}
+
+	for index := range renamedUsers {
+		if tweets := tweetManager.GetTweetsByUser(fmt.Sprintf("new%d", index)); len(tweets) != 1 || tweets[0].GetUser() != fmt.Sprintf("new%d", index) {
+			t.Errorf("Expected the tweet of old%d to be renamed but the tweets were %v", index, tweets)
+		}
+	}
+}
+
+func TestListenersReceiveConcurrentEventsInOrder(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	received := make([]int, 0, concurrentPublishers)
+
+	tweetManager.Subscribe(func(event service.Event) {
+		if published, ok := event.(service.TweetPublished); ok {
+			// Listeners can read from the manager
+			tweetManager.GetTweetById(published.Tweet.GetId())
+			received = append(received, published.Tweet.GetId())
+		}
+	})
+
+	var publishers sync.WaitGroup
+
+	// Operation
+	for publisher := 0; publisher < concurrentPublishers; publisher++ {
+		publishers.Add(1)
+		go func(publisher int) {
+			defer publishers.Done()
+			tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", fmt.Sprintf("Tweet %d", publisher)))
+		}(publisher)
+	}
+
+	publishers.Wait()
+
+	// Validation
+	if len(received) != concurrentPublishers {
+		t.Fatalf("Expected %d events but were %d", concurrentPublishers, len(received))
+	}
+
+	for index, id := range received {
+		if id != index+1 {
+			t.Fatalf("Expected event %d to be of tweet %d but was of tweet %d", index, index+1, id)
+		}
+	}
+}
+
+func TestConcurrentLikesAndFollowsAreAllKept(t *testing.T) {
+
+	// Initialization
+	users := make([]string, 0, concurrentPublishers)
+	for index := 0; index < concurrentPublishers; index++ {
+		users = append(users, fmt.Sprintf("fan%d", index))
+	}
+
+	tweetManager := newManagerWithUsers(append(users, "grupoesfera")...)
+
+	id, _ := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "Like me"))
+
+	var fans sync.WaitGroup
+
+	// Operation
+	for _, user := range users {
+		fans.Add(1)
+		go func(user string) {
+			defer fans.Done()
+			tweetManager.LikeTweet(user, id)
+			tweetManager.Follow(user, "grupoesfera")
+			tweetManager.GetLikes(id)
+			tweetManager.GetFollowers("grupoesfera")
+		}(user)
+	}
+
+	fans.Wait()
+
+	// Validation
+	if likes := tweetManager.GetLikes(id); len(likes) != concurrentPublishers {
+		t.Errorf("Expected %d likes but were %d", concurrentPublishers, len(likes))
+	}
+
+	if followers := tweetManager.GetFollowers("grupoesfera"); len(followers) != concurrentPublishers {
+		t.Errorf("Expected %d followers but were %d", concurrentPublishers, len(followers))
+	}
+}
+
+func TestReturnedTweetsAreCopies(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("grupoesfera", "nick")
+
+	// Operation
+	tweets := tweetManager.GetTweets()
+	tweets[0] = nil
+
+	userTweets := tweetManager.GetTweetsByUser("nick")
+	userTweets[0] = nil
+
+	// Validation
+	if tweetManager.GetTweets()[0] == nil || tweetManager.GetTweetsByUser("nick")[0] == nil {
+		t.Errorf("Expected changing the returned tweets not to change the manager")
+	}
+}
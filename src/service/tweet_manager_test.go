@@ -1,6 +1,7 @@
 package service_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/cursoGo/src/domain"
@@ -13,7 +14,7 @@ func TestPublishedTweetIsSaved(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet domain.Tweet
 
@@ -22,18 +23,16 @@ func TestPublishedTweetIsSaved(t *testing.T) {
 
 	tweet = domain.NewTextTweet(user, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
 	// Operation
-	id, _ := tweetManager.PublishTweet(tweet, quit)
+	id, _ := tweetManager.PublishTweetAndWait(ctx, tweet)
 
 	// Validation
 	publishedTweet := tweetManager.GetTweet()
 
 	isValidTweet(t, publishedTweet, id, user, text)
 
-	<-quit
-
 	if memoryTweetWriter.Tweets[0] != tweet {
 		t.Errorf("A tweet in the writer was expected")
 	}
@@ -46,7 +45,7 @@ func TestTweetWithoutUserIsNotPublished(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet domain.Tweet
 
@@ -55,11 +54,11 @@ func TestTweetWithoutUserIsNotPublished(t *testing.T) {
 
 	tweet = domain.NewTextTweet(user, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
 	// Operation
 	var err error
-	_, err = tweetManager.PublishTweet(tweet, quit)
+	_, err = tweetManager.PublishTweet(ctx, tweet)
 
 	// Validation
 	if err != nil && err.Error() != "user is required" {
@@ -73,7 +72,7 @@ func TestTweetWithoutTextIsNotPublished(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet domain.Tweet
 
@@ -82,11 +81,11 @@ func TestTweetWithoutTextIsNotPublished(t *testing.T) {
 
 	tweet = domain.NewTextTweet(user, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
 	// Operation
 	var err error
-	_, err = tweetManager.PublishTweet(tweet, quit)
+	_, err = tweetManager.PublishTweet(ctx, tweet)
 
 	// Validation
 	if err == nil {
@@ -105,7 +104,7 @@ func TestTweetWhichExceeding140CharactersIsNotPublished(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet domain.Tweet
 
@@ -116,11 +115,11 @@ func TestTweetWhichExceeding140CharactersIsNotPublished(t *testing.T) {
 
 	tweet = domain.NewTextTweet(user, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
 	// Operation
 	var err error
-	_, err = tweetManager.PublishTweet(tweet, quit)
+	_, err = tweetManager.PublishTweet(ctx, tweet)
 
 	// Validation
 	if err == nil {
@@ -138,7 +137,7 @@ func TestCanPublishAndRetrieveMoreThanOneTweet(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet, secondTweet domain.Tweet
 
@@ -149,11 +148,11 @@ func TestCanPublishAndRetrieveMoreThanOneTweet(t *testing.T) {
 	tweet = domain.NewTextTweet(user, text)
 	secondTweet = domain.NewTextTweet(user, secondText)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
 	// Operation
-	firstId, _ := tweetManager.PublishTweet(tweet, quit)
-	secondId, _ := tweetManager.PublishTweet(secondTweet, quit)
+	firstId, _ := tweetManager.PublishTweet(ctx, tweet)
+	secondId, _ := tweetManager.PublishTweet(ctx, secondTweet)
 
 	// Validation
 	publishedTweets := tweetManager.GetTweets()
@@ -183,7 +182,7 @@ func TestCanRetrieveTweetById(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet domain.Tweet
 	var id int
@@ -193,10 +192,10 @@ func TestCanRetrieveTweetById(t *testing.T) {
 
 	tweet = domain.NewTextTweet(user, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
 	// Operation
-	id, _ = tweetManager.PublishTweet(tweet, quit)
+	id, _ = tweetManager.PublishTweet(ctx, tweet)
 
 	// Validation
 	publishedTweet := tweetManager.GetTweetById(id)
@@ -210,7 +209,7 @@ func TestCanCountTheTweetsSentByAnUser(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet, secondTweet, thirdTweet domain.Tweet
 
@@ -223,11 +222,11 @@ func TestCanCountTheTweetsSentByAnUser(t *testing.T) {
 	secondTweet = domain.NewTextTweet(user, secondText)
 	thirdTweet = domain.NewTextTweet(anotherUser, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
-	tweetManager.PublishTweet(tweet, quit)
-	tweetManager.PublishTweet(secondTweet, quit)
-	tweetManager.PublishTweet(thirdTweet, quit)
+	tweetManager.PublishTweet(ctx, tweet)
+	tweetManager.PublishTweet(ctx, secondTweet)
+	tweetManager.PublishTweet(ctx, thirdTweet)
 
 	// Operation
 	count := tweetManager.CountTweetsByUser(user)
@@ -245,7 +244,7 @@ func TestCanRetrieveTheTweetsSentByAnUser(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
 
 	var tweet, secondTweet, thirdTweet domain.Tweet
 
@@ -258,11 +257,11 @@ func TestCanRetrieveTheTweetsSentByAnUser(t *testing.T) {
 	secondTweet = domain.NewTextTweet(user, secondText)
 	thirdTweet = domain.NewTextTweet(anotherUser, text)
 
-	quit := make(chan bool)
+	ctx := context.Background()
 
-	firstId, _ := tweetManager.PublishTweet(tweet, quit)
-	secondId, _ := tweetManager.PublishTweet(secondTweet, quit)
-	tweetManager.PublishTweet(thirdTweet, quit)
+	firstId, _ := tweetManager.PublishTweet(ctx, tweet)
+	secondId, _ := tweetManager.PublishTweet(ctx, secondTweet)
+	tweetManager.PublishTweet(ctx, thirdTweet)
 
 	// Operation
 	tweets := tweetManager.GetTweetsByUser(user)
@@ -307,3 +306,151 @@ func isValidTweet(t *testing.T, tweet domain.Tweet, id int, user, text string) b
 	return true
 
 }
+
+func TestTweetsOfAnUserAreRetrievedIgnoringCase(t *testing.T) {
+
+	// Initialization
+	memoryTweetWriter := service.NewMemoryTweetWriter()
+	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	tweet := domain.NewTextTweet("Nick", "This is my first tweet")
+	secondTweet := domain.NewTextTweet("nick", "This is my second tweet")
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, tweet)
+	tweetManager.PublishTweet(ctx, secondTweet)
+
+	// Operation
+	tweets := tweetManager.GetTweetsByUser("NICK")
+	count := tweetManager.CountTweetsByUser("@nick")
+
+	// Validation
+	if len(tweets) != 2 {
+		t.Errorf("Expected size is 2 but was %d", len(tweets))
+	}
+
+	if count != 2 {
+		t.Errorf("Expected count is 2 but was %d", count)
+	}
+}
+
+func TestTweetWithInvalidUserIsNotPublished(t *testing.T) {
+
+	// Initialization
+	memoryTweetWriter := service.NewMemoryTweetWriter()
+	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	tweet := domain.NewTextTweet("grupo esfera", "This is my first tweet")
+
+	ctx := context.Background()
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, tweet)
+
+	// Validation
+	if err == nil {
+		t.Error("Expected error")
+		return
+	}
+
+	if err.Error() != "user can only contain letters, digits and underscores" {
+		t.Errorf("Unexpected error %s", err)
+	}
+}
+
+func TestTweetFromAnUserSimilarToAnotherIsNotPublished(t *testing.T) {
+
+	// Initialization
+	memoryTweetWriter := service.NewMemoryTweetWriter()
+	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("n1ck", "I am nick"))
+
+	// Validation
+	if err == nil {
+		t.Error("Expected error")
+		return
+	}
+
+	if err.Error() != "user n1ck is too similar to existing user nick" {
+		t.Errorf("Unexpected error %s", err)
+	}
+}
+
+func TestDeleteTweetRemovesItFromTheManager(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	tweet := domain.NewTextTweet("grupoesfera", "This is my first tweet")
+	id, _ := tweetManager.PublishTweet(context.Background(), tweet)
+	tweetManager.LikeTweet("nick", id)
+
+	var deleted domain.Tweet
+	tweetManager.Subscribe(func(event service.Event) {
+		if tweetDeleted, ok := event.(service.TweetDeleted); ok {
+			deleted = tweetDeleted.Tweet
+		}
+	})
+
+	// Operation
+	err := tweetManager.DeleteTweet("GrupoEsfera", id)
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	if tweetManager.GetTweetById(id) != nil || tweetManager.CountTweetsByUser("grupoesfera") != 0 {
+		t.Errorf("Expected the tweet to be deleted")
+	}
+
+	if tweets := tweetManager.GetTweetsOnThisDay(*tweet.Date); len(tweets) != 1 {
+		t.Errorf("Expected the tweet to be removed from the date index but were %d tweets", len(tweets))
+	}
+
+	if len(tweetManager.GetLikes(id)) != 0 {
+		t.Errorf("Expected the likes of the tweet to be removed")
+	}
+
+	if deleted != tweet {
+		t.Errorf("Expected a TweetDeleted event with the tweet")
+	}
+}
+
+func TestDeleteTweetOfAnotherUserFails(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	id, _ := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "This is my first tweet"))
+
+	// Operation
+	err := tweetManager.DeleteTweet("nick", id)
+	missingErr := tweetManager.DeleteTweet("nick", 99)
+
+	// Validation
+	if err == nil || err.Error() != "tweet 2 is not from user nick" {
+		t.Errorf("Expected an error deleting the tweet of other user but was %v", err)
+	}
+
+	if missingErr == nil || missingErr.Error() != "tweet 99 does not exist" {
+		t.Errorf("Expected a missing tweet error but was %v", missingErr)
+	}
+
+	if tweetManager.GetTweetById(id) == nil {
+		t.Errorf("Expected the tweet not to be deleted")
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const (
+	textTweetKind  = "text"
+	imageTweetKind = "image"
+	quoteTweetKind = "quote"
+)
+
+const (
+	deleteOperation   = "delete"
+	renameOperation   = "rename"
+	sequenceOperation = "sequence"
+)
+
+// tweetRecord is how tweets are stored. Quotes keep the id of the quoted
+// tweet instead of the tweet itself.
+//
+// Records with an operation change the tweets saved before: delete removes
+// the tweet with the id, rename moves the tweets of User up to the id to
+// NewUser and sequence keeps the last id given.
+//
+// Quotes of a detached tweet, which isn't stored, keep its user and text
+type tweetRecord struct {
+	Operation string `json:",omitempty"`
+	Kind      string `json:",omitempty"`
+	Id        int
+	User      string `json:",omitempty"`
+	NewUser   string `json:",omitempty"`
+	Text      string `json:",omitempty"`
+	Date      time.Time
+	URL       string `json:",omitempty"`
+	QuotedId  int    `json:",omitempty"`
+
+	QuotedUser string `json:",omitempty"`
+	QuotedText string `json:",omitempty"`
+}
+
+func newTweetRecord(tweet domain.Tweet) tweetRecord {
+
+	record := tweetRecord{
+		Kind: textTweetKind,
+		Id:   tweet.GetId(),
+		User: tweet.GetUser(),
+		Text: tweet.GetText(),
+	}
+
+	if tweet.GetDate() != nil {
+		record.Date = *tweet.GetDate()
+	}
+
+	switch tweet := tweet.(type) {
+	case *domain.ImageTweet:
+		record.Kind = imageTweetKind
+		record.URL = tweet.URL
+	case *domain.QuoteTweet:
+		record.Kind = quoteTweetKind
+		if tweet.QuotedTweet != nil {
+			record.QuotedId = tweet.QuotedTweet.GetId()
+		}
+		if tweet.QuotedTweet != nil && record.QuotedId == 0 {
+			record.QuotedUser = tweet.QuotedTweet.GetUser()
+			record.QuotedText = tweet.QuotedTweet.GetText()
+		}
+	}
+
+	return record
+}
+
+func (record tweetRecord) operation() string {
+
+	switch record.Operation {
+	case deleteOperation:
+		return fmt.Sprintf("delete of tweet %d", record.Id)
+	case renameOperation:
+		return fmt.Sprintf("rename of %s to %s", record.User, record.NewUser)
+	}
+
+	return record.Operation
+}
+
+// tweet builds the stored tweet. The quoted tweet is left empty and has to
+// be linked with linkQuotes
+func (record tweetRecord) tweet() (domain.Tweet, error) {
+
+	date := record.Date
+
+	textTweet := domain.TextTweet{
+		User: record.User,
+		Text: record.Text,
+		Date: &date,
+		Id:   record.Id,
+	}
+
+	switch record.Kind {
+
+	case textTweetKind:
+		return &textTweet, nil
+
+	case imageTweetKind:
+		return &domain.ImageTweet{TextTweet: textTweet, URL: record.URL}, nil
+
+	case quoteTweetKind:
+		quoteTweet := &domain.QuoteTweet{TextTweet: textTweet}
+		if record.QuotedUser != "" {
+			quoteTweet.QuotedTweet = detachedTweet(record.QuotedUser, record.QuotedText, record.Date)
+		}
+		return quoteTweet, nil
+	}
+
+	return nil, fmt.Errorf("tweet kind %s does not exist", record.Kind)
+}
+
+// detachedTweet returns a quoted tweet that isn't stored, like one
+// imported from an archive that didn't have it. It has no id and the date
+// of the quote
+func detachedTweet(user, text string, date time.Time) domain.Tweet {
+	return &domain.TextTweet{User: user, Text: text, Date: &date}
+}
+
+// tweetsOfRecords builds the tweets of the records, linking the quotes with
+// the quoted tweets whatever their order. Quotes of tweets that aren't in
+// the records are left empty
+func tweetsOfRecords(records []tweetRecord) ([]domain.Tweet, error) {
+
+	tweets := make([]domain.Tweet, 0, len(records))
+	tweetsById := make(map[int]domain.Tweet, len(records))
+
+	for _, record := range records {
+
+		tweet, err := record.tweet()
+
+		if err != nil {
+			return nil, err
+		}
+
+		tweets = append(tweets, tweet)
+		tweetsById[record.Id] = tweet
+	}
+
+	for index, record := range records {
+		if quotedTweet, found := tweetsById[record.QuotedId]; found && record.Kind == quoteTweetKind {
+			tweets[index].(*domain.QuoteTweet).QuotedTweet = quotedTweet
+		}
+	}
+
+	return tweets, nil
+}
+
+// compactRecords applies the operations of the records, in order, and
+// returns the records of the tweets that still exist sorted by id, along
+// with the last id that was given. Tweets are written asynchronously, so
+// their records may come after their deletion or rename: the deletions of
+// tweets that aren't in the records and the renames are kept after the
+// tweets, to be applied to the records that follow
+func compactRecords(records []tweetRecord) ([]tweetRecord, int) {
+
+	tweets := make(map[int]tweetRecord)
+	deleted := make(map[int]bool)
+	deletes := make([]tweetRecord, 0)
+	renames := make([]tweetRecord, 0)
+
+	var lastId int
+
+	for _, record := range records {
+
+		if record.Id > lastId {
+			lastId = record.Id
+		}
+
+		switch record.Operation {
+		case "":
+			tweets[record.Id] = record
+		case deleteOperation:
+			deleted[record.Id] = true
+			deletes = append(deletes, record)
+		case renameOperation:
+			renames = append(renames, record)
+		}
+	}
+
+	compacted := make([]tweetRecord, 0, len(tweets))
+
+	for id, record := range tweets {
+
+		if deleted[id] {
+			continue
+		}
+
+		if deleted[record.QuotedId] {
+			record.QuotedId = 0
+		}
+
+		for _, rename := range renames {
+			if id <= rename.Id && domain.NormalizeHandle(record.User) == rename.User {
+				record.User = rename.NewUser
+			}
+		}
+
+		compacted = append(compacted, record)
+	}
+
+	sort.Slice(compacted, func(i, j int) bool {
+		return compacted[i].Id < compacted[j].Id
+	})
+
+	for _, record := range deletes {
+		if _, found := tweets[record.Id]; !found {
+			compacted = append(compacted, record)
+		}
+	}
+
+	return append(compacted, renames...), lastId
+}
+
+// replayRecords saves the tweets that still exist after the records in the
+// repository, so the ids of the deleted ones aren't given again
+func replayRecords(records []tweetRecord, repository TweetRepository) (int, error) {
+
+	compacted, lastId := compactRecords(records)
+
+	tweetRecords := make([]tweetRecord, 0, len(compacted))
+
+	for _, record := range compacted {
+		if record.Operation == "" {
+			tweetRecords = append(tweetRecords, record)
+		}
+	}
+
+	tweets, err := tweetsOfRecords(tweetRecords)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for saved, tweet := range tweets {
+		if _, err := repository.Save(tweet); err != nil {
+			return saved, err
+		}
+	}
+
+	repository.SetLastId(lastId)
+
+	return len(tweets), nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cursoGo/src/domain"
+)
+
+// TweetRepository stores the published tweets. Users are identified by
+// their normalized handle and lists are sorted by id
+type TweetRepository interface {
+
+	// Save stores the tweet, giving it the next id unless it already has one
+	Save(tweet domain.Tweet) (int, error)
+
+	Get(id int) (domain.Tweet, error)
+	List() []domain.Tweet
+	ListByUser(user string) []domain.Tweet
+	Count() int
+	CountByUser(user string) int
+	Delete(id int) error
+
+	// SetLastId makes the next tweets get ids after the id, so the ids of
+	// deleted tweets aren't given again after a restart
+	SetLastId(id int)
+
+	RenameUser(oldUser, newUser string) error
+}
+
+type MemoryTweetRepository struct {
+	tweets       []domain.Tweet
+	tweetsById   map[int]domain.Tweet
+	tweetsByUser map[string][]domain.Tweet
+	lastId       int
+}
+
+func NewMemoryTweetRepository() *MemoryTweetRepository {
+
+	repository := new(MemoryTweetRepository)
+
+	repository.tweets = make([]domain.Tweet, 0)
+	repository.tweetsById = make(map[int]domain.Tweet)
+	repository.tweetsByUser = make(map[string][]domain.Tweet)
+
+	return repository
+}
+
+func (repository *MemoryTweetRepository) Save(tweet domain.Tweet) (int, error) {
+
+	if tweet.GetId() == 0 {
+		tweet.SetId(repository.lastId + 1)
+	}
+
+	id := tweet.GetId()
+
+	if _, found := repository.tweetsById[id]; found {
+		return 0, fmt.Errorf("tweet %d already exists", id)
+	}
+
+	if id > repository.lastId {
+		repository.lastId = id
+	}
+
+	user := domain.NormalizeHandle(tweet.GetUser())
+
+	repository.tweets = insertById(repository.tweets, tweet)
+	repository.tweetsByUser[user] = insertById(repository.tweetsByUser[user], tweet)
+	repository.tweetsById[id] = tweet
+
+	return id, nil
+}
+
+func (repository *MemoryTweetRepository) Get(id int) (domain.Tweet, error) {
+
+	tweet, found := repository.tweetsById[id]
+
+	if !found {
+		return nil, fmt.Errorf("tweet %d does not exist", id)
+	}
+
+	return tweet, nil
+}
+
+func (repository *MemoryTweetRepository) List() []domain.Tweet {
+	return append([]domain.Tweet(nil), repository.tweets...)
+}
+
+func (repository *MemoryTweetRepository) ListByUser(user string) []domain.Tweet {
+	return append([]domain.Tweet(nil), repository.tweetsByUser[domain.NormalizeHandle(user)]...)
+}
+
+func (repository *MemoryTweetRepository) Count() int {
+	return len(repository.tweets)
+}
+
+func (repository *MemoryTweetRepository) CountByUser(user string) int {
+	return len(repository.tweetsByUser[domain.NormalizeHandle(user)])
+}
+
+func (repository *MemoryTweetRepository) Delete(id int) error {
+
+	tweet, found := repository.tweetsById[id]
+
+	if !found {
+		return fmt.Errorf("tweet %d does not exist", id)
+	}
+
+	user := domain.NormalizeHandle(tweet.GetUser())
+
+	delete(repository.tweetsById, id)
+	repository.tweets = removeById(repository.tweets, id)
+	repository.tweetsByUser[user] = removeById(repository.tweetsByUser[user], id)
+
+	if len(repository.tweetsByUser[user]) == 0 {
+		delete(repository.tweetsByUser, user)
+	}
+
+	return nil
+}
+
+func (repository *MemoryTweetRepository) SetLastId(id int) {
+
+	if id > repository.lastId {
+		repository.lastId = id
+	}
+}
+
+func (repository *MemoryTweetRepository) RenameUser(oldUser, newUser string) error {
+
+	oldHandle := domain.NormalizeHandle(oldUser)
+	newHandle := domain.NormalizeHandle(newUser)
+
+	if oldHandle != newHandle && len(repository.tweetsByUser[newHandle]) > 0 {
+		return fmt.Errorf("user %s already has tweets", newHandle)
+	}
+
+	tweets := repository.tweetsByUser[oldHandle]
+
+	if len(tweets) == 0 {
+		return nil
+	}
+
+	// The saved tweets may be being read, so they are replaced by renamed
+	// copies instead of being changed. Quotes of the renamed tweets are
+	// copied too, to quote the copies
+	renamed := make(map[int]domain.Tweet, len(tweets))
+
+	for _, tweet := range tweets {
+		renamedTweet := copyTweet(tweet)
+		renamedTweet.SetUser(newUser)
+		renamed[tweet.GetId()] = renamedTweet
+	}
+
+	for _, tweet := range repository.tweets {
+		if quoteTweet, ok := tweet.(*domain.QuoteTweet); ok && quoteTweet.QuotedTweet != nil && renamed[quoteTweet.QuotedTweet.GetId()] != nil {
+
+			quote, found := renamed[tweet.GetId()]
+
+			if !found {
+				quote = copyTweet(tweet)
+				renamed[tweet.GetId()] = quote
+			}
+
+			quote.(*domain.QuoteTweet).QuotedTweet = renamed[quoteTweet.QuotedTweet.GetId()]
+		}
+	}
+
+	for position, tweet := range repository.tweets {
+		if renamedTweet, found := renamed[tweet.GetId()]; found {
+			repository.tweets[position] = renamedTweet
+			repository.tweetsById[tweet.GetId()] = renamedTweet
+		}
+	}
+
+	for _, userTweets := range repository.tweetsByUser {
+		for position, tweet := range userTweets {
+			if renamedTweet, found := renamed[tweet.GetId()]; found {
+				userTweets[position] = renamedTweet
+			}
+		}
+	}
+
+	delete(repository.tweetsByUser, oldHandle)
+	repository.tweetsByUser[newHandle] = tweets
+
+	return nil
+}
+
+func copyTweet(tweet domain.Tweet) domain.Tweet {
+
+	switch tweet := tweet.(type) {
+	case *domain.TextTweet:
+		copied := *tweet
+		return &copied
+	case *domain.ImageTweet:
+		copied := *tweet
+		return &copied
+	case *domain.QuoteTweet:
+		copied := *tweet
+		return &copied
+	}
+
+	return tweet
+}
+
+// insertById adds the tweet keeping the tweets sorted by id. Tweets are
+// usually saved in order, so it is an append most of the time
+func insertById(tweets []domain.Tweet, tweet domain.Tweet) []domain.Tweet {
+
+	position := sort.Search(len(tweets), func(index int) bool {
+		return tweets[index].GetId() > tweet.GetId()
+	})
+
+	tweets = append(tweets, nil)
+	copy(tweets[position+1:], tweets[position:])
+	tweets[position] = tweet
+
+	return tweets
+}
+
+func removeById(tweets []domain.Tweet, id int) []domain.Tweet {
+
+	position := sort.Search(len(tweets), func(index int) bool {
+		return tweets[index].GetId() >= id
+	})
+
+	if position < len(tweets) && tweets[position].GetId() == id {
+		tweets = append(tweets[:position], tweets[position+1:]...)
+	}
+
+	return tweets
+}
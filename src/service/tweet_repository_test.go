@@ -0,0 +1,45 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+	"github.com/cursoGo/src/service/repositorytest"
+)
+
+func TestMemoryTweetRepository(t *testing.T) {
+
+	repositorytest.TestRepository(t, func() service.TweetRepository {
+		return service.NewMemoryTweetRepository()
+	})
+}
+
+func TestTweetManagerIndexesTheTweetsOfTheRepository(t *testing.T) {
+
+	// Initialization
+	repository := service.NewMemoryTweetRepository()
+	repository.Save(domain.NewTextTweet("grupoesfera", "Stored before"))
+
+	// Operation
+	tweetManager := service.NewTweetManager(repository, service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	id, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("GrupoEsfera", "Published after"))
+
+	// Validation
+	if err != nil || id != 2 {
+		t.Fatalf("Expected the tweet to be published with id 2 but was %d, %v", id, err)
+	}
+
+	if count := tweetManager.CountTweetsByUser("grupoesfera"); count != 2 {
+		t.Errorf("Expected 2 tweets of grupoesfera but were %d", count)
+	}
+
+	if _, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "Hi")); err != nil {
+		t.Errorf("Expected the stored user to be registered but was %s", err.Error())
+	}
+
+	if _, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grup0esfera", "Hi")); err == nil {
+		t.Errorf("Expected a lookalike of the stored user to be rejected")
+	}
+}
@@ -0,0 +1,560 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+const (
+	segmentPrefix   = "tweets-"
+	segmentSuffix   = ".log"
+	snapshotPrefix  = "snapshot-"
+	snapshotSuffix  = ".snap"
+	temporarySuffix = ".tmp"
+)
+
+type TweetStoreConfig struct {
+
+	// SnapshotEvery is how many records are written to a segment before it
+	// is compacted into a new snapshot. Zero disables the snapshots
+	SnapshotEvery int
+
+	// Keyring encrypts the segments and snapshots. Nil keeps them in plain
+	// text
+	Keyring *Keyring
+
+	// MaxRetries, RetryDelay and MaxRetryDelay retry the deletions and
+	// renames that couldn't be written, as ChannelTweetWriterConfig does
+	// with the tweets
+	MaxRetries    int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+}
+
+func DefaultTweetStoreConfig() TweetStoreConfig {
+	return TweetStoreConfig{
+		SnapshotEvery: 1000,
+		MaxRetries:    3,
+		RetryDelay:    10 * time.Millisecond,
+		MaxRetryDelay: time.Second,
+	}
+}
+
+// TweetStore keeps the tweets in a directory of log segments and
+// snapshots. Snapshot n has the tweets that exist after all the segments
+// before n, so only segment n and the later ones have to be replayed.
+// Segments are compacted into snapshots in the background, dropping the
+// deleted tweets and applying the renames
+type TweetStore struct {
+	directory   string
+	config      TweetStoreConfig
+	deadLetters *DeadLetterQueue
+
+	mutex         sync.Mutex
+	segment       *TweetLog
+	segmentNumber int
+	written       int
+	lastId        int
+	err           error
+
+	compaction  sync.Mutex
+	compactions sync.WaitGroup
+}
+
+// OpenTweetStore opens the store in the directory, creating it if it
+// doesn't exist. The files left by an interrupted snapshot are removed
+func OpenTweetStore(directory string, config TweetStoreConfig) (*TweetStore, error) {
+
+	if err := os.MkdirAll(directory, 0777); err != nil {
+		return nil, err
+	}
+
+	store := new(TweetStore)
+
+	store.directory = directory
+	store.config = config
+
+	if err := store.removeTemporaryFiles(); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := store.latestSnapshot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.removeBefore(snapshot); err != nil {
+		return nil, err
+	}
+
+	segments, err := store.numbers(segmentPrefix, segmentSuffix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.segmentNumber = snapshot
+	if len(segments) > 0 && segments[len(segments)-1] > snapshot {
+		store.segmentNumber = segments[len(segments)-1]
+	}
+	if store.segmentNumber == 0 {
+		store.segmentNumber = 1
+	}
+
+	store.segment, err = OpenEncryptedTweetLog(store.segmentPath(store.segmentNumber), store.config.Keyring)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *TweetStore) Replay(repository TweetRepository) (int, error) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshot, err := store.latestSnapshot()
+
+	if err != nil {
+		return 0, err
+	}
+
+	records, err := store.readRecords(snapshot, store.segmentNumber)
+
+	if err != nil {
+		return 0, err
+	}
+
+	segmentRecords, err := store.segment.readRecords()
+
+	if err != nil {
+		return 0, err
+	}
+
+	records = append(records, segmentRecords...)
+
+	for _, record := range records {
+		if record.Id > store.lastId {
+			store.lastId = record.Id
+		}
+	}
+
+	store.written = len(segmentRecords)
+
+	return replayRecords(records, repository)
+}
+
+// Attach records the deletions and renames of the TweetManager, which
+// don't go through the TweetWriter. The ones that can't be written are kept
+// in the dead letters, if not nil
+func (store *TweetStore) Attach(tweetManager *TweetManager, deadLetters *DeadLetterQueue) {
+
+	store.deadLetters = deadLetters
+
+	tweetManager.Events().SubscribeSync("tweet store", store.handleEvent, TweetPublished{}, TweetDeleted{}, UserRenamed{})
+}
+
+func (store *TweetStore) WriteTweet(tweet domain.Tweet) error {
+	return store.write(newTweetRecord(tweet))
+}
+
+func (store *TweetStore) WriteTweets(tweets []domain.Tweet) error {
+
+	records := make([]tweetRecord, 0, len(tweets))
+
+	for _, tweet := range tweets {
+		records = append(records, newTweetRecord(tweet))
+	}
+
+	return store.writeRecords(records)
+}
+
+func (store *TweetStore) Snapshot() error {
+
+	store.mutex.Lock()
+	number, err := store.startSegment()
+	store.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return store.compact(number)
+}
+
+// Close waits for the running compactions and closes the current segment.
+// It returns the error of the last compaction that failed, if any
+func (store *TweetStore) Close() error {
+
+	store.compactions.Wait()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.segment.Close(); err != nil {
+		return err
+	}
+
+	return store.err
+}
+
+func (store *TweetStore) handleEvent(event Event) error {
+
+	switch event := event.(type) {
+
+	case TweetPublished:
+		store.mutex.Lock()
+		if event.Tweet.GetId() > store.lastId {
+			store.lastId = event.Tweet.GetId()
+		}
+		store.mutex.Unlock()
+
+	case TweetDeleted:
+		return store.writeOperation(tweetRecord{Operation: deleteOperation, Id: event.Tweet.GetId()})
+
+	case UserRenamed:
+		store.mutex.Lock()
+		lastId := store.lastId
+		store.mutex.Unlock()
+
+		return store.writeOperation(tweetRecord{Operation: renameOperation, Id: lastId, User: event.From, NewUser: event.To})
+	}
+
+	return nil
+}
+
+func (store *TweetStore) write(record tweetRecord) error {
+	return store.writeRecords([]tweetRecord{record})
+}
+
+// writeOperation writes the record of a deletion or rename, retrying it
+// when it fails. A record that still fails is kept as a dead letter
+func (store *TweetStore) writeOperation(record tweetRecord) error {
+
+	err := retryWrite(store.config.MaxRetries, store.config.RetryDelay, store.config.MaxRetryDelay, func(retry int) error {
+		return store.write(record)
+	})
+
+	if err == nil {
+		return nil
+	}
+
+	if store.deadLetters == nil {
+		return fmt.Errorf("%s couldn't be written: %s", record.operation(), err.Error())
+	}
+
+	if deadLetterErr := store.deadLetters.addRecords([]tweetRecord{record}, err); deadLetterErr != nil {
+		return fmt.Errorf("%s couldn't be written: %s, nor kept as a dead letter: %s", record.operation(), err.Error(), deadLetterErr.Error())
+	}
+
+	return fmt.Errorf("%s couldn't be written and was kept as a dead letter: %s", record.operation(), err.Error())
+}
+
+// writeRecords fails only when the records couldn't be written. A snapshot
+// that fails is kept as the error of the store
+func (store *TweetStore) writeRecords(records []tweetRecord) error {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.segment.writeRecords(records); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Operation == "" && record.Id > store.lastId {
+			store.lastId = record.Id
+		}
+	}
+
+	store.written += len(records)
+
+	if store.config.SnapshotEvery > 0 && store.written >= store.config.SnapshotEvery {
+
+		number, err := store.startSegment()
+
+		if err != nil {
+			store.err = err
+			return nil
+		}
+
+		store.compactions.Add(1)
+
+		go func() {
+
+			defer store.compactions.Done()
+
+			if err := store.compact(number); err != nil {
+				store.mutex.Lock()
+				store.err = err
+				store.mutex.Unlock()
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (store *TweetStore) startSegment() (int, error) {
+
+	number := store.segmentNumber + 1
+
+	segment, err := OpenEncryptedTweetLog(store.segmentPath(number), store.config.Keyring)
+
+	if err != nil {
+		return 0, err
+	}
+
+	store.segment.Close()
+
+	store.segment = segment
+	store.segmentNumber = number
+	store.written = 0
+
+	return number, nil
+}
+
+// compact writes snapshot number with the latest snapshot and the segments
+// before number, and then removes them. A snapshot is written to a
+// temporary file and renamed, so it is never seen half written
+func (store *TweetStore) compact(number int) error {
+
+	store.compaction.Lock()
+	defer store.compaction.Unlock()
+
+	snapshot, err := store.latestSnapshot()
+
+	if err != nil || snapshot >= number {
+		return err
+	}
+
+	records, err := store.readRecords(snapshot, number)
+
+	if err != nil {
+		return err
+	}
+
+	compacted, lastId := compactRecords(records)
+
+	temporaryPath := filepath.Join(store.directory, fmt.Sprintf("%s%08d%s", snapshotPrefix, number, temporarySuffix))
+
+	if err := writeSnapshot(temporaryPath, compacted, lastId, store.config.Keyring); err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	if err := os.Rename(temporaryPath, store.snapshotPath(number)); err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	if err := syncDirectory(store.directory); err != nil {
+		return err
+	}
+
+	return store.removeBefore(number)
+}
+
+func (store *TweetStore) readRecords(snapshot, number int) ([]tweetRecord, error) {
+
+	records := make([]tweetRecord, 0)
+
+	paths := make([]string, 0)
+
+	if snapshot > 0 {
+		paths = append(paths, store.snapshotPath(snapshot))
+	}
+
+	segments, err := store.numbers(segmentPrefix, segmentSuffix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, segment := range segments {
+		if segment >= snapshot && segment < number {
+			paths = append(paths, store.segmentPath(segment))
+		}
+	}
+
+	for _, path := range paths {
+
+		tweetLog, err := OpenEncryptedTweetLog(path, store.config.Keyring)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fileRecords, err := tweetLog.readRecords()
+
+		tweetLog.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, fileRecords...)
+	}
+
+	return records, nil
+}
+
+func writeSnapshot(path string, records []tweetRecord, lastId int, keyring *Keyring) error {
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+
+	records = append([]tweetRecord{{Operation: sequenceOperation, Id: lastId}}, records...)
+
+	for _, record := range records {
+
+		frame, err := encodeRecord(record, keyring)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := writer.Write(frame); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+func syncDirectory(directory string) error {
+
+	file, err := os.Open(directory)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return file.Sync()
+}
+
+func (store *TweetStore) latestSnapshot() (int, error) {
+
+	snapshots, err := store.numbers(snapshotPrefix, snapshotSuffix)
+
+	if err != nil || len(snapshots) == 0 {
+		return 0, err
+	}
+
+	return snapshots[len(snapshots)-1], nil
+}
+
+func (store *TweetStore) removeBefore(number int) error {
+
+	snapshots, err := store.numbers(snapshotPrefix, snapshotSuffix)
+
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot < number {
+			if err := os.Remove(store.snapshotPath(snapshot)); err != nil {
+				return err
+			}
+		}
+	}
+
+	segments, err := store.numbers(segmentPrefix, segmentSuffix)
+
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if segment < number {
+			if err := os.Remove(store.segmentPath(segment)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (store *TweetStore) removeTemporaryFiles() error {
+
+	files, err := ioutil.ReadDir(store.directory)
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), temporarySuffix) {
+			if err := os.Remove(filepath.Join(store.directory, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (store *TweetStore) numbers(prefix, suffix string) ([]int, error) {
+
+	files, err := ioutil.ReadDir(store.directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0)
+
+	for _, file := range files {
+
+		var number int
+
+		name := file.Name()
+
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), "%d", &number); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+
+	sort.Ints(numbers)
+
+	return numbers, nil
+}
+
+func (store *TweetStore) segmentPath(number int) string {
+	return filepath.Join(store.directory, fmt.Sprintf("%s%08d%s", segmentPrefix, number, segmentSuffix))
+}
+
+func (store *TweetStore) snapshotPath(number int) string {
+	return filepath.Join(store.directory, fmt.Sprintf("%s%08d%s", snapshotPrefix, number, snapshotSuffix))
+}
@@ -0,0 +1,365 @@
+package service_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func tempStoreDirectory(t *testing.T) (string, func()) {
+
+	directory, err := ioutil.TempDir("", "tweetstore")
+
+	if err != nil {
+		t.Fatalf("Unexpected error creating a directory: %s", err.Error())
+	}
+
+	return directory, func() { os.RemoveAll(directory) }
+}
+
+// openStore opens the store and a manager with the tweets replayed from it
+func openStore(t *testing.T, directory string, config service.TweetStoreConfig) (*service.TweetStore, *service.TweetManager) {
+
+	store, err := service.OpenTweetStore(directory, config)
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening the store: %s", err.Error())
+	}
+
+	repository := service.NewMemoryTweetRepository()
+
+	if _, err := store.Replay(repository); err != nil {
+		t.Fatalf("Unexpected error replaying the store: %s", err.Error())
+	}
+
+	tweetManager := service.NewTweetManager(repository, service.NewChannelTweetWriter(store))
+	store.Attach(tweetManager, nil)
+
+	return store, tweetManager
+}
+
+func publishAndWait(t *testing.T, tweetManager *service.TweetManager, user, text string) int {
+
+	ctx := context.Background()
+
+	id, err := tweetManager.PublishTweetAndWait(ctx, domain.NewTextTweet(user, text))
+
+	if err != nil {
+		t.Fatalf("Unexpected error publishing: %s", err.Error())
+	}
+
+	return id
+}
+
+func storeFiles(directory string) []string {
+
+	files, _ := ioutil.ReadDir(directory)
+
+	names := make([]string, 0, len(files))
+
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+
+	return names
+}
+
+func TestTweetStoreReplaysTheSnapshotAndTheLaterSegments(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{})
+
+	publishAndWait(t, tweetManager, "grupoesfera", "Before the snapshot")
+
+	// Operation
+	err := store.Snapshot()
+	publishAndWait(t, tweetManager, "grupoesfera", "After the snapshot")
+	store.Close()
+
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{})
+	defer store.Close()
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error taking the snapshot: %s", err.Error())
+	}
+
+	assertTexts(t, tweetManager.GetTweets(), "Before the snapshot", "After the snapshot")
+
+	files := storeFiles(directory)
+
+	if len(files) != 2 || files[0] != "snapshot-00000002.snap" || files[1] != "tweets-00000002.log" {
+		t.Errorf("Expected only the snapshot and the last segment but were %v", files)
+	}
+}
+
+func TestTweetStoreCompactionDropsDeletedTweetsAndAppliesRenames(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{})
+
+	publishAndWait(t, tweetManager, "grupoesfera", "Kept")
+	deletedId := publishAndWait(t, tweetManager, "grupoesfera", "Deleted")
+
+	tweetManager.DeleteTweet("grupoesfera", deletedId)
+	tweetManager.RenameUser("grupoesfera", "esfera")
+
+	// Operation
+	store.Snapshot()
+	store.Close()
+
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{})
+	defer store.Close()
+
+	// Validation
+	assertTexts(t, tweetManager.GetTweets(), "Kept")
+
+	if user := tweetManager.GetTweetById(1).GetUser(); user != "esfera" {
+		t.Errorf("Expected the tweet to be from esfera but was from %s", user)
+	}
+
+	if id := publishAndWait(t, tweetManager, "esfera", "New"); id != 3 {
+		t.Errorf("Expected the id of the deleted tweet not to be given again but was %d", id)
+	}
+}
+
+func TestTweetStoreKeepsDeletionsAndRenamesOfTweetsWrittenAfterTheSnapshot(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, err := service.OpenTweetStore(directory, service.TweetStoreConfig{})
+
+	if err != nil {
+		t.Fatalf("Unexpected error opening the store: %s", err.Error())
+	}
+
+	// The tweets aren't written to the store until after the snapshot
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+	store.Attach(tweetManager, nil)
+
+	ctx := context.Background()
+
+	kept := domain.NewTextTweet("grupoesfera", "Kept")
+	deleted := domain.NewTextTweet("grupoesfera", "Deleted")
+
+	tweetManager.PublishTweet(ctx, kept)
+	deletedId, _ := tweetManager.PublishTweet(ctx, deleted)
+
+	tweetManager.DeleteTweet("grupoesfera", deletedId)
+	tweetManager.RenameUser("grupoesfera", "esfera")
+
+	// Operation
+	store.Snapshot()
+	store.WriteTweets([]domain.Tweet{kept, deleted})
+	store.Close()
+
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{})
+	defer store.Close()
+
+	// Validation
+	assertTexts(t, tweetManager.GetTweets(), "Kept")
+
+	if user := tweetManager.GetTweetById(1).GetUser(); user != "esfera" {
+		t.Errorf("Expected the tweet to be from esfera but was from %s", user)
+	}
+}
+
+func TestTweetStoreSnapshotsInTheBackground(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	config := service.TweetStoreConfig{SnapshotEvery: 3}
+
+	store, tweetManager := openStore(t, directory, config)
+
+	// Operation
+	for n := 0; n < 10; n++ {
+		publishAndWait(t, tweetManager, "grupoesfera", fmt.Sprintf("Tweet %d", n))
+	}
+
+	err := store.Close()
+
+	// Validation
+	if err != nil {
+		t.Fatalf("Unexpected error compacting: %s", err.Error())
+	}
+
+	files := storeFiles(directory)
+
+	if len(files) != 2 || files[0] != "snapshot-00000004.snap" {
+		t.Errorf("Expected the segments to be compacted but the files were %v", files)
+	}
+
+	store, tweetManager = openStore(t, directory, config)
+	defer store.Close()
+
+	if count := len(tweetManager.GetTweets()); count != 10 {
+		t.Errorf("Expected 10 tweets but were %d", count)
+	}
+}
+
+func TestTweetStoreIgnoresAnInterruptedSnapshot(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{})
+	publishAndWait(t, tweetManager, "grupoesfera", "First")
+	store.Close()
+
+	ioutil.WriteFile(filepath.Join(directory, "snapshot-00000002.tmp"), []byte("half written"), 0666)
+
+	// Operation
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{})
+	defer store.Close()
+
+	// Validation
+	assertTexts(t, tweetManager.GetTweets(), "First")
+
+	if _, err := os.Stat(filepath.Join(directory, "snapshot-00000002.tmp")); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary snapshot to be removed")
+	}
+}
+
+func TestTweetStoreRecoversWhenTheSegmentsWereNotRemovedAfterASnapshot(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	store, tweetManager := openStore(t, directory, service.TweetStoreConfig{})
+	publishAndWait(t, tweetManager, "grupoesfera", "First")
+	publishAndWait(t, tweetManager, "grupoesfera", "Second")
+
+	segment, _ := ioutil.ReadFile(filepath.Join(directory, "tweets-00000001.log"))
+
+	store.Snapshot()
+	store.Close()
+
+	ioutil.WriteFile(filepath.Join(directory, "tweets-00000001.log"), segment, 0666)
+
+	// Operation
+	store, tweetManager = openStore(t, directory, service.TweetStoreConfig{})
+	defer store.Close()
+
+	// Validation
+	assertTexts(t, tweetManager.GetTweets(), "First", "Second")
+}
+
+// TestTweetStoreHelperProcess publishes and deletes tweets until it is
+// killed, printing the ids once they are durable. It only runs as the
+// process started by TestTweetStoreSurvivesBeingKilled
+func TestTweetStoreHelperProcess(t *testing.T) {
+
+	directory := os.Getenv("TWEET_STORE_DIRECTORY")
+
+	if directory == "" {
+		return
+	}
+
+	_, tweetManager := openStore(t, directory, service.TweetStoreConfig{SnapshotEvery: 2})
+
+	for n := 0; ; n++ {
+
+		id := publishAndWait(t, tweetManager, "grupoesfera", "Tweet "+strconv.Itoa(n))
+		fmt.Println("published", id)
+
+		if n%3 == 0 {
+			fmt.Println("deleting", id)
+			tweetManager.DeleteTweet("grupoesfera", id)
+			fmt.Println("deleted", id)
+		}
+	}
+}
+
+func TestTweetStoreSurvivesBeingKilled(t *testing.T) {
+
+	// Initialization
+	directory, remove := tempStoreDirectory(t)
+	defer remove()
+
+	// published tells whether the tweets acknowledged by the process must
+	// exist. Tweets being deleted when the process was killed can be in
+	// either state, so they aren't checked
+	published := make(map[int]bool)
+
+	for run := 0; run < 5; run++ {
+
+		deleting := 0
+
+		// Operation
+		command := exec.Command(os.Args[0], "-test.run=TestTweetStoreHelperProcess")
+		command.Env = append(os.Environ(), "TWEET_STORE_DIRECTORY="+directory)
+
+		output, _ := command.StdoutPipe()
+		scanner := bufio.NewScanner(output)
+
+		if err := command.Start(); err != nil {
+			t.Fatalf("Unexpected error starting the process: %s", err.Error())
+		}
+
+		// The process is killed after some lines, and the lines it printed
+		// until it died are still read
+		for lines := 0; scanner.Scan(); lines++ {
+
+			if lines == 20+7*run {
+				command.Process.Kill()
+			}
+
+			var action string
+			var id int
+
+			if _, err := fmt.Sscan(scanner.Text(), &action, &id); err != nil {
+				t.Fatalf("Unexpected output of the process: %s", scanner.Text())
+			}
+
+			switch action {
+			case "published":
+				published[id] = true
+			case "deleting":
+				deleting = id
+			case "deleted":
+				published[id] = false
+				deleting = 0
+			}
+		}
+
+		command.Wait()
+
+		// Validation
+		store, tweetManager := openStore(t, directory, service.TweetStoreConfig{})
+
+		delete(published, deleting)
+
+		for id, exists := range published {
+			if exists && tweetManager.GetTweetById(id) == nil {
+				t.Fatalf("Expected tweet %d to survive the crash of run %d", id, run)
+			}
+			if !exists && tweetManager.GetTweetById(id) != nil {
+				t.Fatalf("Expected tweet %d to stay deleted after the crash of run %d", id, run)
+			}
+		}
+
+		store.Close()
+	}
+}
@@ -1,73 +1,521 @@
 package service
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/cursoGo/src/domain"
 )
 
+// TweetWriter writes the tweets somewhere they are kept. A tweet whose
+// write fails may be written again
 type TweetWriter interface {
-	WriteTweet(domain.Tweet)
+	WriteTweet(domain.Tweet) error
 }
 
+// BatchTweetWriter is a TweetWriter that writes many tweets at once faster
+// than one by one
+type BatchTweetWriter interface {
+	TweetWriter
+	WriteTweets([]domain.Tweet) error
+}
+
+// MemoryTweetWriter keeps the written tweets in Tweets. Tweets can be read
+// once the writes are acknowledged
 type MemoryTweetWriter struct {
 	Tweets []domain.Tweet
+	mutex  sync.Mutex
 }
 
 func NewMemoryTweetWriter() *MemoryTweetWriter {
 	return new(MemoryTweetWriter)
 }
 
-func (writer *MemoryTweetWriter) WriteTweet(tweet domain.Tweet) {
+func (writer *MemoryTweetWriter) WriteTweet(tweet domain.Tweet) error {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
 	writer.Tweets = append(writer.Tweets, tweet)
+
+	return nil
+}
+
+func (writer *MemoryTweetWriter) WriteTweets(tweets []domain.Tweet) error {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.Tweets = append(writer.Tweets, tweets...)
+
+	return nil
+}
+
+type OverflowPolicy int
+
+const (
+	// BlockOnOverflow waits for room in the queue
+	BlockOnOverflow OverflowPolicy = iota
+
+	// DropOnOverflow discards the tweet, failing its WriteAck with
+	// ErrTweetDropped
+	DropOnOverflow
+
+	// ErrorOnOverflow rejects the tweet with ErrQueueFull
+	ErrorOnOverflow
+)
+
+var ErrQueueFull = errors.New("tweet writer queue is full")
+
+var ErrTweetDropped = errors.New("tweet was dropped because the writer queue is full")
+
+type ChannelTweetWriterConfig struct {
+
+	// QueueSize is how many tweets can wait to be written
+	QueueSize int
+
+	// BatchSize is how many tweets are written at once
+	BatchSize int
+
+	// BatchDelay is how long a tweet can wait for the batch to be full
+	BatchDelay time.Duration
+
+	Overflow OverflowPolicy
+
+	// MaxRetries is how many times a batch that failed is written again.
+	// The wait between retries starts at RetryDelay and doubles up to
+	// MaxRetryDelay
+	MaxRetries    int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// DeadLetters keeps the tweets that still fail after every retry.
+	// Without it they are lost, failing their WriteAck
+	DeadLetters *DeadLetterQueue
 }
 
-type FileTweetWriter struct {
-	file *os.File
+func DefaultChannelTweetWriterConfig() ChannelTweetWriterConfig {
+	return ChannelTweetWriterConfig{
+		QueueSize:     1024,
+		BatchSize:     100,
+		BatchDelay:    10 * time.Millisecond,
+		Overflow:      BlockOnOverflow,
+		MaxRetries:    3,
+		RetryDelay:    10 * time.Millisecond,
+		MaxRetryDelay: time.Second,
+	}
 }
 
-func NewFileTweetWriter() *FileTweetWriter {
+type ChannelTweetWriterStats struct {
+	Queued       int
+	Written      int
+	Dropped      int
+	Rejected     int
+	Batches      int
+	Retries      int
+	Failed       int
+	DeadLettered int
+}
 
-	file, _ := os.OpenFile(
-		"tweets.txt",
-		os.O_WRONLY|os.O_TRUNC|os.O_CREATE,
-		0666,
-	)
+type WriteAck struct {
+	done chan struct{}
+	err  error
+}
+
+func newWriteAck() *WriteAck {
+
+	ack := new(WriteAck)
 
-	writer := new(FileTweetWriter)
-	writer.file = file
+	ack.done = make(chan struct{})
+
+	return ack
+}
 
-	return writer
+func (ack *WriteAck) Done() <-chan struct{} {
+	return ack.done
 }
 
-func (writer *FileTweetWriter) WriteTweet(tweet domain.Tweet) {
+// Wait waits for the tweet to be written. It fails with ErrTweetDropped if
+// the tweet was dropped, with the error of the write if it failed after
+// every retry, or with the error of the context if it is done first
+func (ack *WriteAck) Wait(ctx context.Context) error {
 
-	if writer.file != nil {
-		byteSlice := []byte(tweet.PrintableTweet() + "\n")
-		writer.file.Write(byteSlice)
+	select {
+	case <-ack.done:
+		return ack.err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
+func (ack *WriteAck) complete(err error) {
+	ack.err = err
+	close(ack.done)
+}
+
+type queuedTweet struct {
+	tweet   domain.Tweet
+	ack     *WriteAck
+	flushed chan bool
+}
+
+// ChannelTweetWriter writes the tweets in the background through a bounded
+// queue. One goroutine takes the tweets from the queue and writes them in
+// batches, as many as BatchSize or those that waited BatchDelay
 type ChannelTweetWriter struct {
 	writer TweetWriter
+	config ChannelTweetWriterConfig
+	queue  chan queuedTweet
+	done   chan bool
+
+	// slots has an item for every tweet given room in the queue, so the
+	// room can be taken before the tweet is published
+	slots chan bool
+
+	// closing lets Close wait for the tweets being queued
+	closing sync.RWMutex
+	closed  bool
+
+	mutex sync.Mutex
+	stats ChannelTweetWriterStats
 }
 
 func NewChannelTweetWriter(writer TweetWriter) *ChannelTweetWriter {
+	return NewChannelTweetWriterWithConfig(writer, DefaultChannelTweetWriterConfig())
+}
+
+func NewChannelTweetWriterWithConfig(writer TweetWriter, config ChannelTweetWriterConfig) *ChannelTweetWriter {
+
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+
+	if config.BatchSize < 1 {
+		config.BatchSize = 1
+	}
+
 	channelTweetWriter := new(ChannelTweetWriter)
+
 	channelTweetWriter.writer = writer
+	channelTweetWriter.config = config
+	channelTweetWriter.queue = make(chan queuedTweet, config.QueueSize)
+	channelTweetWriter.slots = make(chan bool, config.QueueSize)
+	channelTweetWriter.done = make(chan bool)
+
+	go channelTweetWriter.run()
+
 	return channelTweetWriter
 }
 
-func (channelWriter *ChannelTweetWriter) WriteTweet(tweetsToWrite chan domain.Tweet, quit chan bool) {
+// Enqueue queues the tweet to be written following the overflow policy.
+// While the queue is full, BlockOnOverflow waits until the context is done.
+// Once queued, the tweet is written even if the context is done, and the
+// returned WriteAck tells when
+func (channelWriter *ChannelTweetWriter) Enqueue(ctx context.Context, tweet domain.Tweet) (*WriteAck, error) {
+
+	slot, err := channelWriter.reserve(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return slot.send(tweet), nil
+}
+
+// queueSlot is room in the queue taken for a tweet. A dropped slot has no
+// room and drops its tweet
+type queueSlot struct {
+	channelWriter *ChannelTweetWriter
+	dropped       bool
+}
+
+// reserve takes room in the queue for a tweet following the overflow
+// policy, as Enqueue does
+func (channelWriter *ChannelTweetWriter) reserve(ctx context.Context) (*queueSlot, error) {
+
+	channelWriter.closing.RLock()
+	defer channelWriter.closing.RUnlock()
+
+	if channelWriter.closed {
+		return nil, fmt.Errorf("tweet writer is closed")
+	}
+
+	slot := &queueSlot{channelWriter: channelWriter}
+
+	if channelWriter.config.Overflow == BlockOnOverflow {
+		select {
+		case channelWriter.slots <- true:
+			return slot, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 
-	tweet, open := <-tweetsToWrite
+	select {
 
-	for open {
+	case channelWriter.slots <- true:
+		return slot, nil
 
-		channelWriter.writer.WriteTweet(tweet)
+	default:
+		channelWriter.mutex.Lock()
+		defer channelWriter.mutex.Unlock()
+
+		if channelWriter.config.Overflow == ErrorOnOverflow {
+			channelWriter.stats.Rejected++
+			return nil, ErrQueueFull
+		}
+
+		channelWriter.stats.Dropped++
+		slot.dropped = true
+
+		return slot, nil
+	}
+}
+
+func (slot *queueSlot) send(tweet domain.Tweet) *WriteAck {
+
+	ack := newWriteAck()
+
+	if slot.dropped {
+		ack.complete(ErrTweetDropped)
+		return ack
+	}
+
+	channelWriter := slot.channelWriter
+
+	channelWriter.closing.RLock()
+	defer channelWriter.closing.RUnlock()
+
+	if channelWriter.closed {
+		<-channelWriter.slots
+		ack.complete(fmt.Errorf("tweet writer is closed"))
+		return ack
+	}
+
+	channelWriter.queue <- queuedTweet{tweet: tweet, ack: ack}
+
+	return ack
+}
+
+func (slot *queueSlot) cancel() {
+
+	if !slot.dropped {
+		<-slot.channelWriter.slots
+	}
+}
+
+// Flush waits for the tweets queued before to be written, or for the
+// context to be done
+func (channelWriter *ChannelTweetWriter) Flush(ctx context.Context) error {
+
+	flushed := make(chan bool)
+
+	if err := channelWriter.enqueue(ctx, queuedTweet{flushed: flushed}); err != nil {
+		return err
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes the queued tweets and stops the writer. Tweets can't be
+// queued after it is closed
+func (channelWriter *ChannelTweetWriter) Close() {
+
+	channelWriter.closing.Lock()
+
+	if !channelWriter.closed {
+		channelWriter.closed = true
+		close(channelWriter.queue)
+	}
+
+	channelWriter.closing.Unlock()
+
+	<-channelWriter.done
+}
+
+func (channelWriter *ChannelTweetWriter) Stats() ChannelTweetWriterStats {
+
+	channelWriter.mutex.Lock()
+	defer channelWriter.mutex.Unlock()
+
+	stats := channelWriter.stats
+	stats.Queued = len(channelWriter.queue)
+
+	return stats
+}
+
+func (channelWriter *ChannelTweetWriter) enqueue(ctx context.Context, item queuedTweet) error {
+
+	channelWriter.closing.RLock()
+	defer channelWriter.closing.RUnlock()
+
+	if channelWriter.closed {
+		return fmt.Errorf("tweet writer is closed")
+	}
+
+	select {
+	case channelWriter.queue <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (channelWriter *ChannelTweetWriter) run() {
+
+	defer close(channelWriter.done)
+
+	batch := make([]queuedTweet, 0, channelWriter.config.BatchSize)
+
+	var batchDelay <-chan time.Time
+
+	for {
+
+		select {
+
+		case item, open := <-channelWriter.queue:
+
+			if !open {
+				channelWriter.writeBatch(batch)
+				return
+			}
+
+			if item.flushed != nil {
+				channelWriter.writeBatch(batch)
+				batch, batchDelay = batch[:0], nil
+				close(item.flushed)
+				continue
+			}
+
+			<-channelWriter.slots
+
+			batch = append(batch, item)
+
+			if len(batch) >= channelWriter.config.BatchSize {
+				channelWriter.writeBatch(batch)
+				batch, batchDelay = batch[:0], nil
+			} else if len(batch) == 1 {
+				batchDelay = time.After(channelWriter.config.BatchDelay)
+			}
+
+		case <-batchDelay:
+			channelWriter.writeBatch(batch)
+			batch, batchDelay = batch[:0], nil
+		}
+	}
+}
+
+func (channelWriter *ChannelTweetWriter) writeBatch(batch []queuedTweet) {
+
+	if len(batch) == 0 {
+		return
+	}
+
+	tweets := make([]domain.Tweet, 0, len(batch))
+
+	for _, item := range batch {
+		tweets = append(tweets, item.tweet)
+	}
+
+	err := channelWriter.writeWithRetries(tweets)
+
+	if err != nil {
+		err = channelWriter.fail(tweets, err)
+	}
+
+	channelWriter.mutex.Lock()
+	if err == nil {
+		channelWriter.stats.Written += len(batch)
+	}
+	channelWriter.stats.Batches++
+	channelWriter.mutex.Unlock()
+
+	for _, item := range batch {
+		item.ack.complete(err)
+	}
+}
+
+// writeWithRetries writes the tweets, retrying the whole batch with
+// exponential backoff while it fails. Writers remove the tweets of a batch
+// that failed half written, so they aren't written twice
+func (channelWriter *ChannelTweetWriter) writeWithRetries(tweets []domain.Tweet) error {
+
+	config := channelWriter.config
+
+	return retryWrite(config.MaxRetries, config.RetryDelay, config.MaxRetryDelay, func(retry int) error {
+
+		if retry > 0 {
+			channelWriter.mutex.Lock()
+			channelWriter.stats.Retries++
+			channelWriter.mutex.Unlock()
+		}
+
+		return writeTweets(channelWriter.writer, tweets)
+	})
+}
+
+// retryWrite calls write until it succeeds or has been retried maxRetries
+// times, waiting between retries from delay and doubling up to maxDelay
+func retryWrite(maxRetries int, delay, maxDelay time.Duration, write func(retry int) error) error {
+
+	err := write(0)
+
+	for retry := 1; err != nil && retry <= maxRetries; retry++ {
+
+		time.Sleep(delay)
+
+		if delay *= 2; maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+
+		err = write(retry)
+	}
+
+	return err
+}
+
+// fail keeps the tweets that couldn't be written as dead letters, returning
+// the error for their acks
+func (channelWriter *ChannelTweetWriter) fail(tweets []domain.Tweet, err error) error {
+
+	channelWriter.mutex.Lock()
+	channelWriter.stats.Failed += len(tweets)
+	channelWriter.mutex.Unlock()
+
+	if channelWriter.config.DeadLetters == nil {
+		return fmt.Errorf("tweet couldn't be written: %s", err.Error())
+	}
+
+	if deadLetterErr := channelWriter.config.DeadLetters.Add(tweets, err); deadLetterErr != nil {
+		return fmt.Errorf("tweet couldn't be written: %s, nor kept as a dead letter: %s", err.Error(), deadLetterErr.Error())
+	}
+
+	channelWriter.mutex.Lock()
+	channelWriter.stats.DeadLettered += len(tweets)
+	channelWriter.mutex.Unlock()
+
+	return fmt.Errorf("tweet couldn't be written and was kept as a dead letter: %s", err.Error())
+}
+
+func writeTweets(writer TweetWriter, tweets []domain.Tweet) error {
+
+	if batchWriter, ok := writer.(BatchTweetWriter); ok {
+		return batchWriter.WriteTweets(tweets)
+	}
 
-		tweet, open = <-tweetsToWrite
+	for _, tweet := range tweets {
+		if err := writer.WriteTweet(tweet); err != nil {
+			return err
+		}
 	}
 
-	quit <- true
+	return nil
 }
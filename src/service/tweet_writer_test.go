@@ -1,7 +1,12 @@
 package service_test
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cursoGo/src/domain"
 	"github.com/cursoGo/src/service"
@@ -16,17 +21,13 @@ func TestCanWriteATweet(t *testing.T) {
 	memoryTweetWriter := service.NewMemoryTweetWriter()
 	tweetWriter := service.NewChannelTweetWriter(memoryTweetWriter)
 
-	tweetsToWrite := make(chan domain.Tweet)
-	quit := make(chan bool)
-
-	go tweetWriter.WriteTweet(tweetsToWrite, quit)
+	ctx := context.Background()
 
 	// Operation
-	tweetsToWrite <- tweet
-	tweetsToWrite <- tweet2
-	close(tweetsToWrite)
+	tweetWriter.Enqueue(ctx, tweet)
+	tweetWriter.Enqueue(ctx, tweet2)
 
-	<-quit
+	tweetWriter.Flush(ctx)
 
 	// Validation
 	if memoryTweetWriter.Tweets[0] != tweet {
@@ -37,3 +38,318 @@ func TestCanWriteATweet(t *testing.T) {
 		t.Errorf("A tweet in the writer was expected")
 	}
 }
+
+// batchRecorder records the size of every batch it writes
+type batchRecorder struct {
+	mutex   sync.Mutex
+	batches []int
+}
+
+func (recorder *batchRecorder) WriteTweet(tweet domain.Tweet) error {
+	return recorder.WriteTweets([]domain.Tweet{tweet})
+}
+
+func (recorder *batchRecorder) WriteTweets(tweets []domain.Tweet) error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.batches = append(recorder.batches, len(tweets))
+
+	return nil
+}
+
+// blockingTweetWriter waits for release before every write
+type blockingTweetWriter struct {
+	release chan bool
+	writer  *service.MemoryTweetWriter
+}
+
+func (writer *blockingTweetWriter) WriteTweet(tweet domain.Tweet) error {
+	<-writer.release
+	return writer.writer.WriteTweet(tweet)
+}
+
+// newBlockedWriter returns a writer with a queue of two tweets whose first
+// tweet is being written and waits for release
+func newBlockedWriter(t *testing.T, overflow service.OverflowPolicy) (*service.ChannelTweetWriter, *blockingTweetWriter) {
+
+	blockingWriter := &blockingTweetWriter{make(chan bool), service.NewMemoryTweetWriter()}
+
+	tweetWriter := service.NewChannelTweetWriterWithConfig(blockingWriter, service.ChannelTweetWriterConfig{
+		QueueSize:  2,
+		BatchSize:  1,
+		BatchDelay: time.Second,
+		Overflow:   overflow,
+	})
+
+	tweetWriter.Enqueue(context.Background(), domain.NewTextTweet("grupoesfera", "Being written"))
+
+	for tweetWriter.Stats().Queued != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for n := 0; n < 2; n++ {
+		if _, err := tweetWriter.Enqueue(context.Background(), domain.NewTextTweet("grupoesfera", fmt.Sprintf("Queued %d", n))); err != nil {
+			t.Fatalf("Unexpected error queueing: %s", err.Error())
+		}
+	}
+
+	return tweetWriter, blockingWriter
+}
+
+func TestChannelTweetWriterWritesInBatches(t *testing.T) {
+
+	// Initialization
+	recorder := new(batchRecorder)
+
+	tweetWriter := service.NewChannelTweetWriterWithConfig(recorder, service.ChannelTweetWriterConfig{
+		QueueSize:  300,
+		BatchSize:  100,
+		BatchDelay: time.Hour,
+	})
+
+	// Operation
+	for n := 0; n < 250; n++ {
+		tweetWriter.Enqueue(context.Background(), domain.NewTextTweet("grupoesfera", "Batched tweet"))
+	}
+
+	tweetWriter.Flush(context.Background())
+
+	// Validation
+	if !reflect.DeepEqual(recorder.batches, []int{100, 100, 50}) {
+		t.Errorf("Expected batches of [100 100 50] but were %v", recorder.batches)
+	}
+
+	if stats := tweetWriter.Stats(); stats.Written != 250 || stats.Batches != 3 || stats.Queued != 0 {
+		t.Errorf("Expected 250 tweets written in 3 batches but the stats were %+v", stats)
+	}
+}
+
+func TestChannelTweetWriterWritesIncompleteBatchesAfterTheDelay(t *testing.T) {
+
+	// Initialization
+	memoryTweetWriter := service.NewMemoryTweetWriter()
+
+	tweetWriter := service.NewChannelTweetWriterWithConfig(memoryTweetWriter, service.ChannelTweetWriterConfig{
+		QueueSize:  10,
+		BatchSize:  10,
+		BatchDelay: 10 * time.Millisecond,
+	})
+
+	ctx := context.Background()
+
+	// Operation
+	ack, _ := tweetWriter.Enqueue(ctx, domain.NewTextTweet("grupoesfera", "Alone in the batch"))
+
+	// Validation
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	if err := ack.Wait(waitCtx); err != nil {
+		t.Errorf("Expected the tweet to be written after the batch delay but was %s", err)
+	}
+}
+
+func TestChannelTweetWriterDropsTweetsWhenTheQueueIsFull(t *testing.T) {
+
+	// Initialization
+	tweetWriter, blockingWriter := newBlockedWriter(t, service.DropOnOverflow)
+
+	ctx := context.Background()
+
+	// Operation
+	ack, err := tweetWriter.Enqueue(ctx, domain.NewTextTweet("grupoesfera", "Dropped"))
+
+	close(blockingWriter.release)
+	tweetWriter.Close()
+
+	// Validation
+	if err != nil || ack.Wait(ctx) != service.ErrTweetDropped {
+		t.Errorf("Expected the tweet to be dropped without error but the error was %v", err)
+	}
+
+	if stats := tweetWriter.Stats(); stats.Dropped != 1 || stats.Written != 3 {
+		t.Errorf("Expected 1 tweet dropped and 3 written but the stats were %+v", stats)
+	}
+
+	assertTexts(t, blockingWriter.writer.Tweets, "Being written", "Queued 0", "Queued 1")
+}
+
+func TestChannelTweetWriterRejectsTweetsWhenTheQueueIsFull(t *testing.T) {
+
+	// Initialization
+	tweetWriter, blockingWriter := newBlockedWriter(t, service.ErrorOnOverflow)
+	defer close(blockingWriter.release)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	// Operation
+	_, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("grupoesfera", "Rejected"))
+
+	// Validation
+	if err != service.ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull but was %v", err)
+	}
+
+	if tweets := tweetManager.GetTweets(); len(tweets) != 0 {
+		t.Errorf("Expected the rejected tweet not to be published but the tweets were %v", tweets)
+	}
+
+	if stats := tweetWriter.Stats(); stats.Rejected != 1 {
+		t.Errorf("Expected 1 tweet rejected but the stats were %+v", stats)
+	}
+}
+
+func TestChannelTweetWriterBlocksUntilThereIsRoom(t *testing.T) {
+
+	// Initialization
+	tweetWriter, blockingWriter := newBlockedWriter(t, service.BlockOnOverflow)
+
+	queued := make(chan error)
+
+	// Operation
+	go func() {
+		_, err := tweetWriter.Enqueue(context.Background(), domain.NewTextTweet("grupoesfera", "Waiting"))
+		queued <- err
+	}()
+
+	// Validation
+	select {
+	case <-queued:
+		t.Fatalf("Expected the tweet to wait for room in the queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(blockingWriter.release)
+
+	if err := <-queued; err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+
+	tweetWriter.Close()
+
+	assertTexts(t, blockingWriter.writer.Tweets, "Being written", "Queued 0", "Queued 1", "Waiting")
+}
+
+func TestPublishTweetStopsWaitingForRoomWhenTheContextIsCancelled(t *testing.T) {
+
+	// Initialization
+	tweetWriter, blockingWriter := newBlockedWriter(t, service.BlockOnOverflow)
+	defer close(blockingWriter.release)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("grupoesfera", "Cancelled"))
+
+	// Validation
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded but was %v", err)
+	}
+
+	if tweets := tweetManager.GetTweets(); len(tweets) != 0 {
+		t.Errorf("Expected the cancelled tweet not to be published but the tweets were %v", tweets)
+	}
+}
+
+func TestPublishTweetAndWaitReturnsTheIdWhenTheWriteTakesTooLong(t *testing.T) {
+
+	// Initialization
+	blockingWriter := &blockingTweetWriter{make(chan bool), service.NewMemoryTweetWriter()}
+	tweetWriter := service.NewChannelTweetWriter(blockingWriter)
+
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// Operation
+	id, err := tweetManager.PublishTweetAndWait(ctx, domain.NewTextTweet("grupoesfera", "Slow write"))
+
+	close(blockingWriter.release)
+	tweetWriter.Close()
+
+	// Validation
+	if err != context.DeadlineExceeded || id != 1 {
+		t.Errorf("Expected tweet 1 and the deadline to be exceeded but were %d and %v", id, err)
+	}
+
+	assertTexts(t, blockingWriter.writer.Tweets, "Slow write")
+}
+
+func TestReadersDontWaitForTweetsBlockedByAFullQueue(t *testing.T) {
+
+	// Initialization
+	tweetWriter, blockingWriter := newBlockedWriter(t, service.BlockOnOverflow)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	published := make(chan error)
+
+	go func() {
+		_, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("nick", "Waiting for room"))
+		published <- err
+	}()
+
+	// Operation
+	read := make(chan int)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		read <- len(tweetManager.GetTweets())
+	}()
+
+	// Validation
+	select {
+	case count := <-read:
+		if count != 0 {
+			t.Errorf("Expected the tweet waiting for room not to be read yet but were %d tweets", count)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the readers not to wait for the full queue")
+	}
+
+	close(blockingWriter.release)
+
+	if err := <-published; err != nil {
+		t.Errorf("Unexpected error publishing: %s", err.Error())
+	}
+
+	if count := tweetManager.CountTweetsByUser("nick"); count != 1 {
+		t.Errorf("Expected the tweet to be published once there was room but were %d tweets", count)
+	}
+
+	tweetWriter.Close()
+}
+
+func TestTweetsRejectedByAFullQueueAreNeverSeen(t *testing.T) {
+
+	// Initialization
+	tweetWriter, blockingWriter := newBlockedWriter(t, service.ErrorOnOverflow)
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), tweetWriter)
+
+	events := make([]service.Event, 0)
+
+	tweetManager.Events().SubscribeSync("recorder", func(event service.Event) error {
+		events = append(events, event)
+		return nil
+	})
+
+	// Operation
+	_, err := tweetManager.PublishTweet(context.Background(), domain.NewTextTweet("nick", "Rejected"))
+
+	// Validation
+	if err != service.ErrQueueFull {
+		t.Errorf("Expected the tweet to be rejected but was %v", err)
+	}
+
+	if len(tweetManager.GetTweets()) != 0 || tweetManager.IsRegistered("nick") || len(events) != 0 {
+		t.Errorf("Expected the rejected tweet never to be seen but the events were %v", events)
+	}
+
+	close(blockingWriter.release)
+	tweetWriter.Close()
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+)
+
+// DefaultRenameGracePeriod is how long an old handle redirects to the new one
+// after a rename before it is released
+const DefaultRenameGracePeriod = 30 * 24 * time.Hour
+
+type userRename struct {
+	from string
+	to   string
+	date time.Time
+}
+
+func (manager *TweetManager) SetRenameGracePeriod(gracePeriod time.Duration) {
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.renameGracePeriod = gracePeriod
+}
+
+func (manager *TweetManager) RenameUser(oldUser, newUser string) error {
+
+	manager.lock()
+	defer manager.unlock()
+
+	oldHandle := domain.NormalizeHandle(oldUser)
+
+	// Lookalikes share the skeleton of the registered handle, so only the
+	// handle itself is accepted
+	if !manager.isRegistered(oldHandle) {
+		return fmt.Errorf("user %s does not exist", oldUser)
+	}
+
+	if err := domain.ValidateHandle(newUser); err != nil {
+		return err
+	}
+
+	delete(manager.usersBySkeleton, domain.HandleSkeleton(oldHandle))
+
+	newHandle, err := manager.registerUser(newUser)
+
+	if err != nil {
+		manager.usersBySkeleton[domain.HandleSkeleton(oldHandle)] = oldHandle
+		return err
+	}
+
+	if err := manager.repository.RenameUser(oldHandle, newUser); err != nil {
+		delete(manager.usersBySkeleton, domain.HandleSkeleton(newHandle))
+		manager.usersBySkeleton[domain.HandleSkeleton(oldHandle)] = oldHandle
+		return err
+	}
+
+	manager.reindex()
+
+	manager.following[newHandle] = manager.following[oldHandle]
+	delete(manager.following, oldHandle)
+
+	for _, following := range manager.following {
+		if following[oldHandle] {
+			delete(following, oldHandle)
+			following[newHandle] = true
+		}
+	}
+
+	for _, users := range manager.likes {
+		if users[oldHandle] {
+			delete(users, oldHandle)
+			users[newHandle] = true
+		}
+	}
+
+	manager.renames = append(manager.renames, userRename{oldHandle, newHandle, manager.clock()})
+
+	manager.publishEvent(UserRenamed{oldHandle, newHandle})
+
+	return nil
+}
+
+// ResolveUser returns the normalized handle the user is known by now,
+// following the redirects of the renames still in their grace period
+func (manager *TweetManager) ResolveUser(user string) string {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.resolveUser(user)
+}
+
+func (manager *TweetManager) resolveUser(user string) string {
+
+	handle := domain.NormalizeHandle(user)
+
+	if manager.isRegistered(handle) {
+		return handle
+	}
+
+	now := manager.clock()
+
+	for _, rename := range manager.renames {
+		if rename.from == handle && now.Before(rename.date.Add(manager.renameGracePeriod)) {
+			handle = rename.to
+		}
+	}
+
+	return handle
+}
+
+// GetMentions returns the actual handles of the users mentioned in the tweet.
+// Mentions of handles renamed after the tweet was published resolve to the
+// renamed user, even when the grace period is over, and so do mentions of
+// handles still in their grace period
+func (manager *TweetManager) GetMentions(tweet domain.Tweet) []string {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.getMentions(tweet)
+}
+
+func (manager *TweetManager) getMentions(tweet domain.Tweet) []string {
+
+	mentions := make([]string, 0)
+
+	for _, mention := range domain.Mentions(tweet.GetText()) {
+
+		handle := domain.NormalizeHandle(mention)
+
+		for _, rename := range manager.renames {
+			if rename.from == handle && rename.date.After(*tweet.GetDate()) {
+				handle = rename.to
+			}
+		}
+
+		mentions = append(mentions, manager.resolveUser(handle))
+	}
+
+	return mentions
+}
+
+func (manager *TweetManager) IsRegistered(handle string) bool {
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.isRegistered(handle)
+}
+
+func (manager *TweetManager) isRegistered(handle string) bool {
+	return manager.usersBySkeleton[domain.HandleSkeleton(handle)] == handle
+}
+
+func (manager *TweetManager) isRedirected(handle string) bool {
+	return !manager.isRegistered(handle) && manager.resolveUser(handle) != handle
+}
@@ -0,0 +1,156 @@
+package service_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cursoGo/src/domain"
+	"github.com/cursoGo/src/service"
+)
+
+func TestRenamedUserKeepsItsTweets(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+
+	// Operation
+	err := tweetManager.RenameUser("nick", "nicolas")
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	tweets := tweetManager.GetTweetsByUser("nicolas")
+
+	if len(tweets) != 1 {
+		t.Errorf("Expected size is 1 but was %d", len(tweets))
+		return
+	}
+
+	if tweets[0].GetUser() != "nicolas" {
+		t.Errorf("Expected user is nicolas but was %s", tweets[0].GetUser())
+	}
+}
+
+func TestLookalikesOfAUserCantRenameIt(t *testing.T) {
+
+	// Initialization
+	tweetManager := newManagerWithUsers("nick")
+
+	// Operation
+	err := tweetManager.RenameUser("n1ck", "bob")
+
+	// Validation
+	if err == nil || err.Error() != "user n1ck does not exist" {
+		t.Errorf("Expected the lookalike not to exist but the error was %v", err)
+	}
+
+	if !tweetManager.IsRegistered("nick") || tweetManager.IsRegistered("bob") {
+		t.Errorf("Expected nick to be kept and bob not to be registered")
+	}
+
+	if tweetManager.ResolveUser("n1ck") != "n1ck" {
+		t.Errorf("Expected the lookalike not to redirect but it redirects to %s", tweetManager.ResolveUser("n1ck"))
+	}
+
+	if count := tweetManager.CountTweetsByUser("nick"); count != 1 {
+		t.Errorf("Expected nick to keep its tweet but had %d", count)
+	}
+}
+
+func TestOldHandleRedirectsDuringGracePeriod(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	now := time.Now()
+	tweetManager.SetClock(func() time.Time { return now })
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+	tweetManager.RenameUser("nick", "nicolas")
+
+	// Operation
+	tweets := tweetManager.GetTweetsByUser("nick")
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "I am the new nick"))
+
+	// Validation
+	if len(tweets) != 1 {
+		t.Errorf("Expected size is 1 but was %d", len(tweets))
+	}
+
+	if err == nil || err.Error() != "user nick has been renamed to nicolas" {
+		t.Errorf("Expected error is user nick has been renamed to nicolas but was %v", err)
+	}
+}
+
+func TestOldHandleIsReleasedAfterGracePeriod(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	now := time.Now()
+	tweetManager.SetClock(func() time.Time { return now })
+	tweetManager.SetRenameGracePeriod(time.Hour)
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+	tweetManager.RenameUser("nick", "nicolas")
+
+	now = now.Add(2 * time.Hour)
+
+	// Operation
+	_, err := tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "I am the new nick"))
+
+	// Validation
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+	}
+
+	if count := tweetManager.CountTweetsByUser("nick"); count != 1 {
+		t.Errorf("Expected count is 1 but was %d", count)
+	}
+
+	if count := tweetManager.CountTweetsByUser("nicolas"); count != 1 {
+		t.Errorf("Expected count is 1 but was %d", count)
+	}
+}
+
+func TestMentionsOfTheOldHandleResolveToTheRenamedUser(t *testing.T) {
+
+	// Initialization
+	tweetManager := service.NewTweetManager(service.NewMemoryTweetRepository(), service.NewChannelTweetWriter(service.NewMemoryTweetWriter()))
+
+	now := time.Now()
+	tweetManager.SetClock(func() time.Time { return now })
+	tweetManager.SetRenameGracePeriod(time.Hour)
+
+	ctx := context.Background()
+
+	tweetManager.PublishTweet(ctx, domain.NewTextTweet("nick", "This is my first tweet"))
+
+	tweet := domain.NewTextTweet("grupoesfera", "Hello @nick")
+	tweetManager.PublishTweet(ctx, tweet)
+
+	now = now.Add(time.Minute)
+	tweetManager.RenameUser("nick", "nicolas")
+	now = now.Add(2 * time.Hour)
+
+	// Operation
+	mentions := tweetManager.GetMentions(tweet)
+
+	// Validation
+	if !reflect.DeepEqual(mentions, []string{"nicolas"}) {
+		t.Errorf("Expected mentions are [nicolas] but were %v", mentions)
+	}
+}
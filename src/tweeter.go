@@ -1,30 +1,164 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/abiosoft/ishell"
 	"github.com/cursoGo/src/domain"
 	"github.com/cursoGo/src/rest"
+	"github.com/cursoGo/src/search"
 	"github.com/cursoGo/src/service"
 )
 
+const shellPageSize = 10
+
+// shutdownTimeout is how long the requests in flight and the queued tweets
+// have to finish when exiting
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
-	quit := make(chan bool)
+	ctx := service.WithPublishSource(context.Background(), service.PublishSource{Channel: service.ShellChannel})
+
+	keyring, err := loadKeyring()
+
+	if err != nil {
+		fmt.Println("Error loading the encryption keys:", err)
+		os.Exit(1)
+	}
+
+	storeConfig := service.DefaultTweetStoreConfig()
+	storeConfig.Keyring = keyring
+
+	tweetStore, err := service.OpenTweetStore("tweets", storeConfig)
+
+	if err != nil {
+		fmt.Println("Error opening the tweets directory:", err)
+		os.Exit(1)
+	}
+
+	repository := service.NewMemoryTweetRepository()
+
+	if _, err := tweetStore.Replay(repository); err != nil {
+		fmt.Println("Error reading the tweets directory:", err)
+		os.Exit(1)
+	}
+
+	// tweets.txt is encrypted with the keys of the store, if any, so the
+	// tweets aren't left readable on disk
+	fileConfig := service.DefaultFileTweetWriterConfig()
+	fileConfig.Keyring = keyring
+
+	fileWriter, err := service.NewFileTweetWriter(fileConfig)
+
+	if err != nil {
+		fmt.Println("Error opening tweets.txt:", err)
+		os.Exit(1)
+	}
+
+	// The store keeps the tweets, so it waits for room instead of dropping
+	// them. tweets.txt is only a copy
+	deadLetters, err := service.OpenEncryptedDeadLetterQueue("dead_letters.jsonl", tweetStore, keyring)
+
+	if err != nil {
+		fmt.Println("Error opening the dead letters:", err)
+		os.Exit(1)
+	}
+
+	storeSinkConfig := service.DefaultSinkConfig()
+	storeSinkConfig.Overflow = service.BlockOnOverflow
+	storeSinkConfig.DeadLetters = deadLetters
+
+	tweetSinks := service.NewMultiTweetWriter()
+	tweetSinks.AddRequiredSink("store", tweetStore, storeSinkConfig)
+	tweetSinks.AddSink("file", fileWriter, service.DefaultSinkConfig())
+
+	// The sinks retry on their own, so retrying here would write the tweets
+	// twice to the sinks that didn't fail
+	tweetWriterConfig := service.DefaultChannelTweetWriterConfig()
+	tweetWriterConfig.MaxRetries = 0
+
+	tweetWriter := service.NewChannelTweetWriterWithConfig(tweetSinks, tweetWriterConfig)
+
+	tweetManager := service.NewTweetManager(repository, tweetWriter)
+
+	tweetStore.Attach(tweetManager, deadLetters)
+
+	recommendationService := service.NewRecommendationService(tweetManager)
+
+	notificationService := service.NewNotificationService(tweetManager)
+
+	trendService := service.NewTrendService(tweetManager, service.DefaultTrendConfig())
+
+	searchService := search.NewSearchService(tweetManager)
+
+	rankingConfig, err := service.LoadRankingConfig("ranking.json")
+
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error loading ranking.json, using the default ranking:", err)
+		rankingConfig = service.DefaultRankingConfig()
+	}
 
-	fileTweetWriter := service.NewFileTweetWriter()
-	tweetWriter := service.NewChannelTweetWriter(fileTweetWriter)
+	rankingService := service.NewRankingService(tweetManager, rankingConfig)
 
-	tweetManager := service.NewTweetManager(tweetWriter)
+	rateLimiterConfig, err := service.LoadRateLimiterConfig("ratelimit.json")
 
-	ginServer := rest.NewGinServer(tweetManager)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error loading ratelimit.json, using the default limits:", err)
+		rateLimiterConfig = service.DefaultRateLimiterConfig()
+	}
+
+	tweetManager.SetRateLimiter(service.NewRateLimiter(rateLimiterConfig))
+
+	ginServer := rest.NewGinServer(tweetManager, recommendationService, notificationService, trendService,
+		searchService, rankingService, tweetSinks, deadLetters)
+	ginServer.SetAPITokens(rest.ParseTokens(os.Getenv(rest.APITokensVariable)))
+	ginServer.SetAdminTokens(rest.ParseTokens(os.Getenv(rest.AdminTokensVariable)))
 	ginServer.StartGinServer()
 
+	// Stopped in reverse order: the server stops taking tweets, the queued
+	// ones are written to every sink and then the files are closed
+	lifecycle := NewLifecycle()
+
+	lifecycle.OnStop("tweet store", func(ctx context.Context) error {
+		return tweetStore.Close()
+	})
+
+	lifecycle.OnStop("tweets file", func(ctx context.Context) error {
+		return fileWriter.Close()
+	})
+
+	lifecycle.OnStop("event bus", func(ctx context.Context) error {
+		err := tweetManager.Events().Drain(ctx)
+		tweetManager.Events().Close()
+		return err
+	})
+
+	lifecycle.OnStop("tweet sinks", stopPipeline(tweetSinks))
+
+	lifecycle.OnStop("tweet writer", stopPipeline(tweetWriter))
+
+	lifecycle.OnStop("http server", ginServer.Shutdown)
+
 	shell := ishell.New()
 	shell.SetPrompt("Tweeter >> ")
 	shell.Print("Type 'help' to know commands\n")
 
+	lifecycle.StopOnSignals(shell.Close)
+
+	shell.Interrupt(func(c *ishell.Context, count int, input string) {
+		if count >= 2 {
+			c.Stop()
+			return
+		}
+		c.Println("Input Ctrl-c once more to exit")
+	})
+
 	shell.AddCmd(&ishell.Cmd{
 		Name: "publishTweet",
 		Help: "Publishes a tweet",
@@ -32,9 +166,7 @@ func main() {
 
 			defer c.ShowPrompt(true)
 
-			c.Print("Type your username: ")
-
-			user := c.ReadLine()
+			user := readUser(c, "Type your username: ")
 
 			c.Print("Type your tweet: ")
 
@@ -42,7 +174,7 @@ func main() {
 
 			tweet := domain.NewTextTweet(user, text)
 
-			id, err := tweetManager.PublishTweet(tweet, quit)
+			id, err := tweetManager.PublishTweet(ctx, tweet)
 
 			if err == nil {
 				c.Printf("Tweet sent with id: %v\n", id)
@@ -61,9 +193,7 @@ func main() {
 
 			defer c.ShowPrompt(true)
 
-			c.Print("Type your username: ")
-
-			user := c.ReadLine()
+			user := readUser(c, "Type your username: ")
 
 			c.Print("Type your tweet: ")
 
@@ -75,7 +205,7 @@ func main() {
 
 			tweet := domain.NewImageTweet(user, text, url)
 
-			id, err := tweetManager.PublishTweet(tweet, quit)
+			id, err := tweetManager.PublishTweet(ctx, tweet)
 
 			if err == nil {
 				c.Printf("Tweet sent with id: %v\n", id)
@@ -94,9 +224,7 @@ func main() {
 
 			defer c.ShowPrompt(true)
 
-			c.Print("Type your username: ")
-
-			user := c.ReadLine()
+			user := readUser(c, "Type your username: ")
 
 			c.Print("Type your tweet: ")
 
@@ -110,7 +238,7 @@ func main() {
 
 			tweet := domain.NewQuoteTweet(user, text, quoteTweet)
 
-			id, err := tweetManager.PublishTweet(tweet, quit)
+			id, err := tweetManager.PublishTweet(ctx, tweet)
 
 			if err == nil {
 				c.Printf("Tweet sent with id: %v\n", id)
@@ -144,9 +272,9 @@ func main() {
 
 			defer c.ShowPrompt(true)
 
-			tweets := tweetManager.GetTweets()
-
-			c.Println(tweets)
+			showPages(c, func(cursor string) (service.Page, error) {
+				return tweetManager.GetTweetsPage(cursor, shellPageSize)
+			})
 
 			return
 		},
@@ -178,9 +306,7 @@ func main() {
 
 			defer c.ShowPrompt(true)
 
-			c.Print("Type the user: ")
-
-			user := c.ReadLine()
+			user := readUser(c, "Type the user: ")
 
 			count := tweetManager.CountTweetsByUser(user)
 
@@ -197,13 +323,505 @@ func main() {
 
 			defer c.ShowPrompt(true)
 
-			c.Print("Type the user: ")
+			user := readUser(c, "Type the user: ")
+
+			showPages(c, func(cursor string) (service.Page, error) {
+				return tweetManager.GetTweetsByUserPage(user, cursor, shellPageSize)
+			})
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "renameUser",
+		Help: "Changes the username keeping its tweets",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type the user: ")
+
+			newUser := readUser(c, "Type the new username: ")
+
+			err := tweetManager.RenameUser(user, newUser)
+
+			if err == nil {
+				c.Printf("User renamed to %s\n", newUser)
+			} else {
+				c.Println("Error renaming user:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "follow",
+		Help: "Follows an user",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			followed := readUser(c, "Type the user to follow: ")
+
+			err := tweetManager.Follow(user, followed)
+
+			if err == nil {
+				c.Printf("You are following %s\n", followed)
+			} else {
+				c.Println("Error following user:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "unfollow",
+		Help: "Stops following an user",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			followed := readUser(c, "Type the user to unfollow: ")
+
+			err := tweetManager.Unfollow(user, followed)
+
+			if err == nil {
+				c.Printf("You are not following %s anymore\n", followed)
+			} else {
+				c.Println("Error unfollowing user:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "whoToFollow",
+		Help: "Shows users recommended to follow",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			recommendations := recommendationService.GetRecommendations(user, 10)
+
+			if len(recommendations) == 0 {
+				c.Println("No recommendations")
+			}
+
+			for _, recommendation := range recommendations {
+				c.Printf("@%s: %s\n", recommendation.User, strings.Join(recommendation.Reasons, ", "))
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "dismissRecommendation",
+		Help: "Stops recommending an user",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			dismissed := readUser(c, "Type the user to dismiss: ")
+
+			recommendationService.Dismiss(user, dismissed)
+
+			c.Printf("%s won't be recommended anymore\n", dismissed)
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "likeTweet",
+		Help: "Likes the tweet with the provided id",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			c.Print("Type the id of the tweet you like: ")
+
+			id, _ := strconv.Atoi(c.ReadLine())
+
+			err := tweetManager.LikeTweet(user, id)
+
+			if err == nil {
+				c.Println("Tweet liked")
+			} else {
+				c.Println("Error liking tweet:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "deleteTweet",
+		Help: "Deletes one of your tweets by its id",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			c.Print("Type the id of the tweet to delete: ")
+
+			id, _ := strconv.Atoi(c.ReadLine())
+
+			err := tweetManager.DeleteTweet(user, id)
+
+			if err == nil {
+				c.Println("Tweet deleted")
+			} else {
+				c.Println("Error deleting tweet:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "showMentions",
+		Help: "Shows the tweets that mention the user",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type the user: ")
+
+			showPages(c, func(cursor string) (service.Page, error) {
+				return notificationService.GetMentionsPage(user, cursor, shellPageSize)
+			})
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "showNotifications",
+		Help: "Shows the notifications of the user and marks them as read",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			c.Printf("%d unread notifications\n", notificationService.CountUnread(user))
+
+			for _, notification := range notificationService.GetNotifications(user) {
+
+				status := " "
+				if !notification.Read {
+					status = "*"
+				}
+
+				if notification.Tweet != nil {
+					c.Printf("%s %s from @%s: %s\n", status, notification.Type, notification.From, notification.Tweet)
+				} else {
+					c.Printf("%s %s from @%s\n", status, notification.Type, notification.From)
+				}
+			}
+
+			notificationService.MarkAllAsRead(user)
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "setNotificationPreference",
+		Help: "Enables or disables a type of notification",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			c.Printf("Type the notification type %v: ", service.NotificationTypes)
+
+			notificationType := service.NotificationType(c.ReadLine())
+
+			c.Print("Do you want to receive them? (yes/no): ")
+
+			enabled := c.ReadLine() == "yes"
+
+			err := notificationService.SetPreference(user, notificationType, enabled)
+
+			if err == nil {
+				c.Println(notificationService.GetPreferences(user))
+			} else {
+				c.Println("Error setting notification preference:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "trends",
+		Help: "Shows the trending hashtags and terms",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			c.Printf("Type the window %v: ", trendService.GetWindows())
+
+			trends, err := trendService.GetTrends(c.ReadLine(), 10)
+
+			if err != nil {
+				c.Println("Error getting trends:", err)
+				return
+			}
+
+			for index, trend := range trends {
+				c.Printf("%d. %s (%d)\n", index+1, trend.Term, trend.Count)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "search",
+		Help: "Searches tweets by their text",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			c.Print("Type your search: ")
+
+			results, err := searchService.Search(c.ReadLine(), 10)
+
+			if err != nil {
+				c.Println("Invalid search:", err)
+				return
+			}
+
+			if len(results) == 0 {
+				c.Println("No tweets found")
+			}
+
+			for _, result := range results {
+				c.Printf("%d @%s: %s\n", result.Tweet.GetId(), result.Tweet.GetUser(), result.Snippet)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "showTopTweets",
+		Help: "Shows the top tweets for the user",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			c.Print("Show the score of every signal? (yes/no): ")
+
+			debug := c.ReadLine() == "yes"
+
+			for _, ranked := range rankingService.GetTopTweets(user, shellPageSize, debug) {
+
+				c.Printf("%d %s\n", ranked.Tweet.GetId(), ranked.Tweet)
+
+				if debug {
+					c.Printf("   score %.3f %v\n", ranked.Score, ranked.Signals)
+				}
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "showTweetsBetween",
+		Help: "Shows the tweets published between two days, like yesterday or 2017-11-03",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			from, _ := readDate(c, "Type the first day (empty for the first tweet): ")
+			to, found := readDate(c, "Type the last day (empty for today): ")
+
+			if !found {
+				to = time.Now()
+			}
+
+			to = to.AddDate(0, 0, 1)
+
+			var tweets []domain.Tweet
+
+			if user := readUser(c, "Type the user (empty for everybody): "); user != "" {
+				tweets = tweetManager.GetTweetsByUserBetween(user, from, to)
+			} else {
+				tweets = tweetManager.GetTweetsBetween(from, to)
+			}
+
+			showTweetsByDate(c, tweets)
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "showTweetsOnThisDay",
+		Help: "Shows the tweets published on the same day of every year",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			date, found := readDate(c, "Type the day (empty for today): ")
+
+			if !found {
+				date = time.Now()
+			}
+
+			showTweetsByDate(c, tweetManager.GetTweetsOnThisDay(date))
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "exportArchive",
+		Help: "Writes a zip with your tweets, likes and profile to a file",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			user := readUser(c, "Type your username: ")
+
+			c.Print("Type the file to write (empty for <username>-archive.zip): ")
+
+			path := c.ReadLine()
+
+			if path == "" {
+				path = domain.NormalizeHandle(user) + "-archive.zip"
+			}
+
+			file, err := os.Create(path)
+
+			if err != nil {
+				c.Println("Error creating archive:", err)
+				return
+			}
+
+			err = tweetManager.ExportArchive(user, file)
+
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+
+			if err == nil {
+				c.Println("Archive written to", path)
+			} else {
+				os.Remove(path)
+				c.Println("Error exporting archive:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "importArchive",
+		Help: "Publishes the tweets of an archive exported from another tweeter",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			c.Print("Type the archive file: ")
 
-			user := c.ReadLine()
+			file, err := os.Open(c.ReadLine())
 
-			tweets := tweetManager.GetTweetsByUser(user)
+			if err != nil {
+				c.Println("Error opening archive:", err)
+				return
+			}
 
-			c.Println(tweets)
+			defer file.Close()
+
+			info, err := file.Stat()
+
+			if err != nil {
+				c.Println("Error opening archive:", err)
+				return
+			}
+
+			ids, err := tweetManager.ImportArchive(ctx, file, info.Size())
+
+			if err == nil {
+				c.Printf("%d tweets imported\n", len(ids))
+			} else {
+				c.Println("Error importing archive:", err)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "listDeadLetters",
+		Help: "Lists the tweets that couldn't be stored",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			letters, err := deadLetters.List()
+
+			if err != nil {
+				c.Println("Error listing dead letters:", err)
+				return
+			}
+
+			if len(letters) == 0 {
+				c.Println("No dead letters")
+			}
+
+			for _, letter := range letters {
+
+				if letter.Tweet == nil {
+					c.Printf("%d %s %s (%s)\n", letter.Id, letter.FailedAt.Format("2006-01-02 15:04"), letter.Operation, letter.Error)
+					continue
+				}
+
+				c.Printf("%d %s %s (%s)\n", letter.Id, letter.FailedAt.Format("2006-01-02 15:04"), letter.Tweet, letter.Error)
+			}
+
+			return
+		},
+	})
+
+	shell.AddCmd(&ishell.Cmd{
+		Name: "replayDeadLetters",
+		Help: "Stores again the tweets that couldn't be stored",
+		Func: func(c *ishell.Context) {
+
+			defer c.ShowPrompt(true)
+
+			replayed, err := deadLetters.Replay()
+
+			c.Printf("%d dead letters replayed\n", replayed)
+
+			if err != nil {
+				c.Println("Error replaying dead letters:", err)
+			}
 
 			return
 		},
@@ -211,4 +829,123 @@ func main() {
 
 	shell.Run()
 
+	stopCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	err = lifecycle.Stop(stopCtx)
+	cancel()
+
+	if err != nil {
+		fmt.Println("Error shutting down:", err)
+	}
+
+	os.Exit(ExitCode(err))
+}
+
+// loadKeyring reads the encryption keys from the environment or from
+// encryption.json. Without keys the tweets are stored in plain text
+func loadKeyring() (*service.Keyring, error) {
+
+	var keyring *service.Keyring
+	var err error
+
+	if keys := os.Getenv(service.EncryptionKeysVariable); keys != "" {
+		keyring, err = service.ParseKeyring(keys)
+	} else {
+		keyring, err = service.LoadKeyring("encryption.json")
+	}
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyring.SetMigratePlaintext(os.Getenv(service.MigratePlaintextVariable) != "")
+
+	return keyring, nil
+}
+
+func readUser(c *ishell.Context, prompt string) string {
+
+	for {
+
+		c.Print(prompt)
+
+		user := c.ReadLine()
+
+		err := domain.ValidateHandle(user)
+
+		if err == nil || user == "" {
+			return user
+		}
+
+		c.Println("Invalid username:", err)
+	}
+}
+
+// readDate asks for a day until it can be parsed. It returns false when
+// the input is empty
+func readDate(c *ishell.Context, prompt string) (time.Time, bool) {
+
+	for {
+
+		c.Print(prompt)
+
+		text := c.ReadLine()
+
+		if text == "" {
+			return time.Time{}, false
+		}
+
+		date, err := service.ParseDate(text, time.Now())
+
+		if err == nil {
+			return date, true
+		}
+
+		c.Println("Invalid date:", err)
+	}
+}
+
+func showTweetsByDate(c *ishell.Context, tweets []domain.Tweet) {
+
+	if len(tweets) == 0 {
+		c.Println("No tweets found")
+	}
+
+	for _, tweet := range tweets {
+		c.Printf("%s %d %s\n", tweet.GetDate().Format("2006-01-02 15:04"), tweet.GetId(), tweet)
+	}
+}
+
+func showPages(c *ishell.Context, getPage func(cursor string) (service.Page, error)) {
+
+	cursor := ""
+
+	for {
+
+		page, err := getPage(cursor)
+
+		if err != nil {
+			c.Println("Error listing tweets:", err)
+			return
+		}
+
+		for _, tweet := range page.Tweets {
+			c.Printf("%d %s\n", tweet.GetId(), tweet)
+		}
+
+		if page.NextCursor == "" {
+			return
+		}
+
+		c.Print("Show more? (yes/no): ")
+
+		if c.ReadLine() != "yes" {
+			return
+		}
+
+		cursor = page.NextCursor
+	}
 }